@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/infuseai/artivc/pkg/avc"
+	"github.com/spf13/cobra"
+)
+
+var catCommand = &cobra.Command{
+	Use:                   "cat [-o <output>] <repository>[@<commit>|<tag>]:<path> | [<commit>|<tag>]:<path>",
+	DisableFlagsInUseLine: true,
+	Short:                 "Print a file of a commit",
+	Long: `Print a file of a commit. The file is streamed from the repository without a workspace, and the metadata
+is not cached in the workspace. In a workspace, the commit or tag of the workspace repository can be given
+without the repository.`,
+	Example: `  # Print a file of the latest version
+  avc cat s3://bucket/mydataset:path/to/file
+
+  # Print a file of the specific version
+  avc cat s3://bucket/mydataset@v1.0.0:labels.csv | head
+
+  # Print a file of the specific version of the workspace repository
+  avc cat v1.0.0:labels.csv
+
+  # Save a file
+  avc cat -o /tmp/labels.csv s3://bucket/mydataset@v1.0.0:labels.csv`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		target, path, err := parseCatArg(args[0])
+		exitWithError(err)
+
+		output, err := cmd.Flags().GetString("output")
+		exitWithError(err)
+
+		var dest io.Writer = os.Stdout
+		if output != "" {
+			f, err := os.Create(output)
+			exitWithError(err)
+			defer f.Close()
+			dest = f
+		}
+
+		if isRefStr(target) {
+			client, err := avc.Open("", avc.Options{})
+			if err == nil {
+				_, err = client.Cat(cmd.Context(), target, path, dest)
+				exitWithCatError(err, output)
+				return
+			} else if target == "" || !errors.Is(err, avc.ErrWorkspaceNotFound) {
+				exitWithError(err)
+			}
+		}
+
+		// the target is a repository
+		repoUrl, ref, err := parseRepoStr(target)
+		exitWithError(err)
+
+		_, err = avc.Cat(cmd.Context(), repoUrl, ref, path, dest, avc.Options{})
+		exitWithCatError(err, output)
+	},
+}
+
+func init() {
+	catCommand.Flags().StringP("output", "o", "", "Output file")
+}
+
+// parseCatArg splits the argument into the repository or reference and the path by the last colon
+func parseCatArg(arg string) (target string, path string, err error) {
+	i := strings.LastIndex(arg, ":")
+	if i < 0 || i == len(arg)-1 || strings.HasPrefix(arg[i+1:], "//") {
+		err = fmt.Errorf("invalid file: %s. The file should be in the format <repository>[@<ref>]:<path> or <ref>:<path>", arg)
+		return
+	}
+
+	return arg[:i], arg[i+1:], nil
+}
+
+// isRefStr checks if the target could be a commit or tag of the workspace repository instead of a repository
+func isRefStr(target string) bool {
+	return !strings.ContainsAny(target, "/:@\\")
+}
+
+// exitWithCatError removes the partial output file before exiting with the error
+func exitWithCatError(err error, output string) {
+	if err != nil && output != "" {
+		os.Remove(output)
+	}
+	exitWithError(err)
+}
@@ -1,10 +1,8 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/infuseai/artivc/internal/core"
 	"github.com/infuseai/artivc/internal/repository"
@@ -43,14 +41,13 @@ var configCommand = &cobra.Command{
 			key := args[0]
 			value := args[1]
 			if key == "repo.url" {
-				if strings.HasPrefix(value, "http") && !repository.IsAzureStorageUrl(value) {
-					exitWithError(errors.New("http(s) repository is not supported"))
-				}
-
 				result, err := repository.ParseRepo(value)
 				exitWithError(err)
 
-				_, err = repository.NewRepository(result)
+				repoConfig, err := config.RepositoryConfig()
+				exitWithError(err)
+
+				_, err = repository.NewRepository(result, repoConfig)
 				exitWithError(err)
 			}
 
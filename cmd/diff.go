@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/pkg/avc"
 	"github.com/spf13/cobra"
 )
 
@@ -12,23 +12,20 @@ var diffCommand = &cobra.Command{
 avc diff v0.1.0 v0.2.0`,
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		left := args[0]
-		right := args[1]
-		config, err := core.LoadConfig("")
-		exitWithError(err)
-
-		mngr, err := core.NewArtifactManager(config)
-		exitWithError(err)
+		printer := newOutputPrinter(cmd)
 
-		err = mngr.Fetch()
+		client, err := avc.Open("", avc.Options{Output: printer.output()})
 		exitWithError(err)
 
-		result, err := mngr.Diff(core.DiffOptions{
-			LeftRef:  left,
-			RightRef: right,
-		})
+		result, err := client.Diff(cmd.Context(), args[0], args[1])
 		exitWithError(err)
 
-		result.Print(true)
+		printer.print(result)
+		exitWithDiffCode(cmd, result)
 	},
 }
+
+func init() {
+	addOutputFlag(diffCommand)
+	addExitCodeFlag(diffCommand)
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/infuseai/artivc/pkg/avc"
+	"github.com/spf13/cobra"
+)
+
+var exportCommand = &cobra.Command{
+	Use:                   "export [-f <format>] [-o <output>] [--shuffle] [--seed <seed>] <repository>[@<commit>|<tag>] | <commit>|<tag>",
+	DisableFlagsInUseLine: true,
+	Short:                 "Export a commit as an archive",
+	Long: `Export a commit as an archive. The files are streamed from the repository into the archive in the order of
+the commit, or in a random order if --shuffle or --seed is set. The archive is written to stdout if the output is
+not specified.
+
+The formats are
+  tar         A tar archive
+  tar.zst     A zstd compressed tar archive
+  zip         A zip archive
+  bagit       A BagIt bag in a tar archive, with the SHA-256 checksum manifests
+  webdataset  The tar shards of the WebDataset. The files sharing the same name without the extensions are
+              kept together as a sample. With --max-shard-size, the output is a pattern of the shard files
+              (e.g. "train-%06d.tar")
+
+The format is guessed from the extension of the output if it is not specified.`,
+	Example: `  # Export the latest version to a tar archive
+  avc export -o mydataset.tar s3://bucket/mydataset
+
+  # Export the specific version of the workspace repository as a zip archive
+  avc export -o mydataset.zip v1.0.0
+
+  # Pipe the shuffled files to another tool
+  avc export --seed 42 s3://bucket/mydataset@v1.0.0 | tar -t
+
+  # Export the WebDataset shards of at most 1G
+  avc export -f webdataset --max-shard-size 1G -o "shards/train-%06d.tar" v1.0.0`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var options avc.ExportOptions
+		var err error
+
+		output, err := cmd.Flags().GetString("output")
+		exitWithError(err)
+		if output == "-" {
+			output = ""
+		}
+
+		options.Format, err = cmd.Flags().GetString("format")
+		exitWithError(err)
+		if options.Format == "" {
+			options.Format = exportFormatOf(output)
+		}
+
+		options.Shuffle, err = cmd.Flags().GetBool("shuffle")
+		exitWithError(err)
+
+		if cmd.Flags().Changed("seed") {
+			options.Seed, err = cmd.Flags().GetInt64("seed")
+			exitWithError(err)
+			options.Shuffle = true
+		} else {
+			options.Seed = time.Now().UnixNano()
+		}
+
+		maxShardSize, err := cmd.Flags().GetString("max-shard-size")
+		exitWithError(err)
+		options.MaxShardSize, err = parseSize(maxShardSize)
+		exitWithError(err)
+
+		if options.MaxShardSize > 0 && !strings.Contains(output, "%") {
+			exitWithFormat("--max-shard-size requires the output pattern of the shards. e.g. \"train-%%06d.tar\"")
+		}
+
+		if output != "" {
+			options.BagName = strings.SplitN(filepath.Base(output), ".", 2)[0]
+		}
+
+		outputs := []string{}
+		create := func(shard int) (io.WriteCloser, error) {
+			if output == "" {
+				return nopWriteCloser{os.Stdout}, nil
+			}
+
+			name := output
+			if strings.Contains(output, "%") {
+				name = fmt.Sprintf(output, shard)
+			}
+			outputs = append(outputs, name)
+			return os.Create(name)
+		}
+
+		target := args[0]
+		var result *avc.ExportResult
+		if isRefStr(target) {
+			client, err := avc.Open("", avc.Options{})
+			if err == nil {
+				result, err = client.Export(cmd.Context(), target, options, create)
+				exitWithExportError(err, outputs)
+				printExportResult(result, output)
+				return
+			} else if !errors.Is(err, avc.ErrWorkspaceNotFound) {
+				exitWithError(err)
+			}
+		}
+
+		// the target is a repository
+		repoUrl, ref, err := parseRepoStr(target)
+		exitWithError(err)
+
+		result, err = avc.Export(cmd.Context(), repoUrl, ref, options, create, avc.Options{})
+		exitWithExportError(err, outputs)
+		printExportResult(result, output)
+	},
+}
+
+func init() {
+	exportCommand.Flags().StringP("format", "f", "", fmt.Sprintf("Archive format: %s", strings.Join([]string{
+		avc.ExportFormatTar,
+		avc.ExportFormatTarZstd,
+		avc.ExportFormatZip,
+		avc.ExportFormatBagIt,
+		avc.ExportFormatWebDataset,
+	}, ", ")))
+	exportCommand.Flags().StringP("output", "o", "", "Output file, or the pattern of the shard files. Write to stdout if it is empty or \"-\"")
+	exportCommand.Flags().Bool("shuffle", false, "Write the files in a random order")
+	exportCommand.Flags().Int64("seed", 0, "The random seed of the shuffle. It implies --shuffle")
+	exportCommand.Flags().String("max-shard-size", "", `The maximum size of a WebDataset shard. e.g. "500M" or "1G"`)
+}
+
+// exportFormatOf guesses the format by the extension of the output. It is tar by default.
+func exportFormatOf(output string) string {
+	switch {
+	case strings.HasSuffix(output, ".tar.zst"), strings.HasSuffix(output, ".tzst"):
+		return avc.ExportFormatTarZstd
+	case strings.HasSuffix(output, ".zip"):
+		return avc.ExportFormatZip
+	default:
+		return avc.ExportFormatTar
+	}
+}
+
+func printExportResult(result *avc.ExportResult, output string) {
+	if output == "" {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d files (%d bytes) of the commit %s to %d archive(s)\n", result.Files, result.Bytes, result.Commit[:8], result.Shards)
+}
+
+// exitWithExportError removes the partial output files before exiting with the error
+func exitWithExportError(err error, outputs []string) {
+	if err != nil {
+		for _, output := range outputs {
+			os.Remove(output)
+		}
+	}
+	exitWithError(err)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/infuseai/artivc/pkg/avc"
+	"github.com/spf13/cobra"
+)
+
+var fetchCommand = &cobra.Command{
+	Use:                   "fetch [--all]",
+	DisableFlagsInUseLine: true,
+	Short:                 "Download the metadata from the repository",
+	Long: `Download the metadata from the repository. The metadata is fetched on-demand by other commands,
+so it is not required to run this command before them. Use "--all" to prefetch all the commits.`,
+	Example: `  # Fetch the references
+  avc fetch
+
+  # Fetch the references and all the commits
+  avc fetch --all`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := avc.Open("", withRemote(cmd, avc.Options{}))
+		exitWithError(err)
+
+		all, err := cmd.Flags().GetBool("all")
+		exitWithError(err)
+
+		exitWithError(client.Fetch(cmd.Context(), all))
+	},
+}
+
+func init() {
+	fetchCommand.Flags().Bool("all", false, "Fetch all the commits")
+	addRemoteFlag(fetchCommand)
+}
@@ -6,7 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/pkg/avc"
 	"github.com/spf13/cobra"
 )
 
@@ -46,18 +46,8 @@ var getCmd = &cobra.Command{
 		baseDir, err = filepath.Abs(baseDir)
 		exitWithError(err)
 
-		metadataDir, _ := os.MkdirTemp(os.TempDir(), "*-avc")
-		defer os.RemoveAll(metadataDir)
-
-		config := core.NewConfig(baseDir, metadataDir, repoUrl)
-
-		mngr, err := core.NewArtifactManager(config)
-		exitWithError(err)
-
-		options := core.PullOptions{NoFetch: true}
-		if ref != "" {
-			options.RefOrCommit = &ref
-		}
+		options := avc.GetOptions{Options: transferOptions(cmd, os.Stdout)}
+		options.Ref = ref
 
 		options.Delete, err = cmd.Flags().GetBool("delete")
 		exitWithError(err)
@@ -66,16 +56,16 @@ var getCmd = &cobra.Command{
 			if options.Delete {
 				exitWithError(errors.New("cannot download partial files and specify delete flag at the same time"))
 			}
-			fileInclude := core.NewAvcInclude(args[1:])
-			options.FileFilter = func(path string) bool {
-				return fileInclude.MatchesPath(path)
-			}
+			options.Paths = args[1:]
 		}
-		exitWithError(mngr.Pull(options))
+
+		_, err = avc.Get(cmd.Context(), repoUrl, baseDir, options)
+		exitWithError(err)
 	},
 }
 
 func init() {
 	getCmd.Flags().StringP("output", "o", "", "Output directory")
 	getCmd.Flags().Bool("delete", false, "Delete extra files which are not listed in commit")
+	addTransferFlags(getCmd)
 }
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"os"
+	"sort"
+
+	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/pkg/avc"
+	"github.com/spf13/cobra"
+)
+
+var importCommand = &cobra.Command{
+	Use:                   "import [-m <message>] [--tag] [--strip-components <n>] [--repo <repository>] <archive>...",
+	DisableFlagsInUseLine: true,
+	Short:                 "Import archives as commits",
+	Long: `Import tar or zip archives as commits. Each archive becomes a commit on top of the latest commit, with the
+modification time of the archive as the commit time. The archives are imported in the order of their modification
+times. The files are streamed from the archives to the repository without extracting them.
+
+The supported archives are .tar, .tar.gz, .tgz, .tar.zst, .tzst, .tar.bz2, .tbz2 and .zip.
+
+The archives are imported to the repository of the workspace, or to the repository of the --repo flag without a
+workspace.`,
+	Example: `  # Import the monthly snapshots and tag each commit with the archive name (e.g. "dataset-2021-03")
+  avc import --tag dataset-2021-*.tar.gz
+
+  # Import the archive whose files are in the top-level directory
+  avc import --strip-components 1 -m "initial version" dataset.zip
+
+  # Import to a repository without a workspace
+  avc import --repo s3://bucket/mydataset dataset-2021-03.tar.gz`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		printer := newOutputPrinter(cmd)
+
+		options := avc.ImportOptions{}
+		var err error
+		options.Message, err = cmd.Flags().GetString("message")
+		exitWithError(err)
+
+		tag, err := cmd.Flags().GetBool("tag")
+		exitWithError(err)
+
+		options.StripComponents, err = cmd.Flags().GetInt("strip-components")
+		exitWithError(err)
+
+		repo, err := cmd.Flags().GetString("repo")
+		exitWithError(err)
+
+		archives, err := sortArchivesByTime(args)
+		exitWithError(err)
+
+		importArchive := func(archive string, options avc.ImportOptions) (*avc.ImportResult, error) {
+			return avc.Import(cmd.Context(), repo, archive, options, transferOptions(cmd, printer.output()))
+		}
+		if repo == "" {
+			client, err := avc.Open("", transferOptions(cmd, printer.output()))
+			exitWithError(err)
+			importArchive = func(archive string, options avc.ImportOptions) (*avc.ImportResult, error) {
+				return client.Import(cmd.Context(), archive, options)
+			}
+		}
+
+		results := []*avc.ImportResult{}
+		for _, archive := range archives {
+			if tag {
+				options.Tag = core.ArchiveName(archive)
+			}
+
+			result, err := importArchive(archive, options)
+			exitWithError(err)
+			results = append(results, result)
+		}
+
+		printer.print(results)
+	},
+}
+
+func init() {
+	importCommand.Flags().StringP("message", "m", "", `Commit message. It is "import <archive>" if it is empty`)
+	importCommand.Flags().Bool("tag", false, "Tag each commit with the archive name without the extension")
+	importCommand.Flags().Int("strip-components", 0, "Strip the leading components of the paths in the archives")
+	importCommand.Flags().String("repo", "", "Import to the repository without a workspace")
+	addTransferFlags(importCommand)
+	addOutputFlag(importCommand)
+}
+
+// sortArchivesByTime sorts the archives by the modification times. The archives of the same time keep the order.
+func sortArchivesByTime(archives []string) ([]string, error) {
+	infos := map[string]os.FileInfo{}
+	for _, archive := range archives {
+		info, err := os.Stat(archive)
+		if err != nil {
+			return nil, err
+		}
+		infos[archive] = info
+	}
+
+	sorted := append([]string{}, archives...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return infos[sorted[i]].ModTime().Before(infos[sorted[j]].ModTime())
+	})
+	return sorted, nil
+}
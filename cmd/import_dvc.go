@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"github.com/infuseai/artivc/pkg/avc"
+	"github.com/spf13/cobra"
+)
+
+var importDVCCommand = &cobra.Command{
+	Use:                   "import-dvc [--cache <dir>]... [--history] [-m <message>] [--tag <tag>] [--repo <repository>] [<dvc-project>]",
+	DisableFlagsInUseLine: true,
+	Short:                 "Import the data tracked by a DVC project as commits",
+	Long: `Import the data tracked by the ".dvc" and "dvc.lock" files of a DVC project. The outputs are read from the DVC
+cache of the project, or from the local cache or remote dirs of the --cache flags, so the data is not downloaded
+again. Run "dvc fetch" first if the data is not in the cache.
+
+By default, one commit is created from the DVC files in the working tree. With the --history flag, one commit is
+created for each git commit changing the DVC files, with the time and message of the git commit. Only the first
+parents of the merges are followed.
+
+The data is imported to the repository of the workspace, or to the repository of the --repo flag without a
+workspace. The project is the current directory if it is not specified.`,
+	Example: `  # Import the data of the DVC project in the current directory
+  avc import-dvc
+
+  # Import the history of the DVC project from a local DVC remote
+  avc import-dvc --history --cache /mnt/dvc-remote ../my-dvc-project
+
+  # Import to a repository without a workspace
+  avc import-dvc --repo s3://bucket/mydataset --tag v1.0.0 ../my-dvc-project`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		printer := newOutputPrinter(cmd)
+
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+
+		options := avc.ImportDVCOptions{}
+		var err error
+		options.CacheDirs, err = cmd.Flags().GetStringArray("cache")
+		exitWithError(err)
+
+		options.History, err = cmd.Flags().GetBool("history")
+		exitWithError(err)
+
+		options.Message, err = cmd.Flags().GetString("message")
+		exitWithError(err)
+
+		options.Tag, err = cmd.Flags().GetString("tag")
+		exitWithError(err)
+
+		repo, err := cmd.Flags().GetString("repo")
+		exitWithError(err)
+
+		var results []*avc.ImportResult
+		if repo != "" {
+			results, err = avc.ImportDVC(cmd.Context(), repo, dir, options, transferOptions(cmd, printer.output()))
+		} else {
+			var client *avc.Client
+			client, err = avc.Open("", transferOptions(cmd, printer.output()))
+			exitWithError(err)
+			results, err = client.ImportDVC(cmd.Context(), dir, options)
+		}
+		exitWithError(err)
+
+		printer.print(results)
+	},
+}
+
+func init() {
+	importDVCCommand.Flags().StringArray("cache", nil, "The DVC cache or local remote dir to read the data. It is the cache of the project by default")
+	importDVCCommand.Flags().Bool("history", false, "Create a commit for each git commit changing the DVC files")
+	importDVCCommand.Flags().StringP("message", "m", "", `Commit message of the working tree. It is "import <dvc-project>" if it is empty`)
+	importDVCCommand.Flags().String("tag", "", "Tag the last created commit")
+	importDVCCommand.Flags().String("repo", "", "Import to the repository without a workspace")
+	addTransferFlags(importDVCCommand)
+	addOutputFlag(importDVCCommand)
+}
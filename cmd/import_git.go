@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"github.com/infuseai/artivc/pkg/avc"
+	"github.com/spf13/cobra"
+)
+
+var importGitCommand = &cobra.Command{
+	Use:                   "import-git [--path <subdir>] [--ref <revision>] [--repo <repository>] <git-dir>",
+	DisableFlagsInUseLine: true,
+	Short:                 "Import the history of the files in a git repository as commits",
+	Long: `Import the history of the files in a git repository. One commit is created for each git commit changing the
+files, with the message, author and time of the git commit. Only the first parents of the merges are followed. The
+same contents are uploaded once, and the blobs already in the repository are skipped.
+
+With the --path flag, only the files under the subdir are imported, and the paths are relative to the subdir.
+
+The history is imported to the repository of the workspace, or to the repository of the --repo flag without a
+workspace. It requires the git command.`,
+	Example: `  # Import the history of the data dir
+  avc import-git --path data ../my-project
+
+  # Import to a repository without a workspace
+  avc import-git --repo s3://bucket/mydataset --ref v1.0.0 ../my-dataset`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		printer := newOutputPrinter(cmd)
+
+		options := avc.ImportGitOptions{}
+		var err error
+		options.Path, err = cmd.Flags().GetString("path")
+		exitWithError(err)
+
+		options.Ref, err = cmd.Flags().GetString("ref")
+		exitWithError(err)
+
+		repo, err := cmd.Flags().GetString("repo")
+		exitWithError(err)
+
+		var results []*avc.ImportResult
+		if repo != "" {
+			results, err = avc.ImportGit(cmd.Context(), repo, args[0], options, transferOptions(cmd, printer.output()))
+		} else {
+			var client *avc.Client
+			client, err = avc.Open("", transferOptions(cmd, printer.output()))
+			exitWithError(err)
+			results, err = client.ImportGit(cmd.Context(), args[0], options)
+		}
+		exitWithError(err)
+
+		printer.print(results)
+	},
+}
+
+func init() {
+	importGitCommand.Flags().String("path", "", "Import the files under the subdir of the git repository")
+	importGitCommand.Flags().String("ref", "HEAD", "The git revision to import the history of")
+	importGitCommand.Flags().String("repo", "", "Import to the repository without a workspace")
+	addTransferFlags(importGitCommand)
+	addOutputFlag(importGitCommand)
+}
@@ -1,13 +1,9 @@
 package cmd
 
 import (
-	"errors"
-	"fmt"
 	"os"
-	"strings"
 
-	"github.com/infuseai/artivc/internal/core"
-	"github.com/infuseai/artivc/internal/repository"
+	"github.com/infuseai/artivc/pkg/avc"
 	"github.com/spf13/cobra"
 )
 
@@ -25,19 +21,8 @@ var initCommand = &cobra.Command{
 		cwd, err := os.Getwd()
 		exitWithError(err)
 
-		result, err := repository.ParseRepo(args[0])
+		_, err = avc.Init(cwd, args[0], avc.Options{Output: os.Stdout})
 		exitWithError(err)
-		repo := result.Repo
-
-		if strings.HasPrefix(repo, "http") && !repository.IsAzureStorageUrl(repo) {
-			exitWithError(errors.New("init not support under http(s) repo"))
-		}
-
-		_, err = repository.NewRepository(result)
-		exitWithError(err)
-
-		fmt.Printf("Initialize the artivc workspace of the repository '%s'\n", repo)
-		exitWithError(core.InitWorkspace(cwd, repo))
 	},
 }
 
@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/pkg/avc"
 	"github.com/spf13/cobra"
 )
 
@@ -16,22 +16,23 @@ var listCommand = &cobra.Command{
   avc list v1.0.0`,
 	Args: cobra.RangeArgs(0, 1),
 	Run: func(cmd *cobra.Command, args []string) {
-		var ref string
-		if len(args) == 0 {
-			ref = core.RefLatest
-		} else {
+		printer := newOutputPrinter(cmd)
+
+		ref := avc.RefLatest
+		if len(args) > 0 {
 			ref = args[0]
 		}
 
-		config, err := core.LoadConfig("")
+		client, err := avc.Open("", avc.Options{Output: printer.output()})
 		exitWithError(err)
 
-		mngr, err := core.NewArtifactManager(config)
+		blobs, err := client.List(cmd.Context(), ref)
 		exitWithError(err)
 
-		exitWithError(mngr.List(ref))
+		printer.print(blobs)
 	},
 }
 
 func init() {
+	addOutputFlag(listCommand)
 }
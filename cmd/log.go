@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/pkg/avc"
 	"github.com/spf13/cobra"
 )
 
@@ -16,22 +16,24 @@ var logCommand = &cobra.Command{
   avc log v1.0.0`,
 	Args: cobra.RangeArgs(0, 1),
 	Run: func(cmd *cobra.Command, args []string) {
-		config, err := core.LoadConfig("")
-		exitWithError(err)
+		printer := newOutputPrinter(cmd)
 
-		var ref string
-		if len(args) == 0 {
-			ref = core.RefLatest
-		} else {
+		ref := avc.RefLatest
+		if len(args) > 0 {
 			ref = args[0]
 		}
 
-		mngr, err := core.NewArtifactManager(config)
+		client, err := avc.Open("", withRemote(cmd, avc.Options{Output: printer.output()}))
+		exitWithError(err)
+
+		entries, err := client.Log(cmd.Context(), ref)
 		exitWithError(err)
 
-		exitWithError(mngr.Log(ref))
+		printer.print(entries)
 	},
 }
 
 func init() {
+	addRemoteFlag(logCommand)
+	addOutputFlag(logCommand)
 }
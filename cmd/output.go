@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/infuseai/artivc/pkg/avc"
+	"github.com/spf13/cobra"
+)
+
+const (
+	OUTPUT_TEXT     = "text"
+	OUTPUT_JSON     = "json"
+	OUTPUT_TEMPLATE = "template="
+)
+
+// addOutputFlag adds the flag to select the output format
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().String("output", OUTPUT_TEXT, `Output format: "text", "json" or "template=<go template>". The template is applied to each item of a list`)
+}
+
+type outputPrinter struct {
+	w        io.Writer
+	format   string
+	template *template.Template
+}
+
+func newOutputPrinter(cmd *cobra.Command) *outputPrinter {
+	format, err := cmd.Flags().GetString("output")
+	exitWithError(err)
+
+	printer := &outputPrinter{w: os.Stdout, format: format}
+	switch {
+	case format == OUTPUT_TEXT || format == OUTPUT_JSON:
+	case strings.HasPrefix(format, OUTPUT_TEMPLATE):
+		printer.format = OUTPUT_TEMPLATE
+		printer.template, err = template.New("output").Funcs(template.FuncMap{
+			"json": func(v interface{}) (string, error) {
+				data, err := json.Marshal(v)
+				return string(data), err
+			},
+		}).Parse(strings.TrimPrefix(format, OUTPUT_TEMPLATE))
+		exitWithError(err)
+	default:
+		exitWithFormat("unsupported output format: %s", format)
+	}
+
+	return printer
+}
+
+func (p *outputPrinter) isText() bool {
+	return p.format == OUTPUT_TEXT
+}
+
+// output returns the writer of the text output. It is nil for the other formats.
+func (p *outputPrinter) output() io.Writer {
+	if p.format == OUTPUT_TEXT {
+		return p.w
+	}
+	return nil
+}
+
+// print renders the value in json or by the template. The text output is written by the client itself.
+func (p *outputPrinter) print(v interface{}) {
+	switch p.format {
+	case OUTPUT_JSON:
+		encoder := json.NewEncoder(p.w)
+		encoder.SetIndent("", "  ")
+		exitWithError(encoder.Encode(v))
+	case OUTPUT_TEMPLATE:
+		value := reflect.ValueOf(v)
+		if value.Kind() == reflect.Slice {
+			for i := 0; i < value.Len(); i++ {
+				p.executeTemplate(value.Index(i).Interface())
+			}
+		} else {
+			p.executeTemplate(v)
+		}
+	}
+}
+
+func (p *outputPrinter) executeTemplate(v interface{}) {
+	exitWithError(p.template.Execute(p.w, v))
+	fmt.Fprintln(p.w)
+}
+
+// addExitCodeFlag adds the flag to exit with 1 if there are differences
+func addExitCodeFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("exit-code", false, "Exit with 1 if there are differences and 0 means no differences")
+}
+
+func exitWithDiffCode(cmd *cobra.Command, result avc.DiffResult) {
+	exitCode, err := cmd.Flags().GetBool("exit-code")
+	exitWithError(err)
+
+	if exitCode && result.IsChanged() {
+		os.Exit(1)
+	}
+}
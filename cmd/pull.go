@@ -3,7 +3,7 @@ package cmd
 import (
 	"errors"
 
-	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/pkg/avc"
 	"github.com/spf13/cobra"
 )
 
@@ -21,14 +21,11 @@ var pullCmd = &cobra.Command{
   avc pull -- path/to/partia
   avc pull v0.1.0 -- path/to/partia ...`,
 	Run: func(cmd *cobra.Command, args []string) {
-		config, err := core.LoadConfig("")
-		exitWithError(err)
-
-		mngr, err := core.NewArtifactManager(config)
-		exitWithError(err)
+		printer := newOutputPrinter(cmd)
 
 		// options
-		option := core.PullOptions{}
+		option := avc.PullOptions{}
+		var err error
 
 		option.DryRun, err = cmd.Flags().GetBool("dry-run")
 		exitWithError(err)
@@ -39,32 +36,32 @@ var pullCmd = &cobra.Command{
 		argsLenBeforeDash := cmd.Flags().ArgsLenAtDash()
 		if argsLenBeforeDash == -1 {
 			if len(args) == 1 {
-				option.RefOrCommit = &args[0]
+				option.Ref = args[0]
 			} else if len(args) > 1 {
 				exitWithError(errors.New("please specify \"--\" flag teminator"))
 			}
 		} else {
 			if argsLenBeforeDash == 1 {
-				option.RefOrCommit = &args[0]
+				option.Ref = args[0]
 			}
 
-			if len(args)-argsLenBeforeDash > 0 {
-				if option.Delete {
-					exitWithError(errors.New("cannot pull partial files and specify delete flag at the same time"))
-				}
-
-				fileInclude := core.NewAvcInclude(args[argsLenBeforeDash:])
-				option.FileFilter = func(path string) bool {
-					return fileInclude.MatchesPath(path)
-				}
-			}
+			option.Paths = args[argsLenBeforeDash:]
 		}
 
-		exitWithError(mngr.Pull(option))
+		client, err := avc.Open("", withRemote(cmd, transferOptions(cmd, printer.output())))
+		exitWithError(err)
+
+		result, err := client.Pull(cmd.Context(), option)
+		exitWithError(err)
+
+		printer.print(result)
 	},
 }
 
 func init() {
 	pullCmd.Flags().Bool("dry-run", false, "Dry run")
 	pullCmd.Flags().Bool("delete", false, "Delete extra files which are not listed in commit")
+	addRemoteFlag(pullCmd)
+	addTransferFlags(pullCmd)
+	addOutputFlag(pullCmd)
 }
@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/pkg/avc"
 	"github.com/spf13/cobra"
 )
 
@@ -19,30 +19,36 @@ var pushCmd = &cobra.Command{
   avc tag v1.0.0`,
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		config, err := core.LoadConfig("")
-		exitWithError(err)
+		printer := newOutputPrinter(cmd)
 
 		// options
-		option := core.PushOptions{}
-		message, err := cmd.Flags().GetString("message")
+		option := avc.PushOptions{}
+		var err error
+		option.Message, err = cmd.Flags().GetString("message")
 		exitWithError(err)
 
-		if message != "" {
-			option.Message = &message
-		}
-
 		option.DryRun, err = cmd.Flags().GetBool("dry-run")
 		exitWithError(err)
 
+		option.Index, err = cmd.Flags().GetBool("index")
+		exitWithError(err)
+
 		// push
-		mngr, err := core.NewArtifactManager(config)
+		client, err := avc.Open("", withRemote(cmd, transferOptions(cmd, printer.output())))
+		exitWithError(err)
+
+		result, err := client.Push(cmd.Context(), option)
 		exitWithError(err)
 
-		exitWithError(mngr.Push(option))
+		printer.print(result)
 	},
 }
 
 func init() {
 	pushCmd.Flags().StringP("message", "m", "", "Commit meessage")
 	pushCmd.Flags().Bool("dry-run", false, "Dry run")
+	pushCmd.Flags().Bool("index", false, `Write the static listing index for the plain HTTP mirrors. It is always written if "repo.index" is set`)
+	addRemoteFlag(pushCmd)
+	addTransferFlags(pushCmd)
+	addOutputFlag(pushCmd)
 }
@@ -1,10 +1,9 @@
 package cmd
 
 import (
-	"os"
 	"path/filepath"
 
-	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/pkg/avc"
 	"github.com/spf13/cobra"
 )
 
@@ -25,32 +24,27 @@ var putCmd = &cobra.Command{
 		repoUrl, ref, err := parseRepoStr(args[1])
 		exitWithError(err)
 
+		printer := newOutputPrinter(cmd)
+
 		// options
-		option := core.PushOptions{}
-		message, err := cmd.Flags().GetString("message")
+		options := avc.PutOptions{Options: transferOptions(cmd, printer.output())}
+		options.Message, err = cmd.Flags().GetString("message")
+		exitWithError(err)
+		options.Tag = ref
+		options.Index, err = cmd.Flags().GetBool("index")
 		exitWithError(err)
 
-		if message != "" {
-			option.Message = &message
-		}
-		if ref != "" {
-			option.Tag = &ref
-		}
-
-		// Create temp metadata
-		metadataDir, _ := os.MkdirTemp(os.TempDir(), "*-avc")
-		defer os.RemoveAll(metadataDir)
-
-		config := core.NewConfig(baseDir, metadataDir, repoUrl)
-
-		// push
-		mngr, err := core.NewArtifactManager(config)
+		// put
+		result, err := avc.Put(cmd.Context(), baseDir, repoUrl, options)
 		exitWithError(err)
 
-		exitWithError(mngr.Push(option))
+		printer.print(result)
 	},
 }
 
 func init() {
 	putCmd.Flags().StringP("message", "m", "", "Commit meessage")
+	putCmd.Flags().Bool("index", false, "Write the static listing index for the plain HTTP mirrors")
+	addTransferFlags(putCmd)
+	addOutputFlag(putCmd)
 }
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/internal/repository"
+	"github.com/spf13/cobra"
+)
+
+var remoteCommand = &cobra.Command{
+	Use:   "remote",
+	Short: "Manage the named remotes",
+	Long: `Manage the named remotes of the workspace. The remotes are stored in the "[remote.<name>]" sections of
+".avc/config", and the metadata of each remote is kept in ".avc/remotes/<name>".
+
+The default remote is used by the commands without the --remote flag. If there is no default remote, the
+repository of "repo.url" is used.`,
+	Example: `  # Add a remote and make it the default
+  avc remote add --default team s3://team-bucket/mydataset
+
+  # Add another remote and push to it
+  avc remote add partner s3://partner-bucket/mydataset
+  avc push --remote partner
+
+  # List the remotes
+  avc remote list`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		remoteListCommand.Run(cmd, args)
+	},
+}
+
+var remoteAddCommand = &cobra.Command{
+	Use:                   "add [--default] <name> <repository>",
+	DisableFlagsInUseLine: true,
+	Short:                 "Add a named remote",
+	Args:                  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := core.LoadConfig("")
+		exitWithError(err)
+
+		result, err := repository.ParseRepo(args[1])
+		exitWithError(err)
+
+		repoConfig, err := config.RepositoryConfig()
+		exitWithError(err)
+
+		_, err = repository.NewRepository(result, repoConfig)
+		exitWithError(err)
+
+		exitWithError(config.AddRemote(args[0], result.Repo))
+
+		isDefault, err := cmd.Flags().GetBool("default")
+		exitWithError(err)
+		if isDefault {
+			exitWithError(config.SetDefaultRemote(args[0]))
+		}
+
+		exitWithError(config.Save())
+	},
+}
+
+var remoteRemoveCommand = &cobra.Command{
+	Use:                   "remove <name>",
+	DisableFlagsInUseLine: true,
+	Aliases:               []string{"rm"},
+	Short:                 "Remove a named remote and its metadata",
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := core.LoadConfig("")
+		exitWithError(err)
+
+		exitWithError(config.RemoveRemote(args[0]))
+		exitWithError(config.Save())
+		exitWithError(os.RemoveAll(config.RemoteMetadataDir(args[0])))
+	},
+}
+
+var remoteRenameCommand = &cobra.Command{
+	Use:                   "rename <old> <new>",
+	DisableFlagsInUseLine: true,
+	Short:                 "Rename a named remote",
+	Args:                  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := core.LoadConfig("")
+		exitWithError(err)
+
+		exitWithError(config.RenameRemote(args[0], args[1]))
+		exitWithError(config.Save())
+
+		err = os.Rename(config.RemoteMetadataDir(args[0]), config.RemoteMetadataDir(args[1]))
+		if err != nil && !os.IsNotExist(err) {
+			exitWithError(err)
+		}
+	},
+}
+
+var remoteListCommand = &cobra.Command{
+	Use:                   "list",
+	DisableFlagsInUseLine: true,
+	Aliases:               []string{"ls"},
+	Short:                 "List the named remotes. The default remote is marked with \"*\"",
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		printer := newOutputPrinter(cmd)
+
+		config, err := core.LoadConfig("")
+		exitWithError(err)
+
+		remotes := config.Remotes()
+		if printer.isText() {
+			for _, remote := range remotes {
+				mark := " "
+				if remote.Default {
+					mark = "*"
+				}
+				fmt.Fprintf(printer.w, "%s %s\t%s\n", mark, remote.Name, remote.Url)
+			}
+		}
+
+		printer.print(remotes)
+	},
+}
+
+var remoteShowCommand = &cobra.Command{
+	Use:                   "show [<name>]",
+	DisableFlagsInUseLine: true,
+	Short:                 "Show a named remote. The default remote is shown if the name is not specified",
+	Args:                  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		printer := newOutputPrinter(cmd)
+
+		config, err := core.LoadConfig("")
+		exitWithError(err)
+
+		remote := core.Remote{Url: config.RepoUrl(), Default: true}
+		if len(args) == 1 {
+			remote, err = config.GetRemote(args[0])
+			exitWithError(err)
+		} else if config.Remote() != "" {
+			remote.Name = config.Remote()
+		}
+
+		if printer.isText() {
+			name := remote.Name
+			if name == "" {
+				name = "(repo.url)"
+			}
+			fmt.Fprintf(printer.w, "name: %s\n", name)
+			fmt.Fprintf(printer.w, "url: %s\n", remote.Url)
+			fmt.Fprintf(printer.w, "default: %v\n", remote.Default)
+		}
+
+		printer.print(remote)
+	},
+}
+
+var remoteSetDefaultCommand = &cobra.Command{
+	Use:                   "set-default [<name>]",
+	DisableFlagsInUseLine: true,
+	Short:                 "Set the default remote. Without the name, the repository of \"repo.url\" becomes the default",
+	Args:                  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := core.LoadConfig("")
+		exitWithError(err)
+
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		exitWithError(config.SetDefaultRemote(name))
+		exitWithError(config.Save())
+	},
+}
+
+func init() {
+	remoteAddCommand.Flags().Bool("default", false, "Make the remote the default")
+	addOutputFlag(remoteListCommand)
+	addOutputFlag(remoteShowCommand)
+	addOutputFlag(remoteCommand)
+
+	remoteCommand.AddCommand(
+		remoteAddCommand,
+		remoteRemoveCommand,
+		remoteRenameCommand,
+		remoteListCommand,
+		remoteShowCommand,
+		remoteSetDefaultCommand,
+	)
+}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/infuseai/artivc/internal/log"
 	"github.com/spf13/cobra"
@@ -38,7 +41,15 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	// cancel the running operations on SIGINT or SIGTERM. A second signal terminates the process immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
@@ -59,13 +70,22 @@ func init() {
 		initCommand,
 		cloneCommand,
 		configCommand,
+		remoteCommand,
 		statusCommand,
 		pullCmd,
 		pushCmd,
+		fetchCommand,
 		tagCommand,
 		listCommand,
+		catCommand,
+		exportCommand,
 		logCommand,
 		diffCommand,
+		importCommand,
+		importDVCCommand,
+		importGitCommand,
+		syncCommand,
+		serveCommand,
 	)
 
 	addCommandWithGroup("",
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/internal/repository"
+	"github.com/infuseai/artivc/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var serveCommand = &cobra.Command{
+	Use:                   "serve [--addr <address>] [--token <token>] [<repository>]",
+	DisableFlagsInUseLine: true,
+	Short:                 "Serve a repository over HTTP",
+	Long: `Serve a repository over HTTP. The repository of the workspace is served if the repository is not specified.
+
+The clients read the repository without authentication. The writes (push, tag, ...) require the token, which is
+sent by the clients from the "http.token" config or the AVC_HTTP_TOKEN environment variable. The server is
+read-only if the token is not set.`,
+	Example: `  # Serve a local repository with the token from the environment variable
+  export AVC_SERVE_TOKEN=mysecret
+  avc serve /path/to/repo
+
+  # Serve a S3 repository on the port 9000
+  avc serve --addr :9000 --token mysecret s3://bucket/mydataset
+
+  # Use the served repository
+  export AVC_HTTP_TOKEN=mysecret
+  avc clone http://myserver:9000/ mydataset`,
+	Args: cobra.RangeArgs(0, 1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var config core.ArtConfig
+		var err error
+		if len(args) == 0 {
+			config, err = core.LoadConfig("")
+			exitWithError(err)
+		} else {
+			config = core.NewConfig("", "", args[0])
+		}
+
+		result, err := repository.ParseRepo(config.RepoUrl())
+		exitWithError(err)
+
+		repoConfig, err := config.RepositoryConfig()
+		exitWithError(err)
+
+		repo, err := repository.NewRepository(result, repoConfig)
+		exitWithError(err)
+
+		addr, err := cmd.Flags().GetString("addr")
+		exitWithError(err)
+
+		options := server.Options{}
+		options.Token, err = cmd.Flags().GetString("token")
+		exitWithError(err)
+		if options.Token == "" {
+			options.Token = os.Getenv("AVC_SERVE_TOKEN")
+		}
+
+		options.AuthRead, err = cmd.Flags().GetBool("auth-read")
+		exitWithError(err)
+		if options.AuthRead && options.Token == "" {
+			exitWithFormat("--auth-read requires the token")
+		}
+
+		if options.Token == "" {
+			fmt.Fprintln(os.Stderr, "no token is set. the repository is read-only")
+		}
+
+		exitWithError(serve(cmd.Context(), addr, server.New(repo, options), result.Repo))
+	},
+}
+
+func init() {
+	serveCommand.Flags().String("addr", ":8080", "The address to listen on")
+	serveCommand.Flags().String("token", "", "The bearer token required for the writes. The AVC_SERVE_TOKEN environment variable is used if it is empty")
+	serveCommand.Flags().Bool("auth-read", false, "Require the token for the reads as well")
+}
+
+// serve runs the http server until the context is done
+func serve(ctx context.Context, addr string, handler http.Handler, repo string) error {
+	srv := &http.Server{Addr: addr, Handler: handler}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(os.Stderr, "serve the repository '%s' on %s\n", repo, addr)
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		<-done
+		return nil
+	}
+
+	return err
+}
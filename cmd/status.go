@@ -3,7 +3,7 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/pkg/avc"
 	"github.com/spf13/cobra"
 )
 
@@ -15,22 +15,25 @@ var statusCommand = &cobra.Command{
 	avc status`,
 	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		config, err := core.LoadConfig("")
-		exitWithError(err)
+		printer := newOutputPrinter(cmd)
 
-		mngr, err := core.NewArtifactManager(config)
+		client, err := avc.Open("", withRemote(cmd, avc.Options{Output: printer.output()}))
 		exitWithError(err)
 
-		exitWithError(mngr.Fetch())
-
-		fmt.Printf("workspace of the repository '%s'\n\n", config.RepoUrl())
+		if printer.isText() {
+			fmt.Printf("workspace of the repository '%s'\n\n", client.RepoUrl())
+		}
 
-		result, err := mngr.Status()
+		result, err := client.Status(cmd.Context())
 		exitWithError(err)
 
-		result.Print(true)
+		printer.print(result)
+		exitWithDiffCode(cmd, result)
 	},
 }
 
 func init() {
+	addRemoteFlag(statusCommand)
+	addOutputFlag(statusCommand)
+	addExitCodeFlag(statusCommand)
 }
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"github.com/infuseai/artivc/pkg/avc"
+	"github.com/spf13/cobra"
+)
+
+var syncCommand = &cobra.Command{
+	Use:                   "sync [--dry-run] [--delete] [--verify] [--index] <source> <destination>",
+	DisableFlagsInUseLine: true,
+	Short:                 "Mirror a repository to another repository",
+	Long: `Mirror a repository to another repository, which can be of any backend. The latest reference, the tags, the
+commits not in the destination, and the missing objects of them are copied in parallel. The objects are copied
+before the commits, and the commits before the references, so an interrupted sync can be run again.
+
+The content of each object is verified against its hash while it is copied. Use --verify to download the copied
+objects from the destination and verify them again.
+
+Use --index to write the static listing index to the destination, e.g. a directory served by a static web server.
+The existing index of the destination is always refreshed.`,
+	Example: `  # Mirror a repository to GCS
+  avc sync s3://bucket/mydataset gs://bucket/mydataset
+
+  # Show the changes without copying
+  avc sync --dry-run s3://bucket/mydataset host:/backup/mydataset
+
+  # Delete the tags which are deleted in the source
+  avc sync --delete s3://bucket/mydataset gs://bucket/mydataset
+
+  # Mirror to a directory served by nginx
+  avc sync --index s3://bucket/mydataset /var/www/mydataset`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		printer := newOutputPrinter(cmd)
+
+		options := avc.SyncOptions{}
+		var err error
+		options.DryRun, err = cmd.Flags().GetBool("dry-run")
+		exitWithError(err)
+
+		options.Delete, err = cmd.Flags().GetBool("delete")
+		exitWithError(err)
+
+		options.Verify, err = cmd.Flags().GetBool("verify")
+		exitWithError(err)
+
+		options.Index, err = cmd.Flags().GetBool("index")
+		exitWithError(err)
+
+		result, err := avc.Sync(cmd.Context(), args[0], args[1], options, transferOptions(cmd, printer.output()))
+		exitWithError(err)
+
+		printer.print(result)
+	},
+}
+
+func init() {
+	syncCommand.Flags().Bool("dry-run", false, "Show the changes without copying")
+	syncCommand.Flags().Bool("delete", false, "Delete the tags in the destination which are not in the source")
+	syncCommand.Flags().Bool("verify", false, "Download the copied objects from the destination and verify the hashes")
+	syncCommand.Flags().Bool("index", false, "Write the static listing index to the destination for the plain HTTP mirrors")
+	addTransferFlags(syncCommand)
+	addOutputFlag(syncCommand)
+}
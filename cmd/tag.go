@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/pkg/avc"
 	"github.com/spf13/cobra"
 )
 
@@ -22,14 +22,16 @@ var tagCommand = &cobra.Command{
   avc tag --delete v1.0.0`,
 	Args: cobra.RangeArgs(0, 2),
 	Run: func(cmd *cobra.Command, args []string) {
-		config, err := core.LoadConfig("")
-		exitWithError(err)
+		printer := newOutputPrinter(cmd)
 
-		mngr, err := core.NewArtifactManager(config)
+		client, err := avc.Open("", withRemote(cmd, avc.Options{Output: printer.output()}))
 		exitWithError(err)
 
 		if len(args) == 0 {
-			exitWithError(mngr.ListTags())
+			tags, err := client.Tags(cmd.Context())
+			exitWithError(err)
+
+			printer.print(tags)
 		} else if len(args) == 1 {
 			tag := args[0]
 			refOrCommit, err := cmd.Flags().GetString("ref")
@@ -38,9 +40,9 @@ var tagCommand = &cobra.Command{
 			exitWithError(err)
 
 			if !delete {
-				exitWithError(mngr.AddTag(refOrCommit, tag))
+				exitWithError(client.AddTag(cmd.Context(), refOrCommit, tag))
 			} else {
-				exitWithError(mngr.DeleteTag(tag))
+				exitWithError(client.DeleteTag(cmd.Context(), tag))
 			}
 		} else {
 			exitWithFormat("requires 0 or 1 argument\n")
@@ -50,5 +52,7 @@ var tagCommand = &cobra.Command{
 
 func init() {
 	tagCommand.Flags().BoolP("delete", "D", false, "Delete a tag")
-	tagCommand.Flags().String("ref", core.RefLatest, "The source commit or reference to be tagged")
+	tagCommand.Flags().String("ref", avc.RefLatest, "The source commit or reference to be tagged")
+	addRemoteFlag(tagCommand)
+	addOutputFlag(tagCommand)
 }
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"bytes"
 	"testing"
 
+	"github.com/infuseai/artivc/pkg/avc"
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,3 +33,128 @@ func TestTransformRepoUrl(t *testing.T) {
 		})
 	}
 }
+
+func TestParseRepoStr(t *testing.T) {
+	testCases := []struct {
+		desc string
+		in   string
+		repo string
+		ref  string
+		err  bool
+	}{
+		{desc: "s3 repo", in: "s3://bucket/ds", repo: "s3://bucket/ds"},
+		{desc: "s3 repo with ref", in: "s3://bucket/ds@v1", repo: "s3://bucket/ds", ref: "v1"},
+		{desc: "local repo with ref", in: "/data/ds@v1", repo: "/data/ds", ref: "v1"},
+		{desc: "ssh url with user and port", in: "ssh://user@host:2222/path", repo: "ssh://user@host:2222/path"},
+		{desc: "ssh url with user and ref", in: "ssh://user@host/path@v1", repo: "ssh://user@host/path", ref: "v1"},
+		{desc: "ssh url without path", in: "ssh://user@host", repo: "ssh://user@host"},
+		{desc: "scp-like with user", in: "user@host:path", repo: "user@host:path"},
+		{desc: "scp-like with user and ref", in: "user@host:path@v1", repo: "user@host:path", ref: "v1"},
+		{desc: "two refs", in: "s3://bucket/ds@v1@v2", err: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			repo, ref, err := parseRepoStr(tC.in)
+			if tC.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tC.repo, repo)
+			assert.Equal(t, tC.ref, ref)
+		})
+	}
+}
+
+func TestOutputPrinter(t *testing.T) {
+	tags := []avc.TagEntry{{Name: "v1", Commit: "a1"}, {Name: "v2", Commit: "b2"}}
+	testCases := []struct {
+		desc   string
+		format string
+		out    string
+	}{
+		{desc: "json", format: "json", out: "[\n  {\n    \"name\": \"v1\",\n    \"commit\": \"a1\"\n  },\n  {\n    \"name\": \"v2\",\n    \"commit\": \"b2\"\n  }\n]\n"},
+		{desc: "template", format: "template={{.Name}} {{.Commit}}", out: "v1 a1\nv2 b2\n"},
+		{desc: "template with json", format: "template={{json .}}", out: "{\"name\":\"v1\",\"commit\":\"a1\"}\n{\"name\":\"v2\",\"commit\":\"b2\"}\n"},
+		{desc: "text", format: "text", out: ""},
+	}
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			addOutputFlag(cmd)
+			assert.NoError(t, cmd.Flags().Set("output", tC.format))
+
+			var buf bytes.Buffer
+			printer := newOutputPrinter(cmd)
+			printer.w = &buf
+			printer.print(tags)
+			assert.Equal(t, tC.out, buf.String())
+			assert.Equal(t, tC.format == "text", printer.output() != nil)
+		})
+	}
+}
+
+func TestParseCatArg(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		in     string
+		target string
+		path   string
+		isRef  bool
+		err    bool
+	}{
+		{desc: "s3 repo", in: "s3://bucket/ds@v2:labels.csv", target: "s3://bucket/ds@v2", path: "labels.csv"},
+		{desc: "ssh repo", in: "host:/data/ds:a/b.txt", target: "host:/data/ds", path: "a/b.txt"},
+		{desc: "local repo", in: "/data/ds:a/b.txt", target: "/data/ds", path: "a/b.txt"},
+		{desc: "ref", in: "v1.0.0:a/b.txt", target: "v1.0.0", path: "a/b.txt", isRef: true},
+		{desc: "latest of workspace", in: ":a/b.txt", target: "", path: "a/b.txt", isRef: true},
+		{desc: "no path", in: "s3://bucket/ds", err: true},
+		{desc: "empty path", in: "v1.0.0:", err: true},
+		{desc: "no colon", in: "a/b.txt", err: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			target, path, err := parseCatArg(tC.in)
+			if tC.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tC.target, target)
+			assert.Equal(t, tC.path, path)
+			assert.Equal(t, tC.isRef, isRefStr(target))
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		in   string
+		size int64
+		err  bool
+	}{
+		{in: "", size: 0},
+		{in: "1024", size: 1024},
+		{in: "10b", size: 10},
+		{in: "1K", size: 1024},
+		{in: "1.5M", size: 1536 * 1024},
+		{in: "2g", size: 2 << 30},
+		{in: "-1", err: true},
+		{in: "1X", err: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.in, func(t *testing.T) {
+			size, err := parseSize(tC.in)
+			if tC.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tC.size, size)
+		})
+	}
+}
@@ -1,14 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	neturl "net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/infuseai/artivc/pkg/avc"
 	"github.com/spf13/cobra"
 )
 
@@ -18,6 +21,11 @@ const (
 )
 
 func exitWithError(err error) {
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, "Error: interrupted")
+		os.Exit(130)
+	}
+
 	cobra.CheckErr(err)
 }
 
@@ -25,12 +33,66 @@ func exitWithFormat(format string, a ...interface{}) {
 	cobra.CheckErr(fmt.Sprintf(format, a...))
 }
 
+// addTransferFlags adds the flags to tune the transfers
+func addTransferFlags(cmd *cobra.Command) {
+	cmd.Flags().String("bwlimit", "", `Bandwidth limit. e.g. "10M", "10M:1M" (upload:download) or "08:00,1M 18:00,off" (timetable)`)
+	cmd.Flags().StringP("jobs", "j", "", `Number of concurrent transfers, or "auto" to adjust by the throughput`)
+	cmd.Flags().String("progress", avc.ProgressFormatAuto, `Progress output: "auto", "tty", "plain", "json" or "none"`)
+}
+
+// addRemoteFlag adds the flag to select the named remote of the workspace
+func addRemoteFlag(cmd *cobra.Command) {
+	cmd.Flags().String("remote", "", "The named remote to operate. The default remote is used if it is empty")
+}
+
+// withRemote sets the remote of the options by the remote flag
+func withRemote(cmd *cobra.Command, options avc.Options) avc.Options {
+	remote, err := cmd.Flags().GetString("remote")
+	exitWithError(err)
+
+	options.Remote = remote
+	return options
+}
+
+// transferOptions creates the client options from the transfer flags. The progress is written to stderr.
+func transferOptions(cmd *cobra.Command, output io.Writer) avc.Options {
+	options := avc.Options{Output: output}
+
+	var err error
+	options.BandwidthLimit, err = cmd.Flags().GetString("bwlimit")
+	exitWithError(err)
+
+	options.Jobs, err = cmd.Flags().GetString("jobs")
+	exitWithError(err)
+
+	format, err := cmd.Flags().GetString("progress")
+	exitWithError(err)
+
+	options.Progress, err = avc.NewProgressReporter(format, os.Stderr)
+	exitWithError(err)
+
+	return options
+}
+
+// parseRepoStr splits the <repository>[@<ref>] argument. The "@" of the ref is in the path of the repository, so the
+// user of the ssh repository is not taken as the ref, e.g. ssh://user@host:2222/path@v1 or user@host:path@v1.
 func parseRepoStr(repoAndRef string) (repoUrl string, ref string, err error) {
-	comps := strings.Split(repoAndRef, "@")
+	// the start of the path
+	start := 0
+	if i := strings.Index(repoAndRef, "://"); i >= 0 {
+		start = len(repoAndRef)
+		if j := strings.Index(repoAndRef[i+3:], "/"); j >= 0 {
+			start = i + 3 + j
+		}
+	} else if i := strings.Index(repoAndRef, ":"); i >= 0 {
+		start = i + 1
+	}
+
+	comps := strings.Split(repoAndRef[start:], "@")
 	if len(comps) == 1 {
 		repoUrl = repoAndRef
 	} else if len(comps) == 2 {
-		repoUrl = comps[0]
+		repoUrl = repoAndRef[:start] + comps[0]
 		ref = comps[1]
 	} else {
 		err = errors.New("Invalid repository: " + repoAndRef)
@@ -55,13 +117,34 @@ func transformRepoUrl(base string, repo string) (string, error) {
 	return filepath.Abs(filepath.Join(base, url.Path))
 }
 
-func isDirEmpty(dir string) bool {
-	f, err := os.Open(dir)
-	if err != nil {
-		return false
+// parseSize parses the size in bytes with the optional suffix "K", "M", "G" or "T". It is 0 if the value is empty.
+func parseSize(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	switch strings.ToUpper(value[len(value)-1:]) {
+	case "B":
+		value = value[:len(value)-1]
+	case "K":
+		multiplier = 1 << 10
+		value = value[:len(value)-1]
+	case "M":
+		multiplier = 1 << 20
+		value = value[:len(value)-1]
+	case "G":
+		multiplier = 1 << 30
+		value = value[:len(value)-1]
+	case "T":
+		multiplier = 1 << 40
+		value = value[:len(value)-1]
+	}
+
+	size, err := strconv.ParseFloat(value, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size: %s", value)
 	}
-	defer f.Close()
 
-	_, err = f.Readdirnames(1)
-	return err == io.EOF
+	return int64(size * float64(multiplier)), nil
 }
@@ -7,9 +7,13 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/infuseai/artivc/internal/repository"
 	gitignore "github.com/sabhiram/go-gitignore"
 )
 
@@ -45,6 +49,8 @@ type ArtConfig struct {
 	config      map[string]interface{}
 	MetadataDir string
 	BaseDir     string
+	// the selected named remote. The "repo.url" is used if it is empty.
+	remote string
 }
 
 func NewConfig(baseDir, metadataDir, repoUrl string) ArtConfig {
@@ -92,7 +98,13 @@ func LoadConfig(dir string) (ArtConfig, error) {
 		}
 
 		if err == nil {
-			return ArtConfig{config: config, BaseDir: dir, MetadataDir: path.Join(dir, ".avc")}, nil
+			artConfig := ArtConfig{config: config, BaseDir: dir, MetadataDir: path.Join(dir, ".avc")}
+			if remote := artConfig.DefaultRemote(); remote != "" {
+				if err := artConfig.UseRemote(remote); err != nil {
+					return ArtConfig{}, err
+				}
+			}
+			return artConfig, nil
 		}
 
 		newDir := filepath.Dir(dir)
@@ -126,6 +138,22 @@ func (config *ArtConfig) Set(path string, value interface{}) {
 	}
 }
 
+// Unset removes the value of the path
+func (config *ArtConfig) Unset(path string) {
+	var obj map[string]interface{} = config.config
+
+	parts := strings.Split(path, ".")
+	for i, p := range parts {
+		if i == len(parts)-1 {
+			delete(obj, p)
+		} else if v, ok := obj[p].(map[string]interface{}); ok {
+			obj = v
+		} else {
+			return
+		}
+	}
+}
+
 func (config *ArtConfig) Get(path string) interface{} {
 	var obj interface{} = config.config
 	var val interface{} = nil
@@ -144,23 +172,241 @@ func (config *ArtConfig) Get(path string) interface{} {
 }
 
 func (config *ArtConfig) GetString(path string) string {
-	var value string
+	switch value := config.Get(path).(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
 
-	if config.Get(path) != nil {
-		value = config.Get(path).(string)
+// GetInt returns the integer value. The value can be an integer or a string.
+func (config *ArtConfig) GetInt(path string) (int, error) {
+	switch value := config.Get(path).(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return int(value), nil
+	case string:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer of %s: %s", path, value)
+		}
+		return i, nil
+	default:
+		return 0, fmt.Errorf("invalid integer of %s: %v", path, value)
 	}
+}
 
-	return value
+// GetBool returns the boolean value. The value can be a boolean or a string.
+func (config *ArtConfig) GetBool(path string) (bool, error) {
+	switch value := config.Get(path).(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return value, nil
+	case string:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("invalid boolean of %s: %s", path, value)
+		}
+		return b, nil
+	default:
+		return false, fmt.Errorf("invalid boolean of %s: %v", path, value)
+	}
+}
+
+// GetDuration returns the duration value. The value is a duration string (e.g. "500ms", "1m")
+func (config *ArtConfig) GetDuration(path string) (time.Duration, error) {
+	switch value := config.Get(path).(type) {
+	case nil:
+		return 0, nil
+	case string:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration of %s: %s", path, value)
+		}
+		return d, nil
+	default:
+		return 0, fmt.Errorf("invalid duration of %s: %v", path, value)
+	}
 }
 
 func (config *ArtConfig) RepoUrl() string {
+	if config.remote != "" {
+		return config.GetString("remote." + config.remote + ".url")
+	}
 	return config.GetString("repo.url")
 }
 
+// RepoBackend returns the backend name of the repository
+func (config *ArtConfig) RepoBackend() string {
+	result, err := repository.ParseRepo(config.RepoUrl())
+	if err != nil {
+		return ""
+	}
+
+	return result.Backend()
+}
+
 func (config *ArtConfig) SetRepoUrl(repoUrl string) {
 	config.Set("repo.url", repoUrl)
 }
 
+// RepositoryConfig returns the backend settings of the repository
+func (config *ArtConfig) RepositoryConfig() (repository.RepositoryConfig, error) {
+	var repoConfig repository.RepositoryConfig
+	var err error
+
+	if repoConfig.Retry.MaxAttempts, err = config.GetInt("retry.max-attempts"); err != nil {
+		return repoConfig, err
+	}
+
+	if repoConfig.Retry.InitialInterval, err = config.GetDuration("retry.initial-interval"); err != nil {
+		return repoConfig, err
+	}
+
+	if repoConfig.Retry.MaxInterval, err = config.GetDuration("retry.max-interval"); err != nil {
+		return repoConfig, err
+	}
+
+	repoConfig.Http.Token = config.getRepoString("http.token")
+	repoConfig.WebDAV.Username = config.getRepoString("webdav.username")
+	repoConfig.WebDAV.Password = config.getRepoString("webdav.password")
+	repoConfig.WebDAV.Token = config.getRepoString("webdav.token")
+
+	repoConfig.S3.Endpoint = config.getRepoString("s3.endpoint")
+	repoConfig.S3.Region = config.getRepoString("s3.region")
+	repoConfig.S3.Profile = config.getRepoString("s3.profile")
+	if repoConfig.S3.PathStyle, err = config.getRepoBool("s3.path-style"); err != nil {
+		return repoConfig, err
+	}
+	if repoConfig.S3.Anonymous, err = config.getRepoBool("s3.anonymous"); err != nil {
+		return repoConfig, err
+	}
+	repoConfig.S3.StorageClass = config.getRepoString("s3.storage-class")
+	repoConfig.S3.MetadataStorageClass = config.getRepoString("s3.metadata-storage-class")
+	repoConfig.S3.SSE = config.getRepoString("s3.sse")
+	repoConfig.S3.SSEKMSKeyID = config.getRepoString("s3.sse-kms-key-id")
+	repoConfig.S3.ACL = config.getRepoString("s3.acl")
+	repoConfig.S3.Tags = config.getRepoString("s3.tags")
+
+	repoConfig.GCS.CredentialsFile = config.getRepoString("gcs.credentials-file")
+	repoConfig.GCS.Endpoint = config.getRepoString("gcs.endpoint")
+	repoConfig.GCS.UserProject = config.getRepoString("gcs.user-project")
+	repoConfig.GCS.PredefinedACL = config.getRepoString("gcs.predefined-acl")
+	if repoConfig.GCS.Anonymous, err = config.getRepoBool("gcs.anonymous"); err != nil {
+		return repoConfig, err
+	}
+
+	repoConfig.Azure.SASToken = config.getRepoString("azure.sas-token")
+	repoConfig.Azure.ConnectionString = config.getRepoString("azure.connection-string")
+	repoConfig.Azure.AccountKey = config.getRepoString("azure.account-key")
+	repoConfig.Azure.Endpoint = config.getRepoString("azure.endpoint")
+
+	repoConfig.SSH.User = config.getRepoString("ssh.user")
+	if repoConfig.SSH.Port, err = config.getRepoInt("ssh.port"); err != nil {
+		return repoConfig, err
+	}
+	repoConfig.SSH.Password = config.getRepoString("ssh.password")
+	repoConfig.SSH.IdentityFile = config.getRepoString("ssh.identity-file")
+	repoConfig.SSH.KeyPassphrase = config.getRepoString("ssh.key-passphrase")
+	repoConfig.SSH.StrictHostKeyChecking = config.getRepoString("ssh.strict-host-key-checking")
+	repoConfig.SSH.KnownHostsFile = config.getRepoString("ssh.known-hosts-file")
+	if repoConfig.SSH.ConnectTimeout, err = config.getRepoDuration("ssh.connect-timeout"); err != nil {
+		return repoConfig, err
+	}
+	if repoConfig.SSH.KeepaliveInterval, err = config.getRepoDuration("ssh.keepalive-interval"); err != nil {
+		return repoConfig, err
+	}
+
+	return repoConfig, nil
+}
+
+// getRepoString returns the backend setting of the repository. The setting of the selected remote (e.g.
+// "remote.<name>.http.token") takes precedence over the workspace one.
+func (config *ArtConfig) getRepoString(path string) string {
+	if config.remote != "" {
+		if value := config.GetString("remote." + config.remote + "." + path); value != "" {
+			return value
+		}
+	}
+	return config.GetString(path)
+}
+
+// getRepoBool is the boolean version of getRepoString
+func (config *ArtConfig) getRepoBool(path string) (bool, error) {
+	return config.GetBool(config.repoKey(path))
+}
+
+// getRepoInt is the integer version of getRepoString
+func (config *ArtConfig) getRepoInt(path string) (int, error) {
+	return config.GetInt(config.repoKey(path))
+}
+
+// getRepoDuration is the duration version of getRepoString
+func (config *ArtConfig) getRepoDuration(path string) (time.Duration, error) {
+	return config.GetDuration(config.repoKey(path))
+}
+
+// repoKey returns the key of the backend setting, which is the key of the selected remote if it is set
+func (config *ArtConfig) repoKey(path string) string {
+	if config.remote != "" && config.Get("remote."+config.remote+"."+path) != nil {
+		return "remote." + config.remote + "." + path
+	}
+	return path
+}
+
+// TransferConfig returns the transfer settings for the repository backend. The concurrency is resolved in the order of
+// "transfer.<backend>.concurrency", "transfer.concurrency" and the default of the backend.
+func (config *ArtConfig) TransferConfig(backend string) (TransferConfig, error) {
+	var transferConfig TransferConfig
+	var err error
+
+	for _, key := range []string{"transfer." + backend + ".concurrency", "transfer.concurrency"} {
+		if config.GetString(key) == "auto" {
+			transferConfig.Adaptive = true
+			continue
+		}
+
+		if transferConfig.Concurrency, err = config.GetInt(key); err != nil {
+			return transferConfig, err
+		}
+
+		if transferConfig.Concurrency > 0 {
+			break
+		}
+	}
+
+	if transferConfig.Concurrency <= 0 {
+		transferConfig.Concurrency = repository.DefaultConcurrency(backend)
+	}
+
+	if transferConfig.MaxConcurrency, err = config.GetInt("transfer.max-concurrency"); err != nil {
+		return transferConfig, err
+	}
+
+	if transferConfig.MaxConcurrency <= 0 {
+		transferConfig.MaxConcurrency = DefaultMaxConcurrency
+	}
+
+	if transferConfig.HashConcurrency, err = config.GetInt("transfer.hash-concurrency"); err != nil {
+		return transferConfig, err
+	}
+
+	if transferConfig.HashConcurrency <= 0 {
+		transferConfig.HashConcurrency = runtime.NumCPU()
+	}
+
+	if transferConfig.BandwidthLimit, err = repository.ParseBandwidthLimit(config.GetString("transfer.bwlimit")); err != nil {
+		return transferConfig, err
+	}
+
+	return transferConfig, nil
+}
+
 func (config *ArtConfig) Print() {
 	var printChild func(string, interface{})
 
@@ -182,7 +428,7 @@ func (config *ArtConfig) Print() {
 }
 
 func (config *ArtConfig) Save() error {
-	configPath := path.Join(config.MetadataDir, "config")
+	configPath := path.Join(config.BaseDir, ".avc/config")
 	f, err := os.OpenFile(configPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
 	if err != nil {
 		return err
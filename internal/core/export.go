@@ -0,0 +1,440 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/infuseai/artivc/internal/log"
+	"github.com/infuseai/artivc/internal/repository"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	ExportFormatTar        = "tar"
+	ExportFormatTarZstd    = "tar.zst"
+	ExportFormatZip        = "zip"
+	ExportFormatBagIt      = "bagit"
+	ExportFormatWebDataset = "webdataset"
+)
+
+// ExportFormats are the supported archive formats of the export
+var ExportFormats = []string{ExportFormatTar, ExportFormatTarZstd, ExportFormatZip, ExportFormatBagIt, ExportFormatWebDataset}
+
+type ExportOptions struct {
+	Format string
+	// Write the files in the random order of the seed instead of the commit order
+	Shuffle bool
+	Seed    int64
+	// The maximum size of a WebDataset shard in bytes. 0 to write a single shard.
+	MaxShardSize int64
+	// The top-level directory of the BagIt bag. The short commit hash is used if it is empty.
+	BagName string
+}
+
+type ExportResult struct {
+	Commit string `json:"commit"`
+	Files  int    `json:"files"`
+	Bytes  int64  `json:"bytes"`
+	Shards int    `json:"shards"`
+}
+
+// ShardWriterFunc creates the writer of the n-th archive, which starts from 0. Only the WebDataset format writes more
+// than one archive. The writer is closed after the archive is written.
+type ShardWriterFunc func(shard int) (io.WriteCloser, error)
+
+// Export streams the files of the commit into the archive. The files are written in the order of the commit blobs,
+// or shuffled if the shuffle option is set. The modes and symbolic links are preserved.
+func (mngr *ArtifactManager) Export(ctx context.Context, refOrCommit string, options ExportOptions, create ShardWriterFunc) (*ExportResult, error) {
+	if !isExportFormat(options.Format) {
+		return nil, fmt.Errorf("unsupported export format: %s", options.Format)
+	}
+
+	if options.MaxShardSize != 0 && options.Format != ExportFormatWebDataset {
+		return nil, fmt.Errorf("the max shard size is only supported by the %s format", ExportFormatWebDataset)
+	}
+
+	commitHash, err := mngr.FindCommitOrReference(ctx, refOrCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := mngr.GetCommit(ctx, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	session := repository.NewSession()
+	if !mngr.transfer.BandwidthLimit.Download.IsUnlimited() {
+		session.SetRateLimiter(repository.NewRateLimiter(mngr.transfer.BandwidthLimit.Download))
+	}
+
+	exporter := &exporter{
+		mngr:    mngr,
+		commit:  commit,
+		meter:   session.NewMeter(),
+		options: options,
+		result:  &ExportResult{Commit: commitHash},
+	}
+
+	if options.Format == ExportFormatWebDataset {
+		err = exporter.exportWebDataset(ctx, create)
+	} else {
+		err = exporter.exportArchive(ctx, commitHash, create)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return exporter.result, nil
+}
+
+func isExportFormat(format string) bool {
+	for _, f := range ExportFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+type exporter struct {
+	mngr    *ArtifactManager
+	commit  *Commit
+	meter   *repository.Meter
+	options ExportOptions
+	result  *ExportResult
+}
+
+// blobs returns the blobs in the export order
+func (e *exporter) blobs() []BlobMetaData {
+	blobs := append([]BlobMetaData{}, e.commit.Blobs...)
+	if e.options.Shuffle {
+		r := rand.New(rand.NewSource(e.options.Seed))
+		r.Shuffle(len(blobs), func(i, j int) { blobs[i], blobs[j] = blobs[j], blobs[i] })
+	}
+	return blobs
+}
+
+// download writes the content of the blob to the writer
+func (e *exporter) download(ctx context.Context, blob BlobMetaData, dest io.Writer) error {
+	log.Debugf("export: %s\n", blob.Path)
+	err := e.mngr.repo.DownloadStream(ctx, MakeObjectPath(blob.Hash), dest, e.meter)
+	if err != nil {
+		return fmt.Errorf("%s: %w", blob.Path, err)
+	}
+
+	e.result.Files++
+	e.result.Bytes += blob.Size
+	return nil
+}
+
+func (e *exporter) exportArchive(ctx context.Context, commitHash string, create ShardWriterFunc) error {
+	w, err := create(0)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	e.result.Shards = 1
+
+	switch e.options.Format {
+	case ExportFormatZip:
+		err = e.writeZip(ctx, w)
+	case ExportFormatTarZstd:
+		var zw *zstd.Encoder
+		zw, err = zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		if err = e.writeTar(ctx, zw, e.blobs()); err != nil {
+			zw.Close()
+			return err
+		}
+		err = zw.Close()
+	case ExportFormatBagIt:
+		bagName := e.options.BagName
+		if bagName == "" {
+			bagName = commitHash[:8]
+		}
+		err = e.writeBagIt(ctx, w, bagName)
+	default:
+		err = e.writeTar(ctx, w, e.blobs())
+	}
+	if err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+func (e *exporter) writeTar(ctx context.Context, w io.Writer, blobs []BlobMetaData) error {
+	tw := tar.NewWriter(w)
+	for _, blob := range blobs {
+		if err := e.writeTarEntry(ctx, tw, blob.Path, blob, nil); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// writeTarEntry writes the file or the symbolic link of the blob. The content is also written to the hasher if it is
+// not nil.
+func (e *exporter) writeTarEntry(ctx context.Context, tw *tar.Writer, name string, blob BlobMetaData, hasher io.Writer) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    int64(blob.Mode.Perm()),
+		ModTime: e.commit.CreatedAt,
+		Format:  tar.FormatPAX,
+	}
+
+	if blob.Link != "" {
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = blob.Link
+		header.Mode = 0777
+		return tw.WriteHeader(header)
+	}
+
+	header.Typeflag = tar.TypeReg
+	header.Size = blob.Size
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	var dest io.Writer = tw
+	if hasher != nil {
+		dest = io.MultiWriter(tw, hasher)
+	}
+	return e.download(ctx, blob, dest)
+}
+
+func (e *exporter) writeZip(ctx context.Context, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	for _, blob := range e.blobs() {
+		header := &zip.FileHeader{
+			Name:     blob.Path,
+			Method:   zip.Deflate,
+			Modified: e.commit.CreatedAt,
+		}
+
+		if blob.Link != "" {
+			// the target of the symbolic link is stored as the content
+			header.Method = zip.Store
+			header.SetMode(fs.ModeSymlink | 0777)
+			fw, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(fw, blob.Link); err != nil {
+				return err
+			}
+			continue
+		}
+
+		header.SetMode(blob.Mode)
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if err := e.download(ctx, blob, fw); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writeBagIt writes the bag (RFC 8493) in the tar serialization. The payload manifest lists the regular files. The
+// symbolic links are kept as links in the payload.
+func (e *exporter) writeBagIt(ctx context.Context, w io.Writer, bagName string) error {
+	tw := tar.NewWriter(w)
+	var manifest strings.Builder
+	var oxumBytes int64
+	var oxumFiles int
+
+	for _, blob := range e.blobs() {
+		name := path.Join("data", blob.Path)
+		if blob.Link != "" {
+			if err := e.writeTarEntry(ctx, tw, path.Join(bagName, name), blob, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		hasher := sha256.New()
+		if err := e.writeTarEntry(ctx, tw, path.Join(bagName, name), blob, hasher); err != nil {
+			return err
+		}
+		fmt.Fprintf(&manifest, "%s  %s\n", hex.EncodeToString(hasher.Sum(nil)), encodeBagItPath(name))
+		oxumBytes += blob.Size
+		oxumFiles++
+	}
+
+	var bagInfo strings.Builder
+	fmt.Fprintf(&bagInfo, "Bagging-Date: %s\n", time.Now().Format("2006-01-02"))
+	fmt.Fprintf(&bagInfo, "External-Identifier: %s\n", e.result.Commit)
+	if e.commit.Message != nil && *e.commit.Message != "" {
+		fmt.Fprintf(&bagInfo, "External-Description: %s\n", strings.ReplaceAll(*e.commit.Message, "\n", "\n  "))
+	}
+	fmt.Fprintf(&bagInfo, "Payload-Oxum: %d.%d\n", oxumBytes, oxumFiles)
+
+	tagFiles := []struct {
+		name    string
+		content string
+	}{
+		{name: "bagit.txt", content: "BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n"},
+		{name: "bag-info.txt", content: bagInfo.String()},
+		{name: "manifest-sha256.txt", content: manifest.String()},
+	}
+
+	var tagManifest strings.Builder
+	for _, tagFile := range tagFiles {
+		if err := writeTarContent(tw, path.Join(bagName, tagFile.name), tagFile.content, e.commit.CreatedAt); err != nil {
+			return err
+		}
+		fmt.Fprintf(&tagManifest, "%x  %s\n", sha256.Sum256([]byte(tagFile.content)), tagFile.name)
+	}
+
+	if err := writeTarContent(tw, path.Join(bagName, "tagmanifest-sha256.txt"), tagManifest.String(), e.commit.CreatedAt); err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// encodeBagItPath percent-encodes the characters not allowed in the manifest paths
+func encodeBagItPath(name string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(name)
+}
+
+func writeTarContent(tw *tar.Writer, name, content string, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(content)),
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(tw, content)
+	return err
+}
+
+// webDatasetSample is the files sharing the same key, which is the path without the extensions
+type webDatasetSample struct {
+	key   string
+	blobs []BlobMetaData
+	size  int64
+}
+
+// webDatasetSamples groups the blobs into the samples in the order of the first file of each sample
+func webDatasetSamples(blobs []BlobMetaData) []*webDatasetSample {
+	samples := []*webDatasetSample{}
+	sampleMap := map[string]*webDatasetSample{}
+	for _, blob := range blobs {
+		key := webDatasetKey(blob.Path)
+		sample, ok := sampleMap[key]
+		if !ok {
+			sample = &webDatasetSample{key: key}
+			sampleMap[key] = sample
+			samples = append(samples, sample)
+		}
+		sample.blobs = append(sample.blobs, blob)
+		sample.size += tarEntrySize(blob)
+	}
+	return samples
+}
+
+// webDatasetKey returns the path without the extensions. The extensions start from the first dot of the file name.
+func webDatasetKey(filePath string) string {
+	dir, name := path.Split(filePath)
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return dir + name
+}
+
+// tarEntrySize estimates the size of the blob in the tar archive. The PAX records of the long names are not counted.
+func tarEntrySize(blob BlobMetaData) int64 {
+	const blockSize = 512
+	if blob.Link != "" {
+		return blockSize
+	}
+	return blockSize + (blob.Size+blockSize-1)/blockSize*blockSize
+}
+
+// exportWebDataset writes the samples into the tar shards. The files of a sample are kept together in the same shard,
+// and the samples are shuffled as a whole.
+func (e *exporter) exportWebDataset(ctx context.Context, create ShardWriterFunc) error {
+	samples := webDatasetSamples(e.commit.Blobs)
+	if e.options.Shuffle {
+		r := rand.New(rand.NewSource(e.options.Seed))
+		r.Shuffle(len(samples), func(i, j int) { samples[i], samples[j] = samples[j], samples[i] })
+	}
+
+	var w io.WriteCloser
+	var tw *tar.Writer
+	var shardSize int64
+
+	closeShard := func() error {
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		return w.Close()
+	}
+
+	for _, sample := range samples {
+		if tw != nil && e.options.MaxShardSize > 0 && shardSize+sample.size > e.options.MaxShardSize {
+			if err := closeShard(); err != nil {
+				return err
+			}
+			tw = nil
+		}
+
+		if tw == nil {
+			var err error
+			w, err = create(e.result.Shards)
+			if err != nil {
+				return err
+			}
+			defer w.Close()
+
+			tw = tar.NewWriter(w)
+			e.result.Shards++
+			shardSize = 0
+		}
+
+		for _, blob := range sample.blobs {
+			if err := e.writeTarEntry(ctx, tw, blob.Path, blob, nil); err != nil {
+				return err
+			}
+		}
+		shardSize += sample.size
+	}
+
+	if tw == nil {
+		// write an empty shard for the empty commit
+		var err error
+		if w, err = create(0); err != nil {
+			return err
+		}
+		defer w.Close()
+
+		tw = tar.NewWriter(w)
+		e.result.Shards++
+	}
+
+	return closeShard()
+}
@@ -0,0 +1,259 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+type exportEntry struct {
+	name    string
+	content string
+	link    string
+	mode    fs.FileMode
+}
+
+// exportBuffers collects the archives written by the export
+type exportBuffers struct {
+	shards []*bytes.Buffer
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func (b *exportBuffers) create(shard int) (io.WriteCloser, error) {
+	if shard != len(b.shards) {
+		return nil, fmt.Errorf("unexpected shard %d", shard)
+	}
+	buf := &bytes.Buffer{}
+	b.shards = append(b.shards, buf)
+	return nopCloser{buf}, nil
+}
+
+func readTarEntries(t *testing.T, r io.Reader) []exportEntry {
+	entries := []exportEntry{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := io.ReadAll(tr)
+		entries = append(entries, exportEntry{name: header.Name, content: string(data), link: header.Linkname, mode: fs.FileMode(header.Mode).Perm()})
+	}
+	return entries
+}
+
+func newExportManager(t *testing.T) *ArtifactManager {
+	wp := t.TempDir()
+	repo := t.TempDir()
+
+	assert.NoError(t, writeFile([]byte("a"), filepath.Join(wp, "a.txt")))
+	assert.NoError(t, writeFile([]byte("0"), filepath.Join(wp, "samples/0.jpg")))
+	assert.NoError(t, writeFile([]byte("{}"), filepath.Join(wp, "samples/0.json")))
+	assert.NoError(t, writeFile([]byte("1"), filepath.Join(wp, "samples/1.jpg")))
+	assert.NoError(t, writeFile([]byte("{1}"), filepath.Join(wp, "samples/1.json")))
+	assert.NoError(t, writeFile([]byte("#!/bin/sh"), filepath.Join(wp, "run.sh")))
+	assert.NoError(t, os.Chmod(filepath.Join(wp, "run.sh"), 0755))
+	assert.NoError(t, symlinkFile("a.txt", filepath.Join(wp, "link")))
+
+	assert.NoError(t, InitWorkspace(wp, repo))
+	config, _ := LoadConfig(wp)
+	mngr, err := NewArtifactManager(config)
+	assert.NoError(t, err)
+
+	message := "first"
+	result, err := mngr.Push(context.Background(), PushOptions{Message: &message})
+	assert.NoError(t, err)
+
+	// the blobs of the pushed commit are in the order of hashing. sort them for the order of the export.
+	commit, err := mngr.GetCommit(context.Background(), result.Commit)
+	assert.NoError(t, err)
+	sort.Slice(commit.Blobs, func(i, j int) bool {
+		return commit.Blobs[i].Path < commit.Blobs[j].Path
+	})
+	assert.NoError(t, mngr.Commit(context.Background(), *commit))
+	_, hash := MakeCommitMetadata(commit)
+	assert.NoError(t, mngr.AddRef(context.Background(), RefLatest, hash))
+	return mngr
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	mngr := newExportManager(t)
+
+	expected := []exportEntry{
+		{name: "a.txt", content: "a", mode: 0644},
+		{name: "link", link: "a.txt", mode: 0777},
+		{name: "run.sh", content: "#!/bin/sh", mode: 0755},
+		{name: "samples/0.jpg", content: "0", mode: 0644},
+		{name: "samples/0.json", content: "{}", mode: 0644},
+		{name: "samples/1.jpg", content: "1", mode: 0644},
+		{name: "samples/1.json", content: "{1}", mode: 0644},
+	}
+
+	t.Run("tar", func(t *testing.T) {
+		var buffers exportBuffers
+		result, err := mngr.Export(ctx, RefLatest, ExportOptions{Format: ExportFormatTar}, buffers.create)
+		assert.NoError(t, err)
+		assert.Equal(t, 6, result.Files)
+		assert.Equal(t, 1, result.Shards)
+		assert.Equal(t, expected, readTarEntries(t, buffers.shards[0]))
+	})
+
+	t.Run("tar.zst", func(t *testing.T) {
+		var buffers exportBuffers
+		_, err := mngr.Export(ctx, RefLatest, ExportOptions{Format: ExportFormatTarZstd}, buffers.create)
+		assert.NoError(t, err)
+
+		zr, err := zstd.NewReader(buffers.shards[0])
+		assert.NoError(t, err)
+		defer zr.Close()
+		assert.Equal(t, expected, readTarEntries(t, zr))
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		var buffers exportBuffers
+		_, err := mngr.Export(ctx, RefLatest, ExportOptions{Format: ExportFormatZip}, buffers.create)
+		assert.NoError(t, err)
+
+		data := buffers.shards[0].Bytes()
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		assert.NoError(t, err)
+
+		entries := []exportEntry{}
+		for _, f := range zr.File {
+			r, _ := f.Open()
+			content, _ := io.ReadAll(r)
+			r.Close()
+
+			entry := exportEntry{name: f.Name, content: string(content), mode: f.Mode().Perm()}
+			if f.Mode()&fs.ModeSymlink != 0 {
+				entry.link, entry.content = entry.content, ""
+			}
+			entries = append(entries, entry)
+		}
+		assert.Equal(t, expected, entries)
+	})
+
+	t.Run("shuffle", func(t *testing.T) {
+		var buffers1, buffers2 exportBuffers
+		_, err := mngr.Export(ctx, RefLatest, ExportOptions{Format: ExportFormatTar, Shuffle: true, Seed: 42}, buffers1.create)
+		assert.NoError(t, err)
+		_, err = mngr.Export(ctx, RefLatest, ExportOptions{Format: ExportFormatTar, Shuffle: true, Seed: 42}, buffers2.create)
+		assert.NoError(t, err)
+
+		entries := readTarEntries(t, buffers1.shards[0])
+		assert.ElementsMatch(t, expected, entries)
+		assert.NotEqual(t, expected, entries)
+		assert.Equal(t, entries, readTarEntries(t, buffers2.shards[0]))
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		var buffers exportBuffers
+		_, err := mngr.Export(ctx, RefLatest, ExportOptions{Format: "rar"}, buffers.create)
+		assert.Error(t, err)
+	})
+}
+
+func TestExportBagIt(t *testing.T) {
+	ctx := context.Background()
+	mngr := newExportManager(t)
+
+	var buffers exportBuffers
+	_, err := mngr.Export(ctx, RefLatest, ExportOptions{Format: ExportFormatBagIt, BagName: "mybag"}, buffers.create)
+	assert.NoError(t, err)
+
+	files := map[string]exportEntry{}
+	for _, entry := range readTarEntries(t, buffers.shards[0]) {
+		files[entry.name] = entry
+	}
+
+	assert.Equal(t, "a.txt", files["mybag/data/link"].link)
+	assert.Equal(t, fs.FileMode(0755), files["mybag/data/run.sh"].mode)
+	assert.Equal(t, "BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n", files["mybag/bagit.txt"].content)
+	assert.Contains(t, files["mybag/bag-info.txt"].content, "Payload-Oxum: 17.6\n")
+	assert.Contains(t, files["mybag/bag-info.txt"].content, "External-Description: first\n")
+
+	// verify the manifests
+	for _, manifest := range []string{"mybag/manifest-sha256.txt", "mybag/tagmanifest-sha256.txt"} {
+		lines := strings.Split(strings.TrimSpace(files[manifest].content), "\n")
+		if manifest == "mybag/manifest-sha256.txt" {
+			assert.Len(t, lines, 6)
+		}
+		for _, line := range lines {
+			comps := strings.SplitN(line, "  ", 2)
+			entry, ok := files["mybag/"+comps[1]]
+			assert.True(t, ok, comps[1])
+			assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte(entry.content))), comps[0], comps[1])
+		}
+	}
+}
+
+func TestExportWebDataset(t *testing.T) {
+	ctx := context.Background()
+	mngr := newExportManager(t)
+
+	var buffers exportBuffers
+	result, err := mngr.Export(ctx, RefLatest, ExportOptions{Format: ExportFormatWebDataset, MaxShardSize: 3072}, buffers.create)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Shards)
+
+	shards := [][]string{}
+	for _, buf := range buffers.shards {
+		names := []string{}
+		for _, entry := range readTarEntries(t, buf) {
+			names = append(names, entry.name)
+		}
+		shards = append(shards, names)
+	}
+
+	// the files of a sample are in the same shard
+	assert.Equal(t, [][]string{
+		{"a.txt", "link", "run.sh"},
+		{"samples/0.jpg", "samples/0.json"},
+		{"samples/1.jpg", "samples/1.json"},
+	}, shards)
+
+	_, err = mngr.Export(ctx, RefLatest, ExportOptions{Format: ExportFormatTar, MaxShardSize: 2048}, buffers.create)
+	assert.Error(t, err)
+}
+
+func TestWebDatasetKey(t *testing.T) {
+	testCases := []struct {
+		in  string
+		out string
+	}{
+		{in: "a.jpg", out: "a"},
+		{in: "dir/a.seg.png", out: "dir/a"},
+		{in: "dir.v1/a", out: "dir.v1/a"},
+		{in: "dir/.hidden", out: "dir/.hidden"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.in, func(t *testing.T) {
+			assert.Equal(t, tC.out, webDatasetKey(tC.in))
+		})
+	}
+}
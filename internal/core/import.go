@@ -0,0 +1,512 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/infuseai/artivc/internal/executor"
+	"github.com/infuseai/artivc/internal/log"
+	"github.com/infuseai/artivc/internal/repository"
+	"github.com/klauspost/compress/zstd"
+)
+
+type ImportOptions struct {
+	Message *string
+	// The author of the commit, e.g. "name <email>"
+	Author *string
+	// Tag the created commit
+	Tag *string
+	// The time of the commit. The modification time of the archive is used if it is zero.
+	CreatedAt time.Time
+	// Strip the leading components of the paths in the archive, like "tar --strip-components"
+	StripComponents int
+}
+
+type ImportResult struct {
+	// The imported archive or directory
+	Source string `json:"source"`
+	// The created commit
+	Commit   string           `json:"commit"`
+	Tag      string           `json:"tag,omitempty"`
+	Files    int              `json:"files"`
+	Transfer *TransferSummary `json:"transfer,omitempty"`
+}
+
+// IsArchive checks if the file is an archive supported by the import
+func IsArchive(name string) bool {
+	return archiveFormatOf(name) != ""
+}
+
+// ArchiveName returns the file name of the archive without the archive extensions
+func ArchiveName(archivePath string) string {
+	name := filepath.Base(archivePath)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(strings.ToLower(name), ext.ext) {
+			return name[:len(name)-len(ext.ext)]
+		}
+	}
+	return name
+}
+
+// ImportFile is a file of the commit created by ImportFiles. The hash of a regular file is required.
+type ImportFile struct {
+	BlobMetaData
+	// Open opens the content of the regular file to upload
+	Open func() (io.ReadCloser, error)
+}
+
+// Import creates a commit from the tar or zip archive. The parent is the latest commit. The files are hashed in the
+// first pass over the archive, and the new blobs are streamed from the archive to the repository in the second pass.
+// The archive is not extracted to the disk.
+func (mngr *ArtifactManager) Import(ctx context.Context, archivePath string, options ImportOptions) (*ImportResult, error) {
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsArchive(archivePath) {
+		return nil, fmt.Errorf("unsupported archive: %s", archivePath)
+	}
+
+	if options.CreatedAt.IsZero() {
+		options.CreatedAt = info.ModTime()
+	}
+
+	if options.Message == nil {
+		message := "import " + filepath.Base(archivePath)
+		options.Message = &message
+	}
+
+	// Step 1: hash the files
+	log.Debugln("hash the files of " + archivePath)
+	mngr.report(ProgressEvent{Type: ProgressScanStart, Path: archivePath})
+	blobs, hashes, err := hashArchive(archivePath, options.StripComponents)
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 2: stream the new blobs in the order of the archive
+	uploadTasks := func(pending map[string]bool, tracker *transferTracker) []executor.TaskFunc {
+		task := func(ctx context.Context) error {
+			return walkArchive(archivePath, options.StripComponents, func(i int, entry archiveEntry, r io.Reader) error {
+				hash := hashes[i]
+				if !pending[hash] {
+					return nil
+				}
+
+				if err := mngr.uploadImportBlob(ctx, tracker, entry.path, hash, entry.size, r); err != nil {
+					return err
+				}
+
+				delete(pending, hash)
+				return nil
+			})
+		}
+		return []executor.TaskFunc{task}
+	}
+
+	return mngr.importBlobs(ctx, archivePath, blobs, options, uploadTasks)
+}
+
+// ImportFiles creates a commit of the files. The parent is the latest commit. The blobs not in the repository are
+// uploaded from the opened files. The source is the name of the imported data in the result.
+func (mngr *ArtifactManager) ImportFiles(ctx context.Context, source string, files []ImportFile, options ImportOptions) (*ImportResult, error) {
+	if options.CreatedAt.IsZero() {
+		options.CreatedAt = time.Now()
+	}
+
+	blobs := []BlobMetaData{}
+	sources := map[string]ImportFile{}
+	for _, file := range files {
+		if file.Link == "" && file.Hash == "" {
+			return nil, fmt.Errorf("%s: no hash of the file", file.Path)
+		}
+
+		blobs = append(blobs, file.BlobMetaData)
+		if file.Hash != "" {
+			sources[file.Hash] = file
+		}
+	}
+
+	uploadTasks := func(pending map[string]bool, tracker *transferTracker) []executor.TaskFunc {
+		tasks := []executor.TaskFunc{}
+		for hash := range pending {
+			file := sources[hash]
+			tasks = append(tasks, func(ctx context.Context) error {
+				r, err := file.Open()
+				if err != nil {
+					return fmt.Errorf("%s: %w", file.Path, err)
+				}
+				defer r.Close()
+
+				return mngr.uploadImportBlob(ctx, tracker, file.Path, file.Hash, file.Size, r)
+			})
+		}
+		return tasks
+	}
+
+	return mngr.importBlobs(ctx, source, blobs, options, uploadTasks)
+}
+
+// importBlobs uploads the blobs not in the repository by the upload tasks, and commits the blobs on top of the latest
+// commit. The pending blobs are given to the upload tasks by the hash.
+func (mngr *ArtifactManager) importBlobs(ctx context.Context, source string, blobs []BlobMetaData, options ImportOptions, uploadTasks func(pending map[string]bool, tracker *transferTracker) []executor.TaskFunc) (*ImportResult, error) {
+	// only the empty repository has no parent, or the history would be orphaned by a failed read
+	parent, err := mngr.GetRef(ctx, RefLatest)
+	if repository.IsNotExist(err) {
+		parent = ""
+	} else if err != nil {
+		return nil, err
+	}
+
+	log.Debugln("upload the blobs")
+	session := repository.NewSession()
+	if !mngr.transfer.BandwidthLimit.Upload.IsUnlimited() {
+		session.SetRateLimiter(repository.NewRateLimiter(mngr.transfer.BandwidthLimit.Upload))
+	}
+	tracker := newTransferTracker(mngr, "upload", session)
+
+	// the blobs not in the repository yet
+	pending := map[string]bool{}
+	mutex := sync.Mutex{}
+	statTasks := []executor.TaskFunc{}
+	for _, blob := range blobs {
+		if blob.Hash == "" || pending[blob.Hash] {
+			continue
+		}
+		pending[blob.Hash] = true
+
+		if parent == "" {
+			tracker.add(blob.Size)
+			continue
+		}
+
+		b := blob
+		statTasks = append(statTasks, func(ctx context.Context) error {
+			_, err := mngr.repo.Stat(ctx, MakeObjectPath(b.Hash))
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err == nil {
+				log.Debugf("skip: %s\n", b.Path)
+				delete(pending, b.Hash)
+			} else {
+				tracker.add(b.Size)
+			}
+			return nil
+		})
+	}
+
+	if err := executor.ExecuteAllWithContext(ctx, mngr.transfer.Concurrency, statTasks...); err != nil {
+		return nil, err
+	}
+
+	if err := mngr.executeTransfers(ctx, tracker, uploadTasks(pending, tracker)); err != nil {
+		return nil, err
+	}
+
+	commit := Commit{
+		CreatedAt: options.CreatedAt,
+		Parent:    parent,
+		Message:   options.Message,
+		Author:    options.Author,
+		Blobs:     blobs,
+	}
+
+	_, hash := MakeCommitMetadata(&commit)
+	if err := mngr.Commit(ctx, commit); err != nil {
+		return nil, err
+	}
+
+	if err := mngr.AddRef(ctx, RefLatest, hash); err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{
+		Source:   source,
+		Commit:   hash,
+		Files:    len(blobs),
+		Transfer: tracker.summary(),
+	}
+
+	if options.Tag != nil {
+		if err := mngr.addTag(ctx, hash, *options.Tag); err != nil {
+			return nil, err
+		}
+		result.Tag = *options.Tag
+	}
+
+	if err := mngr.updateIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (mngr *ArtifactManager) uploadImportBlob(ctx context.Context, tracker *transferTracker, path, hash string, size int64, r io.Reader) error {
+	tracker.start(path, hash, size)
+	meter := tracker.session.NewMeter()
+	if err := mngr.repo.UploadStream(ctx, r, MakeObjectPath(hash), meter); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	meter.SetBytes(size)
+	tracker.finish(path, hash, size, false)
+	return nil
+}
+
+// hashArchive makes the blobs of the archive. The hashes are returned by the index of the entries, so that the
+// second pass can find the hash of an entry without hashing it again.
+func hashArchive(archivePath string, stripComponents int) ([]BlobMetaData, map[int]string, error) {
+	blobs := []BlobMetaData{}
+	indexes := map[string]int{}
+	hashes := map[int]string{}
+
+	add := func(blob BlobMetaData) {
+		if i, ok := indexes[blob.Path]; ok {
+			// the later entry overwrites the earlier one, like the extraction
+			blobs[i] = blob
+			return
+		}
+		indexes[blob.Path] = len(blobs)
+		blobs = append(blobs, blob)
+	}
+
+	err := walkArchive(archivePath, stripComponents, func(i int, entry archiveEntry, r io.Reader) error {
+		switch entry.typ {
+		case archiveEntrySymlink:
+			add(BlobMetaData{Path: entry.path, Link: entry.link})
+		case archiveEntryHardlink:
+			target, ok := indexes[entry.link]
+			if !ok || blobs[target].Hash == "" {
+				return fmt.Errorf("%s: the hard link target %s is not found", entry.path, entry.link)
+			}
+			blob := blobs[target]
+			add(BlobMetaData{Path: entry.path, Hash: blob.Hash, Mode: entry.mode, Size: blob.Size})
+		default:
+			hasher := sha1.New()
+			size, err := io.Copy(hasher, r)
+			if err != nil {
+				return fmt.Errorf("%s: %w", entry.path, err)
+			}
+
+			hash := fmt.Sprintf("%x", hasher.Sum(nil))
+			hashes[i] = hash
+			add(BlobMetaData{Path: entry.path, Hash: hash, Mode: entry.mode, Size: size})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return blobs, hashes, nil
+}
+
+const (
+	archiveEntryFile = iota
+	archiveEntrySymlink
+	archiveEntryHardlink
+)
+
+type archiveEntry struct {
+	path string
+	typ  int
+	// the target of the symbolic link or hard link
+	link string
+	mode fs.FileMode
+	size int64
+}
+
+var archiveExtensions = []struct {
+	ext    string
+	format string
+}{
+	{ext: ".tar", format: "tar"},
+	{ext: ".tar.gz", format: "tar.gz"},
+	{ext: ".tgz", format: "tar.gz"},
+	{ext: ".tar.zst", format: "tar.zst"},
+	{ext: ".tzst", format: "tar.zst"},
+	{ext: ".tar.bz2", format: "tar.bz2"},
+	{ext: ".tbz2", format: "tar.bz2"},
+	{ext: ".zip", format: "zip"},
+}
+
+func archiveFormatOf(name string) string {
+	name = strings.ToLower(name)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(name, ext.ext) {
+			return ext.format
+		}
+	}
+	return ""
+}
+
+// walkArchive calls the function for each file or link of the archive in order. The reader is the content of the
+// regular file. The directories and other file types are skipped.
+func walkArchive(archivePath string, stripComponents int, fn func(i int, entry archiveEntry, r io.Reader) error) error {
+	if archiveFormatOf(archivePath) == "zip" {
+		return walkZip(archivePath, stripComponents, fn)
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch archiveFormatOf(archivePath) {
+	case "tar.gz":
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	case "tar.zst":
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	case "tar.bz2":
+		r = bzip2.NewReader(f)
+	}
+
+	tr := tar.NewReader(r)
+	for i := 0; ; i++ {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		entry := archiveEntry{mode: fs.FileMode(header.Mode).Perm(), size: header.Size}
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			entry.typ = archiveEntryFile
+		case tar.TypeSymlink:
+			entry.typ = archiveEntrySymlink
+			entry.link = header.Linkname
+			entry.mode = 0
+		case tar.TypeLink:
+			entry.typ = archiveEntryHardlink
+			entry.link, _, err = archiveEntryPath(header.Linkname, stripComponents)
+			if err != nil {
+				return err
+			}
+		default:
+			if header.Typeflag != tar.TypeDir {
+				log.Debugf("skip the unsupported file type: %s\n", header.Name)
+			}
+			continue
+		}
+
+		var ok bool
+		entry.path, ok, err = archiveEntryPath(header.Name, stripComponents)
+		if err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+
+		if err := fn(i, entry, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(archivePath string, stripComponents int, fn func(i int, entry archiveEntry, r io.Reader) error) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for i, f := range zr.File {
+		mode := f.Mode()
+		if mode.IsDir() {
+			continue
+		}
+
+		entryPath, ok, err := archiveEntryPath(f.Name, stripComponents)
+		if err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+
+		if err := walkZipFile(i, f, entryPath, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkZipFile(i int, f *zip.File, entryPath string, fn func(i int, entry archiveEntry, r io.Reader) error) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	mode := f.Mode()
+	entry := archiveEntry{path: entryPath, typ: archiveEntryFile, mode: mode.Perm(), size: int64(f.UncompressedSize64)}
+	if mode&fs.ModeSymlink != 0 {
+		// the content is the target of the symbolic link
+		link, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		entry = archiveEntry{path: entryPath, typ: archiveEntrySymlink, link: string(link)}
+	} else if !mode.IsRegular() {
+		log.Debugf("skip the unsupported file type: %s\n", f.Name)
+		return nil
+	}
+
+	return fn(i, entry, r)
+}
+
+// archiveEntryPath cleans the path of the archive entry and strips the leading components. It is false if nothing
+// is left after the strip.
+func archiveEntryPath(name string, stripComponents int) (string, bool, error) {
+	name = filepath.ToSlash(name)
+	for _, comp := range strings.Split(name, "/") {
+		if comp == ".." {
+			return "", false, errors.New("invalid path in the archive: " + name)
+		}
+	}
+
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		return "", false, nil
+	}
+
+	comps := strings.SplitN(name, "/", stripComponents+1)
+	if len(comps) <= stripComponents {
+		return "", false, nil
+	}
+
+	entryPath := comps[stripComponents]
+	if entryPath == ".avc" || strings.HasPrefix(entryPath, ".avc/") {
+		return "", false, nil
+	}
+
+	return entryPath, true, nil
+}
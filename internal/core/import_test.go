@@ -0,0 +1,228 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type archiveFixture struct {
+	name     string
+	content  string
+	link     string
+	hardlink bool
+	mode     int64
+}
+
+func writeTarGzFixture(t *testing.T, archivePath string, entries []archiveFixture) {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: entry.mode, Typeflag: tar.TypeReg, Size: int64(len(entry.content))}
+		switch {
+		case entry.hardlink:
+			header.Typeflag, header.Linkname, header.Size = tar.TypeLink, entry.link, 0
+		case entry.link != "":
+			header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, entry.link, 0
+		case entry.content == "" && entry.name[len(entry.name)-1] == '/':
+			header.Typeflag = tar.TypeDir
+		}
+		assert.NoError(t, tw.WriteHeader(header))
+		_, err := io.WriteString(tw, entry.content)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gw.Close())
+}
+
+func writeZipFixture(t *testing.T, archivePath string, entries []archiveFixture) {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		content := entry.content
+		if entry.link != "" {
+			header.SetMode(fs.ModeSymlink | 0777)
+			content = entry.link
+		} else {
+			header.SetMode(fs.FileMode(entry.mode))
+		}
+		w, err := zw.CreateHeader(header)
+		assert.NoError(t, err)
+		_, err = io.WriteString(w, content)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	archiveDir := t.TempDir()
+	metadataDir := t.TempDir()
+	repo := t.TempDir()
+
+	archive1 := filepath.Join(archiveDir, "dataset-2021-03.tar.gz")
+	writeTarGzFixture(t, archive1, []archiveFixture{
+		{name: "dataset/", mode: 0755},
+		{name: "dataset/a.txt", content: "a", mode: 0644},
+		{name: "dataset/run.sh", content: "#!/bin/sh", mode: 0755},
+		{name: "dataset/link", link: "a.txt"},
+		{name: "dataset/hardlink", link: "dataset/a.txt", hardlink: true, mode: 0644},
+	})
+	time1 := time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, os.Chtimes(archive1, time1, time1))
+
+	archive2 := filepath.Join(archiveDir, "dataset-2021-04.zip")
+	writeZipFixture(t, archive2, []archiveFixture{
+		{name: "dataset/a.txt", content: "a", mode: 0644},
+		{name: "dataset/b.txt", content: "b", mode: 0600},
+		{name: "dataset/link", link: "b.txt"},
+	})
+
+	mngr, err := NewArtifactManager(NewConfig(metadataDir, metadataDir, repo))
+	assert.NoError(t, err)
+
+	// the first archive
+	tag := ArchiveName(archive1)
+	assert.Equal(t, "dataset-2021-03", tag)
+	result1, err := mngr.Import(ctx, archive1, ImportOptions{Tag: &tag, StripComponents: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result1.Files)
+	assert.Equal(t, 2, result1.Transfer.Objects)
+
+	commit1, err := mngr.GetCommit(ctx, result1.Commit)
+	assert.NoError(t, err)
+	assert.True(t, time1.Equal(commit1.CreatedAt))
+	assert.Equal(t, "import dataset-2021-03.tar.gz", *commit1.Message)
+	assert.Equal(t, []BlobMetaData{
+		{Path: "a.txt", Hash: Sha1Sum([]byte("a")), Mode: 0644, Size: 1},
+		{Path: "run.sh", Hash: Sha1Sum([]byte("#!/bin/sh")), Mode: 0755, Size: 9},
+		{Path: "link", Link: "a.txt"},
+		{Path: "hardlink", Hash: Sha1Sum([]byte("a")), Mode: 0644, Size: 1},
+	}, commit1.Blobs)
+
+	// the second archive. the existing blob is skipped.
+	result2, err := mngr.Import(ctx, archive2, ImportOptions{StripComponents: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result2.Transfer.Objects)
+
+	commit2, err := mngr.GetCommit(ctx, result2.Commit)
+	assert.NoError(t, err)
+	assert.Equal(t, result1.Commit, commit2.Parent)
+	assert.Equal(t, []BlobMetaData{
+		{Path: "a.txt", Hash: Sha1Sum([]byte("a")), Mode: 0644, Size: 1},
+		{Path: "b.txt", Hash: Sha1Sum([]byte("b")), Mode: 0600, Size: 1},
+		{Path: "link", Link: "b.txt"},
+	}, commit2.Blobs)
+
+	tags, err := mngr.ListTags(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []TagEntry{{Name: tag, Commit: result1.Commit}}, tags)
+
+	// pull the imported commit
+	wp := t.TempDir()
+	assert.NoError(t, InitWorkspace(wp, repo))
+	config, _ := LoadConfig(wp)
+	mngr2, _ := NewArtifactManager(config)
+	_, err = mngr2.Pull(ctx, PullOptions{RefOrCommit: &tag})
+	assert.NoError(t, err)
+
+	data, _ := readFile(filepath.Join(wp, "hardlink"))
+	assert.Equal(t, "a", string(data))
+	link, _ := readlinkFile(filepath.Join(wp, "link"))
+	assert.Equal(t, "a.txt", link)
+	info, _ := os.Stat(filepath.Join(wp, "run.sh"))
+	assert.Equal(t, fs.FileMode(0755), info.Mode().Perm())
+}
+
+func TestImportLatestError(t *testing.T) {
+	ctx := context.Background()
+	metadataDir := t.TempDir()
+	repo := t.TempDir()
+	archive := filepath.Join(t.TempDir(), "dataset.tar.gz")
+	writeTarGzFixture(t, archive, []archiveFixture{{name: "a.txt", content: "a", mode: 0644}})
+
+	mngr, err := NewArtifactManager(NewConfig(metadataDir, metadataDir, repo))
+	assert.NoError(t, err)
+	result, err := mngr.Import(ctx, archive, ImportOptions{})
+	assert.NoError(t, err)
+
+	// the history is not orphaned by the parentless commit if the latest cannot be read
+	mngr.repo = &failingRepository{mngr.repo, os.ErrPermission}
+	_, err = mngr.Import(ctx, archive, ImportOptions{})
+	assert.ErrorIs(t, err, os.ErrPermission)
+
+	data, err := readFile(filepath.Join(repo, "refs/latest"))
+	assert.NoError(t, err)
+	assert.Equal(t, result.Commit, string(data))
+}
+
+func TestImportInvalidPath(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "evil.tar.gz")
+	writeTarGzFixture(t, archive, []archiveFixture{
+		{name: "../evil", content: "evil", mode: 0644},
+	})
+
+	metadataDir := t.TempDir()
+	mngr, err := NewArtifactManager(NewConfig(metadataDir, metadataDir, t.TempDir()))
+	assert.NoError(t, err)
+
+	_, err = mngr.Import(context.Background(), archive, ImportOptions{})
+	assert.Error(t, err)
+
+	_, err = mngr.Import(context.Background(), filepath.Join(t.TempDir(), "data.rar"), ImportOptions{})
+	assert.Error(t, err)
+}
+
+func TestArchiveEntryPath(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		name  string
+		strip int
+		path  string
+		ok    bool
+		err   bool
+	}{
+		{desc: "file", name: "a/b.txt", path: "a/b.txt", ok: true},
+		{desc: "dot prefix", name: "./a/b.txt", path: "a/b.txt", ok: true},
+		{desc: "absolute", name: "/a/b.txt", path: "a/b.txt", ok: true},
+		{desc: "strip", name: "a/b.txt", strip: 1, path: "b.txt", ok: true},
+		{desc: "strip all", name: "a/b.txt", strip: 2},
+		{desc: "root", name: "./"},
+		{desc: "workspace metadata", name: ".avc/config"},
+		{desc: "parent", name: "a/../../b.txt", err: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			entryPath, ok, err := archiveEntryPath(tC.name, tC.strip)
+			if tC.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tC.ok, ok)
+			assert.Equal(t, tC.path, entryPath)
+		})
+	}
+}
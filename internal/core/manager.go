@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"io/ioutil"
 	"os"
@@ -15,7 +16,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/fatih/color"
 	"github.com/infuseai/artivc/internal/executor"
 	"github.com/infuseai/artivc/internal/log"
 	"github.com/infuseai/artivc/internal/repository"
@@ -31,6 +31,15 @@ type ArtifactManager struct {
 
 	// repository
 	repo repository.Repository
+
+	// the transfer settings
+	transfer TransferConfig
+
+	// the receiver of the progress events. nil to discard.
+	progress ProgressReporter
+
+	// write the static listing index when the references are changed
+	index bool
 }
 
 func NewArtifactManager(config ArtConfig) (*ArtifactManager, error) {
@@ -60,19 +69,49 @@ func NewArtifactManager(config ArtConfig) (*ArtifactManager, error) {
 	if err != nil {
 		return nil, err
 	}
-	repo, err := repository.NewRepository(result)
+	repoConfig, err := config.RepositoryConfig()
+	if err != nil {
+		return nil, err
+	}
+	repo, err := repository.NewRepository(result, repoConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer, err := config.TransferConfig(result.Backend())
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := config.GetBool("repo.index")
 	if err != nil {
 		return nil, err
 	}
 
-	return &ArtifactManager{baseDir: baseDir, repo: repo, metadataDir: metadataDir}, nil
+	return &ArtifactManager{baseDir: baseDir, repo: repo, metadataDir: metadataDir, transfer: transfer, index: index}, nil
 }
 
-func (mngr *ArtifactManager) UploadBlob(localPath, hash string, meter *repository.Meter, checkSkip bool) (BlobUploadResult, error) {
+// SetProgressReporter sets the receiver of the progress events
+func (mngr *ArtifactManager) SetProgressReporter(reporter ProgressReporter) {
+	mngr.progress = reporter
+}
+
+func (mngr *ArtifactManager) report(event ProgressEvent) {
+	if mngr.progress == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	mngr.progress.Report(event)
+}
+
+func (mngr *ArtifactManager) UploadBlob(ctx context.Context, localPath, hash string, meter *repository.Meter, checkSkip bool) (BlobUploadResult, error) {
 	repoPath := MakeObjectPath(hash)
 
 	if checkSkip {
-		_, err := mngr.repo.Stat(repoPath)
+		_, err := mngr.repo.Stat(ctx, repoPath)
 		if err == nil {
 			log.Debugf("skip: %s\n", repoPath)
 			return BlobUploadResult{Skip: true}, nil
@@ -80,17 +119,17 @@ func (mngr *ArtifactManager) UploadBlob(localPath, hash string, meter *repositor
 	}
 
 	blobPath := filepath.Join(mngr.baseDir, localPath)
-	err := mngr.Upload(blobPath, repoPath, meter)
+	err := mngr.Upload(ctx, blobPath, repoPath, meter)
 	return BlobUploadResult{Skip: false}, err
 }
 
-func (mngr *ArtifactManager) Upload(localPath, repoPath string, meter *repository.Meter) error {
+func (mngr *ArtifactManager) Upload(ctx context.Context, localPath, repoPath string, meter *repository.Meter) error {
 	log.Debugf("upload: %s -> %s\n", localPath, repoPath)
 
-	return mngr.repo.Upload(localPath, repoPath, meter)
+	return mngr.repo.Upload(ctx, localPath, repoPath, meter)
 }
 
-func (mngr *ArtifactManager) Download(repoPath, localPath, tmpDir string, meter *repository.Meter) error {
+func (mngr *ArtifactManager) Download(ctx context.Context, repoPath, localPath, tmpDir string, meter *repository.Meter) error {
 	log.Debugf("download: %s <- %s\n", localPath, repoPath)
 
 	// Copy from repo to tmp
@@ -108,7 +147,7 @@ func (mngr *ArtifactManager) Download(repoPath, localPath, tmpDir string, meter
 	tmpPath := tmp.Name()
 	defer os.Remove(tmpPath)
 
-	err = mngr.repo.Download(repoPath, tmpPath, meter)
+	err = mngr.repo.Download(ctx, repoPath, tmpPath, meter)
 	if err != nil {
 		return err
 	}
@@ -131,7 +170,7 @@ func (mngr *ArtifactManager) Download(repoPath, localPath, tmpDir string, meter
 	return nil
 }
 
-func (mngr *ArtifactManager) DownloadBlob(localPath, hash string, meter *repository.Meter) (BlobDownloadResult, error) {
+func (mngr *ArtifactManager) DownloadBlob(ctx context.Context, localPath, hash string, meter *repository.Meter) (BlobDownloadResult, error) {
 	blobPath := filepath.Join(mngr.baseDir, localPath)
 
 	err := mkdirsForFile(blobPath)
@@ -142,14 +181,14 @@ func (mngr *ArtifactManager) DownloadBlob(localPath, hash string, meter *reposit
 	repoPath := MakeObjectPath(hash)
 	tmpDir := path.Join(mngr.baseDir, ".avc", "tmp")
 
-	err = mngr.Download(repoPath, blobPath, tmpDir, meter)
+	err = mngr.Download(ctx, repoPath, blobPath, tmpDir, meter)
 	if err != nil {
 		return BlobDownloadResult{}, err
 	}
 	return BlobDownloadResult{Skip: false}, nil
 }
 
-func (mngr *ArtifactManager) Commit(commit Commit) error {
+func (mngr *ArtifactManager) Commit(ctx context.Context, commit Commit) error {
 	content, hash := MakeCommitMetadata(&commit)
 	commitPath := MakeCommitPath(hash)
 	localPath := path.Join(mngr.metadataDir, commitPath)
@@ -158,7 +197,7 @@ func (mngr *ArtifactManager) Commit(commit Commit) error {
 		return err
 	}
 
-	err = mngr.Upload(localPath, commitPath, nil)
+	err = mngr.Upload(ctx, localPath, commitPath, nil)
 	if err != nil {
 		return err
 	}
@@ -166,7 +205,7 @@ func (mngr *ArtifactManager) Commit(commit Commit) error {
 	return err
 }
 
-func (mngr *ArtifactManager) AddRef(ref string, commit string) error {
+func (mngr *ArtifactManager) AddRef(ctx context.Context, ref string, commit string) error {
 	refPath := MakeRefPath(ref)
 	localPath := path.Join(mngr.metadataDir, refPath)
 	err := writeFile([]byte(commit), localPath)
@@ -174,7 +213,7 @@ func (mngr *ArtifactManager) AddRef(ref string, commit string) error {
 		return err
 	}
 
-	err = mngr.Upload(localPath, refPath, nil)
+	err = mngr.Upload(ctx, localPath, refPath, nil)
 	if err != nil {
 		return err
 	}
@@ -182,7 +221,7 @@ func (mngr *ArtifactManager) AddRef(ref string, commit string) error {
 	return err
 }
 
-func (mngr *ArtifactManager) DeleteRef(ref string) error {
+func (mngr *ArtifactManager) DeleteRef(ctx context.Context, ref string) error {
 	refPath := MakeRefPath(ref)
 	localPath := path.Join(mngr.metadataDir, refPath)
 
@@ -191,7 +230,7 @@ func (mngr *ArtifactManager) DeleteRef(ref string) error {
 		return err
 	}
 
-	err = mngr.repo.Delete(refPath)
+	err = mngr.repo.Delete(ctx, refPath)
 	if err != nil {
 		return err
 	}
@@ -199,7 +238,7 @@ func (mngr *ArtifactManager) DeleteRef(ref string) error {
 	return err
 }
 
-func (mngr *ArtifactManager) GetRef(ref string) (string, error) {
+func (mngr *ArtifactManager) GetRef(ctx context.Context, ref string) (string, error) {
 	refPath := MakeRefPath(ref)
 	localPath := path.Join(mngr.metadataDir, refPath)
 
@@ -209,7 +248,7 @@ func (mngr *ArtifactManager) GetRef(ref string) (string, error) {
 	}
 
 	tmpDir := path.Join(mngr.metadataDir, "tmp")
-	err = mngr.Download(refPath, localPath, tmpDir, nil)
+	err = mngr.Download(ctx, refPath, localPath, tmpDir, nil)
 	if err != nil {
 		return "", err
 	}
@@ -227,7 +266,7 @@ func (mngr *ArtifactManager) GetRef(ref string) (string, error) {
 	return hash, nil
 }
 
-func (mngr *ArtifactManager) GetCommit(hash string) (*Commit, error) {
+func (mngr *ArtifactManager) GetCommit(ctx context.Context, hash string) (*Commit, error) {
 	commitPath := MakeCommitPath(hash)
 	localPath := path.Join(mngr.metadataDir, commitPath)
 
@@ -239,7 +278,7 @@ func (mngr *ArtifactManager) GetCommit(hash string) (*Commit, error) {
 		}
 
 		tmpDir := path.Join(mngr.metadataDir, "tmp")
-		err = mngr.Download(commitPath, localPath, tmpDir, nil)
+		err = mngr.Download(ctx, commitPath, localPath, tmpDir, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -259,23 +298,35 @@ func (mngr *ArtifactManager) GetCommit(hash string) (*Commit, error) {
 	return &commit, nil
 }
 
-func (mngr *ArtifactManager) FindCommitOrReference(refOrCommit string) (string, error) {
-	var refPath string
+// emptyRepositoryError returns ErrEmptyRepository if the latest reference is not found in the repository. The other
+// errors (e.g. the authentication or network errors) are returned as is.
+func emptyRepositoryError(err error) error {
+	if repository.IsNotExist(err) {
+		return ErrEmptyRepository
+	}
+	return err
+}
+
+// FindCommitOrReference resolves a reference, a tag or a commit hash (prefix) to the commit hash.
+// The references are always resolved against the repository. Only the metadata required by the
+// resolution is downloaded.
+func (mngr *ArtifactManager) FindCommitOrReference(ctx context.Context, refOrCommit string) (string, error) {
 	if refOrCommit == RefLatest {
-		refPath = MakeRefPath(RefLatest)
-	} else {
-		refPath = MakeTagPath(refOrCommit)
+		commitHash, err := mngr.GetRef(ctx, RefLatest)
+		if err != nil {
+			return "", emptyRepositoryError(err)
+		}
+		return commitHash, nil
 	}
 
-	data, err := readFile(path.Join(mngr.metadataDir, refPath))
-	if err == nil {
-		return string(data), nil
+	if commitHash, err := mngr.GetRef(ctx, "tags/"+refOrCommit); err == nil {
+		return commitHash, nil
 	}
 
 	if len(refOrCommit) >= 4 {
-		dirEntries, err := ioutil.ReadDir(path.Join(mngr.metadataDir, "commits"))
+		// find in the local commits first
 		candidates := []string{}
-
+		dirEntries, err := ioutil.ReadDir(path.Join(mngr.metadataDir, "commits"))
 		if err != nil {
 			dirEntries = []fs.FileInfo{}
 		}
@@ -293,45 +344,89 @@ func (mngr *ArtifactManager) FindCommitOrReference(refOrCommit string) (string,
 		if len(candidates) == 1 {
 			return candidates[0], nil
 		}
+
+		// find in the repository
+		if len(candidates) == 0 {
+			commitEntries, err := mngr.repo.List(ctx, "commits")
+			if err != nil {
+				log.Debugln("cannot list the commits: " + err.Error())
+				commitEntries = []repository.FileInfo{}
+			}
+
+			for _, entry := range commitEntries {
+				if entry.IsDir() {
+					continue
+				}
+
+				if strings.HasPrefix(entry.Name(), refOrCommit) {
+					candidates = append(candidates, entry.Name())
+				}
+			}
+
+			if len(candidates) == 1 {
+				return candidates[0], nil
+			}
+		}
 	}
 
-	if refOrCommit == RefLatest {
-		return "", ErrEmptyRepository
-	} else {
-		return "", ReferenceNotFoundError{
-			Ref: refOrCommit,
+	return "", ReferenceNotFoundError{
+		Ref: refOrCommit,
+	}
+}
+
+// ListTagNames lists the tag names in the repository
+func (mngr *ArtifactManager) ListTagNames(ctx context.Context) ([]string, error) {
+	tagEntries, err := mngr.repo.List(ctx, "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []string{}
+	for _, entry := range tagEntries {
+		if entry.IsDir() {
+			continue
 		}
+
+		tags = append(tags, entry.Name())
 	}
+	sort.Strings(tags)
+
+	return tags, nil
 }
 
-// Fetch downloads all the metadata from repository
-func (mngr *ArtifactManager) Fetch() error {
+// Fetch downloads the references from repository. If the All option is set, all the commits are downloaded as well.
+// It is not required for other operations because the metadata is fetched on-demand.
+func (mngr *ArtifactManager) Fetch(ctx context.Context, options FetchOptions) error {
 	log.Debugln("fetch the repository metadata")
 	// fetch latest
-	if _, err := mngr.GetRef(RefLatest); err != nil {
-		return err
+	if _, err := mngr.GetRef(ctx, RefLatest); err != nil {
+		return emptyRepositoryError(err)
 	}
 
 	// fetch tags
-	tagEntries, err := mngr.repo.List("refs/tags")
+	tags, err := mngr.ListTagNames(ctx)
 	if err != nil {
 		return err
 	}
 
-	for _, entry := range tagEntries {
-		if _, err := mngr.GetRef("tags/" + entry.Name()); err != nil {
+	for _, tag := range tags {
+		if _, err := mngr.GetRef(ctx, "tags/"+tag); err != nil {
 			return err
 		}
 	}
 
+	if !options.All {
+		return nil
+	}
+
 	// fetch commmits
-	commitEntries, err := mngr.repo.List("commits")
+	commitEntries, err := mngr.repo.List(ctx, "commits")
 	if err != nil {
 		return err
 	}
 
 	for _, entry := range commitEntries {
-		_, err := mngr.GetCommit(entry.Name())
+		_, err := mngr.GetCommit(ctx, entry.Name())
 		if err != nil {
 			return err
 		}
@@ -340,8 +435,8 @@ func (mngr *ArtifactManager) Fetch() error {
 	return nil
 }
 
-func (mngr *ArtifactManager) Push(options PushOptions) error {
-	parent, err := mngr.GetRef(RefLatest)
+func (mngr *ArtifactManager) Push(ctx context.Context, options PushOptions) (*PushResult, error) {
+	parent, err := mngr.GetRef(ctx, RefLatest)
 	if err != nil {
 		parent = ""
 	}
@@ -353,7 +448,7 @@ func (mngr *ArtifactManager) Push(options PushOptions) error {
 
 	if err != nil {
 		if !os.IsNotExist(err) {
-			return err
+			return nil, err
 		}
 	} else {
 		avcIgnoreFilter = func(path string) bool {
@@ -361,12 +456,12 @@ func (mngr *ArtifactManager) Push(options PushOptions) error {
 		}
 	}
 
-	commit, err := mngr.MakeWorkspaceCommit(parent, options.Message, avcIgnoreFilter)
+	commit, err := mngr.MakeWorkspaceCommit(ctx, parent, options.Message, avcIgnoreFilter)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	result, err := mngr.Diff(DiffOptions{
+	result, err := mngr.Diff(ctx, DiffOptions{
 		LeftRef:      RefLatest,
 		RightCommit:  commit,
 		AddFilter:    avcIgnoreFilter,
@@ -375,10 +470,10 @@ func (mngr *ArtifactManager) Push(options PushOptions) error {
 	})
 	if err != nil {
 		if err != ErrEmptyRepository {
-			return err
+			return nil, err
 		} else {
 			checkSkip = false
-			result, err = mngr.Diff(DiffOptions{
+			result, err = mngr.Diff(ctx, DiffOptions{
 				LeftCommit:   mngr.MakeEmptyCommit(),
 				RightCommit:  commit,
 				AddFilter:    avcIgnoreFilter,
@@ -386,23 +481,26 @@ func (mngr *ArtifactManager) Push(options PushOptions) error {
 				DeleteFilter: nil,
 			})
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 	}
 
 	if options.DryRun || !result.IsChanged() {
-		result.Print(true)
-		return nil
+		if !options.DryRun && options.Index {
+			if err := mngr.WriteIndex(ctx); err != nil {
+				return nil, err
+			}
+		}
+		return &PushResult{DryRun: options.DryRun, Diff: result}, nil
 	}
 
-	total := 0
-	uploaded := 0
-	skipped := 0
-
 	session := repository.NewSession()
+	if !mngr.transfer.BandwidthLimit.Upload.IsUnlimited() {
+		session.SetRateLimiter(repository.NewRateLimiter(mngr.transfer.BandwidthLimit.Upload))
+	}
+	tracker := newTransferTracker(mngr, "upload", session)
 	tasks := []executor.TaskFunc{}
-	mtx := sync.Mutex{}
 
 	mapUploadBlob := map[string]DiffRecord{}
 	for _, record := range result.Records {
@@ -414,7 +512,7 @@ func (mngr *ArtifactManager) Push(options PushOptions) error {
 
 			if _, ok := mapUploadBlob[record.Hash]; !ok {
 				mapUploadBlob[record.Hash] = record
-				total++
+				tracker.add(record.Size)
 			}
 		}
 	}
@@ -427,73 +525,97 @@ func (mngr *ArtifactManager) Push(options PushOptions) error {
 		meter := session.NewMeter()
 
 		task := func(ctx context.Context) error {
-			uploadResult, err := mngr.UploadBlob(p, h, meter, checkSkip)
+			tracker.start(p, h, s)
+			uploadResult, err := mngr.UploadBlob(ctx, p, h, meter, checkSkip)
 			if err != nil {
 				return err
 			}
 
 			meter.SetBytes(s)
-			mtx.Lock()
-			uploaded++
-			if uploadResult.Skip {
-				skipped++
-			}
-			mtx.Unlock()
+			tracker.finish(p, h, s, uploadResult.Skip)
 			return nil
 		}
 		tasks = append(tasks, task)
 	}
 
-	ticker := time.NewTicker(time.Millisecond * 100)
-	defer ticker.Stop()
-
-	done := make(chan error)
-	go func() {
-		err = executor.ExecuteAll(0, tasks...)
-		done <- err
-	}()
-
-	stop := false
-	for !stop {
-		select {
-		case err = <-done:
-			stop = true
-		case <-ticker.C:
-		}
-		fmt.Printf("upload objects: (%d/%d), skipped: %d, speed: %5v/s    \r", uploaded, total, skipped, session.CalculateSpeed())
-	}
-
+	err = mngr.executeTransfers(ctx, tracker, tasks)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fmt.Println()
-	result.Print(false)
-
 	_, hash := MakeCommitMetadata(commit)
-	fmt.Println("create commit: " + hash)
-	err = mngr.Commit(*commit)
+	err = mngr.Commit(ctx, *commit)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fmt.Println("update ref: latest -> " + hash)
-	err = mngr.AddRef(RefLatest, hash)
+	err = mngr.AddRef(ctx, RefLatest, hash)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	pushResult := &PushResult{
+		Diff:     result,
+		Commit:   hash,
+		Transfer: tracker.summary(),
 	}
 
 	if options.Tag != nil {
 		tag := *options.Tag
-		err = mngr.AddTag(hash, tag)
+		err = mngr.addTag(ctx, hash, tag)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		pushResult.Tag = tag
+	}
 
-		fmt.Println("add tag: " + tag + " -> " + hash)
+	if options.Index {
+		err = mngr.WriteIndex(ctx)
+	} else {
+		err = mngr.updateIndex(ctx)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return pushResult, nil
+}
+
+// executeTransfers executes the transfer tasks with the configured concurrency and reports the progress
+func (mngr *ArtifactManager) executeTransfers(ctx context.Context, tracker *transferTracker, tasks []executor.TaskFunc) error {
+	mngr.report(tracker.event(ProgressTransfer))
+
+	done := make(chan error)
+	go func() {
+		if mngr.transfer.Adaptive {
+			done <- executor.ExecuteAllAdaptive(ctx, executor.AdaptiveOptions{
+				Initial:  mngr.transfer.Concurrency,
+				Min:      1,
+				Max:      mngr.transfer.MaxConcurrency,
+				Progress: tracker.session.TotalBytes,
+			}, tasks...)
+			return
+		}
+
+		done <- executor.ExecuteAllWithContext(ctx, mngr.transfer.Concurrency, tasks...)
+	}()
+
+	ticker := time.NewTicker(time.Millisecond * 100)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			summary := tracker.event(ProgressSummary)
+			if err != nil {
+				summary.Error = err.Error()
+			}
+			mngr.report(summary)
+			return err
+		case <-ticker.C:
+			mngr.report(tracker.event(ProgressTransfer))
+		}
+	}
 }
 
 func (mngr *ArtifactManager) MakeEmptyCommit() *Commit {
@@ -505,7 +627,7 @@ func (mngr *ArtifactManager) MakeEmptyCommit() *Commit {
 	}
 }
 
-func (mngr *ArtifactManager) MakeWorkspaceCommit(parent string, message *string, filter func(path string) bool) (*Commit, error) {
+func (mngr *ArtifactManager) MakeWorkspaceCommit(ctx context.Context, parent string, message *string, filter func(path string) bool) (*Commit, error) {
 	baseDir := mngr.baseDir
 	commit := Commit{
 		CreatedAt: time.Now(),
@@ -516,7 +638,9 @@ func (mngr *ArtifactManager) MakeWorkspaceCommit(parent string, message *string,
 
 	tasks := []executor.TaskFunc{}
 	mutex := sync.Mutex{}
+	hashed := 0
 
+	mngr.report(ProgressEvent{Type: ProgressScanStart, Path: baseDir})
 	err := filepath.Walk(baseDir, func(absPath string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return ErrWorkspaceNotFound
@@ -548,7 +672,10 @@ func (mngr *ArtifactManager) MakeWorkspaceCommit(parent string, message *string,
 
 			mutex.Lock()
 			commit.Blobs = append(commit.Blobs, metadata)
+			hashed++
+			event := ProgressEvent{Type: ProgressHash, Done: hashed, Total: len(tasks)}
 			mutex.Unlock()
+			mngr.report(event)
 
 			return nil
 		}
@@ -559,7 +686,9 @@ func (mngr *ArtifactManager) MakeWorkspaceCommit(parent string, message *string,
 		return nil, err
 	}
 
-	err = executor.ExecuteAll(0, tasks...)
+	mngr.report(ProgressEvent{Type: ProgressHash, Done: 0, Total: len(tasks)})
+
+	err = executor.ExecuteAllWithContext(ctx, mngr.transfer.HashConcurrency, tasks...)
 	if err != nil {
 		return nil, err
 	}
@@ -567,15 +696,7 @@ func (mngr *ArtifactManager) MakeWorkspaceCommit(parent string, message *string,
 	return &commit, nil
 }
 
-func (mngr *ArtifactManager) Pull(options PullOptions) error {
-	var err error
-	if !options.NoFetch {
-		err = mngr.Fetch()
-		if err != nil {
-			return err
-		}
-	}
-
+func (mngr *ArtifactManager) Pull(ctx context.Context, options PullOptions) (*PullResult, error) {
 	refOrCommit := RefLatest
 	if options.RefOrCommit != nil {
 		refOrCommit = *options.RefOrCommit
@@ -583,27 +704,14 @@ func (mngr *ArtifactManager) Pull(options PullOptions) error {
 
 	// Make the remote commit
 	log.Debugln("get the remote commit")
-	commitHash, err := mngr.FindCommitOrReference(refOrCommit)
+	commitHash, err := mngr.FindCommitOrReference(ctx, refOrCommit)
 	if err != nil {
-		var refPath string
-		if refOrCommit == RefLatest {
-			refPath = RefLatest
-		} else {
-			refPath = "tags/" + refOrCommit
-		}
-		commitHash, err = mngr.GetRef(refPath)
-		if err != nil {
-			if refOrCommit == RefLatest {
-				return ErrEmptyRepository
-			} else {
-				return ReferenceNotFoundError{Ref: refOrCommit}
-			}
-		}
+		return nil, err
 	}
 
-	commitRemote, err := mngr.GetCommit(commitHash)
+	commitRemote, err := mngr.GetCommit(ctx, commitHash)
 	if err != nil && err != ErrEmptyRepository {
-		return err
+		return nil, err
 	}
 
 	// Get the local commit hash
@@ -615,17 +723,17 @@ func (mngr *ArtifactManager) Pull(options PullOptions) error {
 
 	if err != nil {
 		if !os.IsNotExist(err) {
-			return err
+			return nil, err
 		}
 	} else {
 		avcIgnoreFilter = func(path string) bool {
 			return !avcIgnore.MatchesPath(path)
 		}
 	}
-	commitLocal, err := mngr.MakeWorkspaceCommit("", nil, avcIgnoreFilter)
+	commitLocal, err := mngr.MakeWorkspaceCommit(ctx, "", nil, avcIgnoreFilter)
 	if err != nil {
 		if err != ErrWorkspaceNotFound {
-			return err
+			return nil, err
 		} else {
 			commitLocal = mngr.MakeEmptyCommit()
 		}
@@ -633,7 +741,7 @@ func (mngr *ArtifactManager) Pull(options PullOptions) error {
 
 	// Diff
 	log.Debugln("diff")
-	result, err := mngr.Diff(DiffOptions{
+	result, err := mngr.Diff(ctx, DiffOptions{
 		NoDelete:      !options.Delete,
 		LeftCommit:    commitLocal,
 		RightCommit:   commitRemote,
@@ -643,22 +751,21 @@ func (mngr *ArtifactManager) Pull(options PullOptions) error {
 		IncludeFilter: options.FileFilter,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if options.DryRun || !result.IsChanged() {
-		result.Print(true)
-		return nil
+		return &PullResult{DryRun: options.DryRun, Diff: result, Commit: commitHash}, nil
 	}
 
 	// download
 	log.Debugln("download")
-	total := 0
-	downloaded := 0
-
 	session := repository.NewSession()
+	if !mngr.transfer.BandwidthLimit.Download.IsUnlimited() {
+		session.SetRateLimiter(repository.NewRateLimiter(mngr.transfer.BandwidthLimit.Download))
+	}
+	tracker := newTransferTracker(mngr, "download", session)
 	tasks := []executor.TaskFunc{}
-	mtx := sync.Mutex{}
 	for _, record := range result.Records {
 		if record.Type != DiffTypeAdd && record.Type != DiffTypeChange {
 			continue
@@ -679,42 +786,26 @@ func (mngr *ArtifactManager) Pull(options PullOptions) error {
 		s := record.Size
 
 		task := func(ctx context.Context) error {
+			tracker.start(p, h, s)
 			meter := session.NewMeter()
-			_, err := mngr.DownloadBlob(p, h, meter)
+			downloadResult, err := mngr.DownloadBlob(ctx, p, h, meter)
 			if err != nil {
 				return err
 			}
-			mtx.Lock()
-			downloaded++
-			mtx.Unlock()
 			meter.SetBytes(s)
+			tracker.finish(p, h, s, downloadResult.Skip)
 
 			return nil
 		}
 
 		tasks = append(tasks, task)
-		total++
+		tracker.add(s)
 	}
 
-	ticker := time.NewTicker(time.Millisecond * 100)
-	defer ticker.Stop()
-
-	done := make(chan error)
-	go func() {
-		err = executor.ExecuteAll(10, tasks...)
-		done <- err
-	}()
-
-	stop := false
-	for !stop {
-		select {
-		case err = <-done:
-			stop = true
-		case <-ticker.C:
-		}
-		fmt.Printf("download objects: (%d/%d), speed: %5v/s    \r", downloaded, total, session.CalculateSpeed())
+	err = mngr.executeTransfers(ctx, tracker, tasks)
+	if err != nil {
+		return nil, err
 	}
-	fmt.Println()
 
 	// delete, rename, symlink, chmod
 	log.Debugln("delete, rename, symlink, chmod")
@@ -727,46 +818,46 @@ func (mngr *ArtifactManager) Pull(options PullOptions) error {
 			if record.Link != "" {
 				err := symlinkFile(record.Link, absPath)
 				if err != nil {
-					return err
+					return nil, err
 				}
 			} else {
 				err := chmod(absPath, mode)
 				if err != nil {
-					return err
+					return nil, err
 				}
 			}
 		case DiffTypeChange:
 			if record.Link != "" {
 				err := deleteFile(absPath)
 				if err != nil {
-					return err
+					return nil, err
 				}
 
 				err = symlinkFile(record.Link, absPath)
 				if err != nil {
-					return err
+					return nil, err
 				}
 			} else {
 				err := chmod(absPath, mode)
 				if err != nil {
-					return err
+					return nil, err
 				}
 			}
 		case DiffTypeDelete:
 			err := deleteFile(absPath)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		case DiffTypeRename:
 			err := renameFile(filepath.Join(mngr.baseDir, record.OldPath), absPath)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			if record.Hash != "" {
 				err := chmod(absPath, mode)
 				if err != nil {
-					return err
+					return nil, err
 				}
 			}
 		}
@@ -775,55 +866,59 @@ func (mngr *ArtifactManager) Pull(options PullOptions) error {
 	if options.Delete {
 		_, err = removeEmptyDirs(mngr.baseDir, false)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 	_, err = removeEmptyDirs(filepath.Join(mngr.baseDir, ".avc"), true)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// print summary
-	result.Print(false)
-
-	return nil
+	return &PullResult{
+		Diff:     result,
+		Commit:   commitHash,
+		Transfer: tracker.summary(),
+	}, nil
 }
 
-func (mngr *ArtifactManager) ListTags() error {
-	err := mngr.Fetch()
+// ListTags returns the tags and the commits they point to
+func (mngr *ArtifactManager) ListTags(ctx context.Context) ([]TagEntry, error) {
+	names, err := mngr.ListTagNames(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	dirEntries, err := ioutil.ReadDir(path.Join(mngr.metadataDir, "refs/tags"))
-	if os.IsNotExist(err) {
-		return nil
+	tags := []TagEntry{}
+	for _, name := range names {
+		commitHash, err := mngr.GetRef(ctx, "tags/"+name)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, TagEntry{Name: name, Commit: commitHash})
 	}
-	if err != nil {
+	return tags, nil
+}
+
+func (mngr *ArtifactManager) AddTag(ctx context.Context, refOrCommit, tag string) error {
+	if err := mngr.addTag(ctx, refOrCommit, tag); err != nil {
 		return err
 	}
 
-	for _, entry := range dirEntries {
-		if entry.IsDir() {
-			continue
-		}
-
-		fmt.Println(entry.Name())
-	}
-	return nil
+	return mngr.updateIndex(ctx)
 }
 
-func (mngr *ArtifactManager) AddTag(refOrCommit, tag string) error {
+func (mngr *ArtifactManager) addTag(ctx context.Context, refOrCommit, tag string) error {
 	if tag == RefLatest {
 		return errors.New("latest cannot be a tag")
 	}
 
-	commitHash, err := mngr.FindCommitOrReference(refOrCommit)
+	commitHash, err := mngr.FindCommitOrReference(ctx, refOrCommit)
 	if err != nil {
 		return err
 	}
 
-	err = mngr.AddRef("tags/"+tag, commitHash)
+	err = mngr.AddRef(ctx, "tags/"+tag, commitHash)
 	if err != nil {
 		return err
 	}
@@ -831,47 +926,109 @@ func (mngr *ArtifactManager) AddTag(refOrCommit, tag string) error {
 	return nil
 }
 
-func (mngr *ArtifactManager) DeleteTag(tag string) error {
+func (mngr *ArtifactManager) DeleteTag(ctx context.Context, tag string) error {
 	if tag == RefLatest {
 		return errors.New("latest cannot be a tag")
 	}
 
-	err := mngr.DeleteRef("tags/" + tag)
+	err := mngr.DeleteRef(ctx, "tags/"+tag)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return mngr.updateIndex(ctx)
 }
 
-func (mngr *ArtifactManager) List(refOrCommit string) error {
-	err := mngr.Fetch()
+// WriteIndex writes the static listing index of the references and commits to the repository. It lets the
+// repositories which cannot list the directories (e.g. a static web server) support the tags, log and clone.
+func (mngr *ArtifactManager) WriteIndex(ctx context.Context) error {
+	log.Debugln("write the index")
+	index, err := repository.BuildIndex(ctx, mngr.repo, []string{"refs", "refs/tags", "commits"})
 	if err != nil {
 		return err
 	}
 
-	commitHash, err := mngr.FindCommitOrReference(refOrCommit)
+	return repository.WriteIndex(ctx, mngr.repo, index)
+}
+
+// updateIndex writes the index if it is enabled or the repository already has one, e.g. written by "push --index",
+// so that the existing index does not go stale
+func (mngr *ArtifactManager) updateIndex(ctx context.Context) error {
+	if !mngr.index {
+		_, err := mngr.repo.Stat(ctx, repository.IndexPath)
+		if repository.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return mngr.WriteIndex(ctx)
+}
+
+// List returns the files of the commit sorted by path
+func (mngr *ArtifactManager) List(ctx context.Context, refOrCommit string) ([]BlobMetaData, error) {
+	commitHash, err := mngr.FindCommitOrReference(ctx, refOrCommit)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	commit, err := mngr.GetCommit(commitHash)
+	commit, err := mngr.GetCommit(ctx, commitHash)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	sort.Slice(commit.Blobs, func(i, j int) bool {
 		return commit.Blobs[i].Path < commit.Blobs[j].Path
 	})
 
-	for _, blob := range commit.Blobs {
-		fmt.Println(blob.Path)
+	return commit.Blobs, nil
+}
+
+// Cat writes the content of the file in the commit to the writer. The object is streamed from the repository
+// without a local copy.
+func (mngr *ArtifactManager) Cat(ctx context.Context, refOrCommit, filePath string, dest io.Writer) (*BlobMetaData, error) {
+	commitHash, err := mngr.FindCommitOrReference(ctx, refOrCommit)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	commit, err := mngr.GetCommit(ctx, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	filePath = path.Clean(strings.TrimPrefix(filepath.ToSlash(filePath), "/"))
+	var blob *BlobMetaData
+	for i := range commit.Blobs {
+		if commit.Blobs[i].Path == filePath {
+			blob = &commit.Blobs[i]
+			break
+		}
+	}
+
+	if blob == nil {
+		return nil, fmt.Errorf("%s: no such file in the commit %s", filePath, commitHash[:8])
+	}
+
+	if blob.Link != "" {
+		return nil, fmt.Errorf("%s is a symbolic link to %s", filePath, blob.Link)
+	}
+
+	session := repository.NewSession()
+	if !mngr.transfer.BandwidthLimit.Download.IsUnlimited() {
+		session.SetRateLimiter(repository.NewRateLimiter(mngr.transfer.BandwidthLimit.Download))
+	}
+
+	err = mngr.repo.DownloadStream(ctx, MakeObjectPath(blob.Hash), dest, session.NewMeter())
+	if err != nil {
+		return nil, err
+	}
+
+	return blob, nil
 }
 
-func (mngr *ArtifactManager) Diff(option DiffOptions) (DiffResult, error) {
+func (mngr *ArtifactManager) Diff(ctx context.Context, option DiffOptions) (DiffResult, error) {
 	type DiffEntry struct {
 		left  *BlobMetaData
 		right *BlobMetaData
@@ -886,12 +1043,12 @@ func (mngr *ArtifactManager) Diff(option DiffOptions) (DiffResult, error) {
 	// left
 	leftCommit := option.LeftCommit
 	if leftCommit == nil {
-		commitHash, err = mngr.FindCommitOrReference(option.LeftRef)
+		commitHash, err = mngr.FindCommitOrReference(ctx, option.LeftRef)
 		if err != nil {
 			return DiffResult{}, err
 		}
 
-		leftCommit, err = mngr.GetCommit(commitHash)
+		leftCommit, err = mngr.GetCommit(ctx, commitHash)
 		if err != nil {
 			return DiffResult{}, err
 		}
@@ -908,12 +1065,12 @@ func (mngr *ArtifactManager) Diff(option DiffOptions) (DiffResult, error) {
 	// right
 	rightCommit := option.RightCommit
 	if rightCommit == nil {
-		commitHash, err = mngr.FindCommitOrReference(option.RightRef)
+		commitHash, err = mngr.FindCommitOrReference(ctx, option.RightRef)
 		if err != nil {
 			return DiffResult{}, err
 		}
 
-		rightCommit, err = mngr.GetCommit(commitHash)
+		rightCommit, err = mngr.GetCommit(ctx, commitHash)
 		if err != nil {
 			return DiffResult{}, err
 		}
@@ -1087,16 +1244,13 @@ func (mngr *ArtifactManager) Diff(option DiffOptions) (DiffResult, error) {
 	}, nil
 }
 
-func (mngr *ArtifactManager) Status() (DiffResult, error) {
+func (mngr *ArtifactManager) Status(ctx context.Context) (DiffResult, error) {
 	// Make the remote latest commit
-	commitHash, err := mngr.FindCommitOrReference(RefLatest)
+	commitHash, err := mngr.FindCommitOrReference(ctx, RefLatest)
 	if err != nil {
-		commitHash, err = mngr.GetRef(RefLatest)
-		if err != nil {
-			return DiffResult{}, ErrEmptyRepository
-		}
+		return DiffResult{}, err
 	}
-	commitRemote, err := mngr.GetCommit(commitHash)
+	commitRemote, err := mngr.GetCommit(ctx, commitHash)
 	if err != nil && err != ErrEmptyRepository {
 		return DiffResult{}, err
 	}
@@ -1117,7 +1271,7 @@ func (mngr *ArtifactManager) Status() (DiffResult, error) {
 		}
 	}
 
-	commitLocal, err := mngr.MakeWorkspaceCommit("", nil, avcIgnoreFilter)
+	commitLocal, err := mngr.MakeWorkspaceCommit(ctx, "", nil, avcIgnoreFilter)
 	if err != nil {
 		if err != ErrWorkspaceNotFound {
 			return DiffResult{}, err
@@ -1127,7 +1281,7 @@ func (mngr *ArtifactManager) Status() (DiffResult, error) {
 	}
 
 	// Diff
-	result, err := mngr.Diff(DiffOptions{
+	result, err := mngr.Diff(ctx, DiffOptions{
 		LeftCommit:   commitRemote,
 		RightCommit:  commitLocal,
 		AddFilter:    avcIgnoreFilter,
@@ -1141,69 +1295,36 @@ func (mngr *ArtifactManager) Status() (DiffResult, error) {
 	return result, nil
 }
 
-func (mngr *ArtifactManager) Log(refOrCommit string) error {
-	err := mngr.Fetch()
-	if err != nil {
-		return err
-	}
-
-	type RefEntry struct {
-		refType string
-		ref     string
-	}
-
-	commitIndex := map[string][]RefEntry{}
+// Log returns the commits from the commit or reference to the ancestors
+func (mngr *ArtifactManager) Log(ctx context.Context, refOrCommit string) ([]LogEntry, error) {
+	refIndex := map[string][]string{}
 
 	// get latest
-	latestPath := path.Join(mngr.metadataDir, "refs/latest")
-	data, err := readFile(latestPath)
-	if err == nil {
-		commitHash := string(data)
-		commitIndex[commitHash] = []RefEntry{{
-			refType: RefLatest,
-			ref:     RefLatest,
-		}}
-	} else {
-		return ErrEmptyRepository
+	commitHash, err := mngr.GetRef(ctx, RefLatest)
+	if err != nil {
+		return nil, emptyRepositoryError(err)
 	}
+	refIndex[commitHash] = []string{RefLatest}
 
 	// get reference
-	refTagsDir := path.Join(mngr.metadataDir, "refs/tags")
-	dirEntries, err := ioutil.ReadDir(refTagsDir)
+	tags, err := mngr.ListTags(ctx)
 	if err == nil {
-		for _, entry := range dirEntries {
-			ref := entry.Name()
-			if entry.IsDir() {
-				continue
-			}
-
-			data, err := readFile(path.Join(refTagsDir, entry.Name()))
-			if err != nil {
-				return err
-			}
-
-			commitHash := string(data)
-			refEntry := RefEntry{
-				refType: "tag",
-				ref:     ref,
-			}
-			if commitIndex[commitHash] != nil {
-				commitIndex[commitHash] = append(commitIndex[commitHash], refEntry)
-			} else {
-				commitIndex[commitHash] = []RefEntry{refEntry}
-			}
+		for _, tag := range tags {
+			refIndex[tag.Commit] = append(refIndex[tag.Commit], tag.Name)
 		}
 	}
 
-	// log from refOrCommit
-	commitHash, err := mngr.FindCommitOrReference(refOrCommit)
+	// log from refOrCommit. the parent commits are fetched on-demand
+	commitHash, err = mngr.FindCommitOrReference(ctx, refOrCommit)
 	if err != nil {
-		return err
+		return nil, err
 	}
+
+	entries := []LogEntry{}
 	for count := 0; commitHash != "" && count < 1000; count++ {
-		commit, err := mngr.GetCommit(commitHash)
+		commit, err := mngr.GetCommit(ctx, commitHash)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		message := ""
@@ -1211,45 +1332,24 @@ func (mngr *ArtifactManager) Log(refOrCommit string) error {
 			message = *commit.Message
 		}
 
-		createdAt := commit.CreatedAt.Format("2006-01-02 15:04 -0700")
-
-		color.Set(color.FgYellow)
-		fmt.Printf("%s ", commitHash[:8])
-		color.Set(color.FgHiBlack)
-		fmt.Printf("%s ", createdAt)
-
-		if commitIndex[commitHash] != nil {
-			first := true
-			color.Set(color.FgYellow)
-			fmt.Print("(")
-			for _, refEntry := range commitIndex[commitHash] {
-				if !first {
-					color.Set(color.FgYellow)
-					fmt.Print(", ")
-				}
-
-				if refEntry.refType == RefLatest {
-					color.Set(color.FgHiGreen)
-				} else {
-					color.Set(color.FgHiRed)
-				}
-
-				fmt.Print(refEntry.ref)
-				first = false
-			}
-			color.Set(color.FgYellow)
-			fmt.Print(") ")
+		author := ""
+		if commit.Author != nil {
+			author = *commit.Author
 		}
 
-		color.Set(color.FgHiWhite)
-		fmt.Printf("%s\n", message)
-		color.Unset()
+		entries = append(entries, LogEntry{
+			Hash:      commitHash,
+			CreatedAt: commit.CreatedAt,
+			Parent:    commit.Parent,
+			Message:   message,
+			Author:    author,
+			Refs:      refIndex[commitHash],
+		})
 
 		commitHash = commit.Parent
-		count++
 	}
 
-	return nil
+	return entries, nil
 }
 
 func (result DiffResult) IsChanged() bool {
@@ -1272,42 +1372,20 @@ func (result DiffResult) IsAppendOnly() bool {
 	return modified == 0
 }
 
-func (result *DiffResult) Print(verbose bool) {
-	records := result.Records
-	if records == nil {
-		records = []DiffRecord{}
-	}
-
-	var added, deleted, modified, renamed int
-
-	for _, record := range records {
+// Stats counts the records by the diff type
+func (result DiffResult) Stats() DiffStats {
+	var stats DiffStats
+	for _, record := range result.Records {
 		switch record.Type {
 		case DiffTypeAdd:
-			if verbose {
-				color.HiGreen(fmt.Sprintf("+ %s\n", record.Path))
-			}
-			added++
+			stats.Added++
 		case DiffTypeDelete:
-			if verbose {
-				color.HiRed(fmt.Sprintf("- %s\n", record.Path))
-			}
-			deleted++
+			stats.Deleted++
 		case DiffTypeChange:
-			if verbose {
-				color.HiYellow(fmt.Sprintf("M %s\n", record.Path))
-			}
-			modified++
+			stats.Modified++
 		case DiffTypeRename:
-			if verbose {
-				color.HiYellow(fmt.Sprintf("R %s -> %s\n", record.OldPath, record.Path))
-			}
-			renamed++
+			stats.Renamed++
 		}
 	}
-
-	if !result.IsChanged() {
-		fmt.Println("no changed")
-	} else {
-		fmt.Printf("%d modified(M), %d added(+), %d deleted(-), %d renamed(R)\n", modified, added, deleted, renamed)
-	}
+	return stats
 }
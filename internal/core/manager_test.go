@@ -1,10 +1,16 @@
 package core
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/infuseai/artivc/internal/repository"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,13 +29,13 @@ func TestPutGet(t *testing.T) {
 	config := NewConfig(wp1, meta1, repo)
 	mngr1, err := NewArtifactManager(config)
 	assert.Empty(t, err)
-	err = mngr1.Push(PushOptions{})
+	_, err = mngr1.Push(context.Background(), PushOptions{})
 	assert.Empty(t, err)
 
 	config = NewConfig(wp2, meta2, repo)
 	mngr2, err := NewArtifactManager(config)
 	assert.Empty(t, err)
-	err = mngr2.Pull(PullOptions{})
+	_, err = mngr2.Pull(context.Background(), PullOptions{})
 	assert.Empty(t, err)
 
 	data, err := readFile(filepath.Join(wp2, path))
@@ -53,17 +59,19 @@ func TestPushPull(t *testing.T) {
 	assert.NoError(t, InitWorkspace(wp1, repo))
 	config, _ := LoadConfig(wp1)
 	mngr1, _ := NewArtifactManager(config)
-	assert.NoError(t, mngr1.Push(PushOptions{}))
+	_, err := mngr1.Push(context.Background(), PushOptions{})
+	assert.NoError(t, err)
 
 	assert.NoError(t, InitWorkspace(wp2, repo))
 	config, _ = LoadConfig(wp2)
 	mngr2, _ := NewArtifactManager(config)
-	assert.NoError(t, mngr2.Pull(PullOptions{}))
+	_, err = mngr2.Pull(context.Background(), PullOptions{})
+	assert.NoError(t, err)
 
 	data, _ := readFile(filepath.Join(wp2, path))
 	assert.Equal(t, string(data), content)
 
-	_, err := os.Stat(filepath.Join(wp2, ".avc/config"))
+	_, err = os.Stat(filepath.Join(wp2, ".avc/config"))
 	assert.False(t, os.IsNotExist(err))
 }
 
@@ -86,13 +94,13 @@ e
 	assert.NoError(t, InitWorkspace(wp1, repo))
 	config, _ := LoadConfig(wp1)
 	mngr1, _ := NewArtifactManager(config)
-	err := mngr1.Push(PushOptions{})
+	_, err := mngr1.Push(context.Background(), PushOptions{})
 	assert.Empty(t, err)
 
 	assert.NoError(t, InitWorkspace(wp2, repo))
 	config, _ = LoadConfig(wp2)
 	mngr2, _ := NewArtifactManager(config)
-	err = mngr2.Pull(PullOptions{})
+	_, err = mngr2.Pull(context.Background(), PullOptions{})
 	assert.Empty(t, err)
 
 	data, _ := readFile(filepath.Join(wp2, "a"))
@@ -115,7 +123,7 @@ func TestPullWithIgnore(t *testing.T) {
 	assert.NoError(t, InitWorkspace(wp1, repo))
 	config, _ := LoadConfig(wp1)
 	mngr1, _ := NewArtifactManager(config)
-	err := mngr1.Push(PushOptions{})
+	_, err := mngr1.Push(context.Background(), PushOptions{})
 	assert.Empty(t, err)
 
 	// pull
@@ -129,7 +137,7 @@ e
 	assert.NoError(t, InitWorkspace(wp2, repo))
 	config, _ = LoadConfig(wp2)
 	mngr2, _ := NewArtifactManager(config)
-	err = mngr2.Pull(PullOptions{})
+	_, err = mngr2.Pull(context.Background(), PullOptions{})
 	assert.Empty(t, err)
 
 	data, _ := readFile(filepath.Join(wp2, "a"))
@@ -159,12 +167,14 @@ func TestSymlink(t *testing.T) {
 	assert.NoError(t, InitWorkspace(wp1, repo))
 	config, _ := LoadConfig(wp1)
 	mngr1, _ := NewArtifactManager(config)
-	assert.NoError(t, mngr1.Push(PushOptions{}))
+	_, err = mngr1.Push(context.Background(), PushOptions{})
+	assert.NoError(t, err)
 
 	assert.NoError(t, InitWorkspace(wp2, repo))
 	config, _ = LoadConfig(wp2)
 	mngr2, _ := NewArtifactManager(config)
-	assert.NoError(t, mngr2.Pull(PullOptions{}))
+	_, err = mngr2.Pull(context.Background(), PullOptions{})
+	assert.NoError(t, err)
 
 	data, _ := readFile(filepath.Join(wp2, "a"))
 	assert.Equal(t, "a", string(data))
@@ -183,8 +193,10 @@ func TestSymlink(t *testing.T) {
 	assert.NoError(t, writeFile([]byte("c"), filepath.Join(wp1, "c")))
 	assert.NoError(t, deleteFile(filepath.Join(wp1, "d")))
 	assert.NoError(t, symlinkFile("dd", filepath.Join(wp1, "e")))
-	assert.NoError(t, mngr1.Push(PushOptions{}))
-	assert.NoError(t, mngr2.Pull(PullOptions{Delete: true}))
+	_, err = mngr1.Push(context.Background(), PushOptions{})
+	assert.NoError(t, err)
+	_, err = mngr2.Pull(context.Background(), PullOptions{Delete: true})
+	assert.NoError(t, err)
 
 	link, _ = readlinkFile(filepath.Join(wp2, "a"))
 	assert.Equal(t, "aa", link)
@@ -217,12 +229,14 @@ func TestPermMode(t *testing.T) {
 	assert.NoError(t, InitWorkspace(wp1, repo))
 	config, _ := LoadConfig(wp1)
 	mngr1, _ := NewArtifactManager(config)
-	assert.NoError(t, mngr1.Push(PushOptions{}))
+	_, err := mngr1.Push(context.Background(), PushOptions{})
+	assert.NoError(t, err)
 
 	assert.NoError(t, InitWorkspace(wp2, repo))
 	config, _ = LoadConfig(wp2)
 	mngr2, _ := NewArtifactManager(config)
-	assert.NoError(t, mngr2.Pull(PullOptions{}))
+	_, err = mngr2.Pull(context.Background(), PullOptions{})
+	assert.NoError(t, err)
 
 	mode, _ := readFileMode(filepath.Join(wp2, "a"))
 	assert.Equal(t, 0o644, int(mode))
@@ -243,8 +257,10 @@ func TestPermMode(t *testing.T) {
 	assert.NoError(t, writeFile([]byte("d"), filepath.Join(wp1, "d")))
 	assert.NoError(t, chmod(filepath.Join(wp1, "d"), 0o755))
 
-	assert.NoError(t, mngr1.Push(PushOptions{}))
-	assert.NoError(t, mngr2.Pull(PullOptions{Delete: true}))
+	_, err = mngr1.Push(context.Background(), PushOptions{})
+	assert.NoError(t, err)
+	_, err = mngr2.Pull(context.Background(), PullOptions{Delete: true})
+	assert.NoError(t, err)
 
 	mode, _ = readFileMode(filepath.Join(wp2, "a"))
 	assert.Equal(t, 0o755, int(mode))
@@ -255,3 +271,265 @@ func TestPermMode(t *testing.T) {
 	mode, _ = readFileMode(filepath.Join(wp2, "d"))
 	assert.Equal(t, 0o755, int(mode))
 }
+
+func TestLazyFetch(t *testing.T) {
+	wp1 := t.TempDir()
+	wp2 := t.TempDir()
+	meta2 := t.TempDir()
+	repo := t.TempDir()
+
+	// push two versions. tag the first one
+	tag := "v1"
+	assert.NoError(t, writeFile([]byte("a"), filepath.Join(wp1, "a")))
+	assert.NoError(t, InitWorkspace(wp1, repo))
+	config, _ := LoadConfig(wp1)
+	mngr1, _ := NewArtifactManager(config)
+	_, err := mngr1.Push(context.Background(), PushOptions{Tag: &tag})
+	assert.NoError(t, err)
+
+	assert.NoError(t, writeFile([]byte("b"), filepath.Join(wp1, "b")))
+	_, err = mngr1.Push(context.Background(), PushOptions{})
+	assert.NoError(t, err)
+
+	// pull the tag. only the tagged commit is fetched
+	config = NewConfig(wp2, meta2, repo)
+	mngr2, _ := NewArtifactManager(config)
+	_, err = mngr2.Pull(context.Background(), PullOptions{RefOrCommit: &tag})
+	assert.NoError(t, err)
+
+	entries, err := os.ReadDir(filepath.Join(meta2, "commits"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	_, err = readFile(filepath.Join(wp2, "b"))
+	assert.Error(t, err)
+
+	// resolve by commit prefix without the local commits
+	commitHash, err := mngr2.FindCommitOrReference(context.Background(), RefLatest)
+	assert.NoError(t, err)
+	found, err := mngr2.FindCommitOrReference(context.Background(), commitHash[:8])
+	assert.NoError(t, err)
+	assert.Equal(t, commitHash, found)
+
+	tags, err := mngr2.ListTagNames(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{tag}, tags)
+
+	// fetch all
+	assert.NoError(t, mngr2.Fetch(context.Background(), FetchOptions{All: true}))
+	entries, err = os.ReadDir(filepath.Join(meta2, "commits"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+}
+
+// failingRepository fails all the downloads with the error
+type failingRepository struct {
+	repository.Repository
+	err error
+}
+
+func (repo *failingRepository) Download(ctx context.Context, repoPath, localPath string, meter *repository.Meter) error {
+	return repo.err
+}
+
+func TestEmptyRepository(t *testing.T) {
+	ctx := context.Background()
+	mngr := newSyncManager(t, t.TempDir())
+
+	// only the missing reference means an empty repository
+	_, err := mngr.FindCommitOrReference(ctx, RefLatest)
+	assert.ErrorIs(t, err, ErrEmptyRepository)
+	assert.ErrorIs(t, mngr.Fetch(ctx, FetchOptions{}), ErrEmptyRepository)
+	_, err = mngr.Log(ctx, RefLatest)
+	assert.ErrorIs(t, err, ErrEmptyRepository)
+
+	// the other errors are returned as is
+	mngr.repo = &failingRepository{mngr.repo, os.ErrPermission}
+	_, err = mngr.FindCommitOrReference(ctx, RefLatest)
+	assert.ErrorIs(t, err, os.ErrPermission)
+	assert.ErrorIs(t, mngr.Fetch(ctx, FetchOptions{}), os.ErrPermission)
+	_, err = mngr.Log(ctx, RefLatest)
+	assert.ErrorIs(t, err, os.ErrPermission)
+
+	mngr.repo = &failingRepository{mngr.repo, context.Canceled}
+	_, err = mngr.FindCommitOrReference(ctx, RefLatest)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLogListTags(t *testing.T) {
+	wp := t.TempDir()
+	repo := t.TempDir()
+
+	tag := "v1"
+	message := "first"
+	assert.NoError(t, writeFile([]byte("a"), filepath.Join(wp, "a")))
+	assert.NoError(t, InitWorkspace(wp, repo))
+	config, _ := LoadConfig(wp)
+	mngr, _ := NewArtifactManager(config)
+	first, err := mngr.Push(context.Background(), PushOptions{Message: &message, Tag: &tag})
+	assert.NoError(t, err)
+	assert.Equal(t, tag, first.Tag)
+	assert.Equal(t, 1, first.Transfer.Objects)
+
+	assert.NoError(t, writeFile([]byte("b"), filepath.Join(wp, "b")))
+	second, err := mngr.Push(context.Background(), PushOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []DiffRecord{{Type: DiffTypeAdd, Hash: "e9d71f5ee7c92d6dc9e92ffdad17b8bd49418f98", Path: "b", Size: 1, Mode: 0o644}}, second.Diff.Records)
+
+	entries, err := mngr.Log(context.Background(), RefLatest)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, second.Commit, entries[0].Hash)
+	assert.Equal(t, []string{RefLatest}, entries[0].Refs)
+	assert.Equal(t, first.Commit, entries[1].Hash)
+	assert.Equal(t, []string{tag}, entries[1].Refs)
+	assert.Equal(t, message, entries[1].Message)
+
+	blobs, err := mngr.List(context.Background(), tag)
+	assert.NoError(t, err)
+	assert.Len(t, blobs, 1)
+	assert.Equal(t, "a", blobs[0].Path)
+
+	tags, err := mngr.ListTags(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []TagEntry{{Name: tag, Commit: first.Commit}}, tags)
+}
+
+func TestCat(t *testing.T) {
+	wp := t.TempDir()
+	repo := t.TempDir()
+
+	tag := "v1"
+	assert.NoError(t, writeFile([]byte("first"), filepath.Join(wp, "data/a")))
+	assert.NoError(t, InitWorkspace(wp, repo))
+	config, _ := LoadConfig(wp)
+	mngr, _ := NewArtifactManager(config)
+	_, err := mngr.Push(context.Background(), PushOptions{Tag: &tag})
+	assert.NoError(t, err)
+
+	assert.NoError(t, writeFile([]byte("second"), filepath.Join(wp, "data/a")))
+	assert.NoError(t, os.Symlink("a", filepath.Join(wp, "data/link")))
+	_, err = mngr.Push(context.Background(), PushOptions{})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		desc string
+		ref  string
+		path string
+		data string
+		err  bool
+	}{
+		{desc: "latest", ref: RefLatest, path: "data/a", data: "second"},
+		{desc: "tag", ref: tag, path: "data/a", data: "first"},
+		{desc: "leading slash", ref: tag, path: "/data/a", data: "first"},
+		{desc: "not found", ref: RefLatest, path: "data/b", err: true},
+		{desc: "directory", ref: RefLatest, path: "data", err: true},
+		{desc: "symlink", ref: RefLatest, path: "data/link", err: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			blob, err := mngr.Cat(context.Background(), tC.ref, tC.path, &buf)
+			if tC.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tC.data, buf.String())
+			assert.Equal(t, int64(len(tC.data)), blob.Size)
+		})
+	}
+}
+
+func TestIndexMirror(t *testing.T) {
+	ctx := context.Background()
+	wp := t.TempDir()
+	repo := t.TempDir()
+
+	assert.NoError(t, writeFile([]byte("a"), filepath.Join(wp, "a")))
+	assert.NoError(t, InitWorkspace(wp, repo))
+	config, _ := LoadConfig(wp)
+	config.Set("repo.index", "true")
+	mngr, err := NewArtifactManager(config)
+	assert.NoError(t, err)
+
+	tag := "v1"
+	first, err := mngr.Push(ctx, PushOptions{Tag: &tag})
+	assert.NoError(t, err)
+	assert.NoError(t, writeFile([]byte("b"), filepath.Join(wp, "b")))
+	second, err := mngr.Push(ctx, PushOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, mngr.AddTag(ctx, RefLatest, "v2"))
+
+	// a read-only mirror on a static web server
+	ts := httptest.NewServer(http.FileServer(http.Dir(repo)))
+	defer ts.Close()
+
+	mirror := t.TempDir()
+	assert.NoError(t, InitWorkspace(mirror, ts.URL))
+	config, _ = LoadConfig(mirror)
+	mngr, err = NewArtifactManager(config)
+	assert.NoError(t, err)
+
+	tags, err := mngr.ListTags(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []TagEntry{{Name: "v1", Commit: first.Commit}, {Name: "v2", Commit: second.Commit}}, tags)
+
+	assert.NoError(t, mngr.Fetch(ctx, FetchOptions{All: true}))
+
+	entries, err := mngr.Log(ctx, RefLatest)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, []string{RefLatest, "v2"}, entries[0].Refs)
+
+	_, err = mngr.Pull(ctx, PullOptions{})
+	assert.NoError(t, err)
+	data, err := readFile(filepath.Join(mirror, "b"))
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(data))
+}
+
+func TestIndexRefresh(t *testing.T) {
+	ctx := context.Background()
+	wp := t.TempDir()
+	repo := t.TempDir()
+
+	assert.NoError(t, writeFile([]byte("a"), filepath.Join(wp, "a")))
+	mngr, err := NewArtifactManager(NewConfig(wp, t.TempDir(), repo))
+	assert.NoError(t, err)
+
+	// no index is written without the option or the config
+	_, err = mngr.Push(ctx, PushOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, mngr.AddTag(ctx, RefLatest, "v0"))
+	_, err = os.Stat(filepath.Join(repo, repository.IndexPath))
+	assert.True(t, os.IsNotExist(err))
+
+	// the index written once is refreshed by the tags
+	_, err = mngr.Push(ctx, PushOptions{Index: true})
+	assert.NoError(t, err)
+	assert.NoError(t, mngr.AddTag(ctx, RefLatest, "v1"))
+	assert.NoError(t, mngr.DeleteTag(ctx, "v0"))
+
+	ts := httptest.NewServer(http.FileServer(http.Dir(repo)))
+	defer ts.Close()
+	mirror, err := repository.NewHttpRepository(ts.URL, repository.HttpConfig{})
+	assert.NoError(t, err)
+	entries, err := mirror.List(ctx, "refs/tags")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "v1", entries[0].Name())
+	}
+}
+
+func TestDiffRecordJSON(t *testing.T) {
+	record := DiffRecord{Type: DiffTypeRename, Path: "b", OldPath: "a"}
+	data, err := json.Marshal(record)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"rename","path":"b","size":0,"mode":0,"oldPath":"a"}`, string(data))
+
+	var decoded DiffRecord
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, record, decoded)
+}
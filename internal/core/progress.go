@@ -0,0 +1,326 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/infuseai/artivc/internal/repository"
+	"github.com/mattn/go-isatty"
+)
+
+type ProgressEventType string
+
+const (
+	// Start to scan the workspace
+	ProgressScanStart ProgressEventType = "scan_start"
+	// The files hashed while scanning the workspace
+	ProgressHash ProgressEventType = "hash"
+	// The overall progress of the transfers. It is reported once at the beginning and periodically afterward.
+	ProgressTransfer ProgressEventType = "transfer"
+	// Start to transfer an object
+	ProgressTransferStart ProgressEventType = "transfer_start"
+	// An object is transferred or skipped
+	ProgressTransferFinish ProgressEventType = "transfer_finish"
+	// All the transfers are done or failed
+	ProgressSummary ProgressEventType = "summary"
+)
+
+const (
+	ProgressFormatAuto  = "auto"
+	ProgressFormatTTY   = "tty"
+	ProgressFormatPlain = "plain"
+	ProgressFormatJSON  = "json"
+	ProgressFormatNone  = "none"
+)
+
+type ProgressEvent struct {
+	Type ProgressEventType `json:"type"`
+	Time time.Time         `json:"time"`
+	// "upload", "download" or "copy" for the transfer events
+	Op string `json:"op,omitempty"`
+	// The workspace path for the scan event, or the object path for the per-object events
+	Path    string `json:"path,omitempty"`
+	Hash    string `json:"hash,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	Skipped bool   `json:"skipped,omitempty"`
+	// The counters of the hash and transfer events
+	Done           int     `json:"done,omitempty"`
+	Total          int     `json:"total,omitempty"`
+	SkippedObjects int     `json:"skippedObjects,omitempty"`
+	Bytes          int64   `json:"bytes,omitempty"`
+	TotalBytes     int64   `json:"totalBytes,omitempty"`
+	Elapsed        float64 `json:"elapsed,omitempty"` // seconds
+	Speed          float64 `json:"speed,omitempty"`   // bytes per second
+	Error          string  `json:"error,omitempty"`
+}
+
+// ProgressReporter receives the progress events of the artifact manager. The events may be reported
+// from multiple goroutines.
+type ProgressReporter interface {
+	Report(event ProgressEvent)
+}
+
+type ProgressReporterFunc func(event ProgressEvent)
+
+func (f ProgressReporterFunc) Report(event ProgressEvent) {
+	f(event)
+}
+
+// NewProgressReporter creates the built-in renderer of the format. The "auto" format renders the progress bar
+// if the writer is a terminal, otherwise the plain lines. The "none" format returns nil.
+func NewProgressReporter(format string, w io.Writer) (ProgressReporter, error) {
+	switch format {
+	case "", ProgressFormatAuto:
+		if f, ok := w.(*os.File); ok && isatty.IsTerminal(f.Fd()) {
+			return NewTTYProgressReporter(w), nil
+		}
+		return NewPlainProgressReporter(w), nil
+	case ProgressFormatTTY:
+		return NewTTYProgressReporter(w), nil
+	case ProgressFormatPlain:
+		return NewPlainProgressReporter(w), nil
+	case ProgressFormatJSON:
+		return NewJSONProgressReporter(w), nil
+	case ProgressFormatNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported progress format: %s", format)
+	}
+}
+
+// transferTracker counts the transfers of a push or pull and reports the progress events
+type transferTracker struct {
+	mngr       *ArtifactManager
+	op         string
+	session    *repository.Session
+	startedAt  time.Time
+	mtx        sync.Mutex
+	total      int
+	totalBytes int64
+	done       int
+	skipped    int
+}
+
+func newTransferTracker(mngr *ArtifactManager, op string, session *repository.Session) *transferTracker {
+	return &transferTracker{
+		mngr:      mngr,
+		op:        op,
+		session:   session,
+		startedAt: time.Now(),
+	}
+}
+
+// add counts an object to transfer
+func (t *transferTracker) add(size int64) {
+	t.total++
+	t.totalBytes += size
+}
+
+func (t *transferTracker) start(path, hash string, size int64) {
+	t.mngr.report(ProgressEvent{Type: ProgressTransferStart, Op: t.op, Path: path, Hash: hash, Size: size})
+}
+
+func (t *transferTracker) finish(path, hash string, size int64, skipped bool) {
+	t.mtx.Lock()
+	t.done++
+	if skipped {
+		t.skipped++
+	}
+	t.mtx.Unlock()
+
+	t.mngr.report(ProgressEvent{Type: ProgressTransferFinish, Op: t.op, Path: path, Hash: hash, Size: size, Skipped: skipped})
+}
+
+func (t *transferTracker) summary() *TransferSummary {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return &TransferSummary{
+		Objects: t.done,
+		Skipped: t.skipped,
+		Bytes:   t.session.TotalBytes(),
+		Elapsed: time.Since(t.startedAt).Seconds(),
+	}
+}
+
+func (t *transferTracker) event(eventType ProgressEventType) ProgressEvent {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	elapsed := time.Since(t.startedAt).Seconds()
+	bytes := t.session.TotalBytes()
+	event := ProgressEvent{
+		Type:           eventType,
+		Op:             t.op,
+		Done:           t.done,
+		Total:          t.total,
+		SkippedObjects: t.skipped,
+		Bytes:          bytes,
+		TotalBytes:     t.totalBytes,
+		Elapsed:        elapsed,
+	}
+	if elapsed > 0 {
+		event.Speed = float64(bytes) / elapsed
+	}
+	return event
+}
+
+// ttyProgressReporter renders the progress bar in place
+type ttyProgressReporter struct {
+	mtx        sync.Mutex
+	w          io.Writer
+	line       bool
+	lastRender time.Time
+}
+
+func NewTTYProgressReporter(w io.Writer) ProgressReporter {
+	return &ttyProgressReporter{w: w}
+}
+
+func (r *ttyProgressReporter) Report(event ProgressEvent) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	switch event.Type {
+	case ProgressHash:
+		if event.Done < event.Total && time.Since(r.lastRender) < 100*time.Millisecond {
+			return
+		}
+		r.render(fmt.Sprintf("hash files: %s (%d/%d)", progressBar(float64(event.Done), float64(event.Total)), event.Done, event.Total))
+		if event.Done >= event.Total {
+			r.endLine()
+		}
+	case ProgressTransfer:
+		r.render(formatTransferProgress(event, true))
+	case ProgressSummary:
+		r.render(formatTransferProgress(event, false))
+		r.endLine()
+	}
+}
+
+func (r *ttyProgressReporter) render(line string) {
+	fmt.Fprintf(r.w, "\r%s\x1b[K", line)
+	r.line = true
+	r.lastRender = time.Now()
+}
+
+func (r *ttyProgressReporter) endLine() {
+	if r.line {
+		fmt.Fprintln(r.w)
+		r.line = false
+	}
+}
+
+// plainProgressReporter prints a line per event. It is for the logs of the non-interactive environment.
+type plainProgressReporter struct {
+	mtx     sync.Mutex
+	w       io.Writer
+	started bool
+}
+
+func NewPlainProgressReporter(w io.Writer) ProgressReporter {
+	return &plainProgressReporter{w: w}
+}
+
+func (r *plainProgressReporter) Report(event ProgressEvent) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	switch event.Type {
+	case ProgressScanStart:
+		fmt.Fprintf(r.w, "scan workspace: %s\n", event.Path)
+	case ProgressHash:
+		if event.Done >= event.Total {
+			fmt.Fprintf(r.w, "hash files: %d\n", event.Total)
+		}
+	case ProgressTransfer:
+		if !r.started {
+			fmt.Fprintf(r.w, "%s objects: %d, size: %v\n", event.Op, event.Total, repository.ByteSize(event.TotalBytes))
+			r.started = true
+		}
+	case ProgressTransferFinish:
+		if event.Skipped {
+			fmt.Fprintf(r.w, "%s %s: skipped\n", event.Op, event.Path)
+		} else {
+			fmt.Fprintf(r.w, "%s %s: %v\n", event.Op, event.Path, repository.ByteSize(event.Size))
+		}
+	case ProgressSummary:
+		fmt.Fprintln(r.w, formatTransferProgress(event, false))
+		r.started = false
+	}
+}
+
+// jsonProgressReporter writes an event per line in JSON. The periodic progress events are throttled.
+type jsonProgressReporter struct {
+	mtx      sync.Mutex
+	encoder  *json.Encoder
+	lastSent map[ProgressEventType]time.Time
+}
+
+func NewJSONProgressReporter(w io.Writer) ProgressReporter {
+	return &jsonProgressReporter{
+		encoder:  json.NewEncoder(w),
+		lastSent: map[ProgressEventType]time.Time{},
+	}
+}
+
+func (r *jsonProgressReporter) Report(event ProgressEvent) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if event.Type == ProgressHash || event.Type == ProgressTransfer {
+		last, sent := r.lastSent[event.Type]
+		if sent && event.Done < event.Total && event.Time.Sub(last) < time.Second {
+			return
+		}
+		r.lastSent[event.Type] = event.Time
+	}
+
+	r.encoder.Encode(event)
+}
+
+func formatTransferProgress(event ProgressEvent, bar bool) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s objects: ", event.Op)
+	if bar {
+		sb.WriteString(progressBar(float64(event.Bytes), float64(event.TotalBytes)))
+		sb.WriteString(" ")
+	}
+	fmt.Fprintf(&sb, "(%d/%d)", event.Done, event.Total)
+	if event.SkippedObjects > 0 {
+		fmt.Fprintf(&sb, ", skipped: %d", event.SkippedObjects)
+	}
+	fmt.Fprintf(&sb, ", %v/%v, speed: %v/s", repository.ByteSize(event.Bytes), repository.ByteSize(event.TotalBytes), repository.ByteSize(event.Speed))
+
+	if event.Type == ProgressSummary {
+		fmt.Fprintf(&sb, ", elapsed: %v", time.Duration(event.Elapsed*float64(time.Second)).Round(time.Second))
+		if event.Error != "" {
+			fmt.Fprintf(&sb, ", failed: %s", event.Error)
+		}
+	} else if event.Speed > 0 && event.TotalBytes > event.Bytes {
+		eta := time.Duration(float64(event.TotalBytes-event.Bytes) / event.Speed * float64(time.Second))
+		fmt.Fprintf(&sb, ", ETA: %v", eta.Round(time.Second))
+	}
+
+	return sb.String()
+}
+
+func progressBar(current, total float64) string {
+	const width = 30
+
+	ratio := 1.0
+	if total > 0 {
+		ratio = current / total
+	}
+	if ratio > 1 {
+		ratio = 1
+	}
+
+	filled := int(ratio * width)
+	return fmt.Sprintf("[%s%s] %3d%%", strings.Repeat("=", filled), strings.Repeat(" ", width-filled), int(ratio*100))
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressEvents(t *testing.T) {
+	wp := t.TempDir()
+	meta := t.TempDir()
+	repo := t.TempDir()
+
+	assert.NoError(t, writeFile([]byte("a"), filepath.Join(wp, "a")))
+	assert.NoError(t, writeFile([]byte("bb"), filepath.Join(wp, "b")))
+
+	var mtx sync.Mutex
+	events := map[ProgressEventType][]ProgressEvent{}
+
+	mngr, err := NewArtifactManager(NewConfig(wp, meta, repo))
+	assert.NoError(t, err)
+	mngr.SetProgressReporter(ProgressReporterFunc(func(event ProgressEvent) {
+		mtx.Lock()
+		events[event.Type] = append(events[event.Type], event)
+		mtx.Unlock()
+	}))
+	_, err = mngr.Push(context.Background(), PushOptions{})
+	assert.NoError(t, err)
+
+	assert.Len(t, events[ProgressScanStart], 1)
+	assert.Equal(t, wp, events[ProgressScanStart][0].Path)
+	assert.Len(t, events[ProgressHash], 3)
+	assert.Len(t, events[ProgressTransferStart], 2)
+	assert.Len(t, events[ProgressTransferFinish], 2)
+
+	assert.Len(t, events[ProgressSummary], 1)
+	summary := events[ProgressSummary][0]
+	assert.Equal(t, "upload", summary.Op)
+	assert.Equal(t, 2, summary.Done)
+	assert.Equal(t, 2, summary.Total)
+	assert.Equal(t, int64(3), summary.Bytes)
+	assert.Equal(t, int64(3), summary.TotalBytes)
+	assert.Empty(t, summary.Error)
+}
+
+func TestPlainProgressReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewPlainProgressReporter(&buf)
+
+	r.Report(ProgressEvent{Type: ProgressTransfer, Op: "upload", Total: 2, TotalBytes: 2048})
+	r.Report(ProgressEvent{Type: ProgressTransfer, Op: "upload", Done: 1, Total: 2, Bytes: 1024, TotalBytes: 2048})
+	r.Report(ProgressEvent{Type: ProgressTransferFinish, Op: "upload", Path: "a", Size: 1024})
+	r.Report(ProgressEvent{Type: ProgressTransferFinish, Op: "upload", Path: "b", Size: 1024, Skipped: true})
+	r.Report(ProgressEvent{Type: ProgressSummary, Op: "upload", Done: 2, Total: 2, SkippedObjects: 1, Bytes: 2048, TotalBytes: 2048, Elapsed: 2, Speed: 1024})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, []string{
+		"upload objects: 2, size: 2.00KB",
+		"upload a: 1.00KB",
+		"upload b: skipped",
+		"upload objects: (2/2), skipped: 1, 2.00KB/2.00KB, speed: 1.00KB/s, elapsed: 2s",
+	}, lines)
+}
+
+func TestJSONProgressReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewJSONProgressReporter(&buf)
+
+	now := time.Now()
+	r.Report(ProgressEvent{Type: ProgressTransfer, Time: now, Op: "download", Total: 2})
+	// throttled
+	r.Report(ProgressEvent{Type: ProgressTransfer, Time: now.Add(100 * time.Millisecond), Op: "download", Total: 2})
+	r.Report(ProgressEvent{Type: ProgressTransferFinish, Time: now.Add(200 * time.Millisecond), Op: "download", Path: "a"})
+	r.Report(ProgressEvent{Type: ProgressTransfer, Time: now.Add(2 * time.Second), Op: "download", Done: 1, Total: 2})
+	r.Report(ProgressEvent{Type: ProgressSummary, Time: now.Add(3 * time.Second), Op: "download", Done: 2, Total: 2})
+
+	types := []ProgressEventType{}
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var event ProgressEvent
+		assert.NoError(t, decoder.Decode(&event))
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []ProgressEventType{ProgressTransfer, ProgressTransferFinish, ProgressTransfer, ProgressSummary}, types)
+}
+
+func TestNewProgressReporter(t *testing.T) {
+	var buf bytes.Buffer
+
+	r, err := NewProgressReporter(ProgressFormatAuto, &buf)
+	assert.NoError(t, err)
+	assert.IsType(t, &plainProgressReporter{}, r)
+
+	r, err = NewProgressReporter(ProgressFormatNone, &buf)
+	assert.NoError(t, err)
+	assert.Nil(t, r)
+
+	_, err = NewProgressReporter("foo", &buf)
+	assert.Error(t, err)
+}
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+)
+
+var remoteNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Remote is a named remote repository of the workspace
+type Remote struct {
+	Name    string `json:"name"`
+	Url     string `json:"url"`
+	Default bool   `json:"default,omitempty"`
+}
+
+// Remotes returns the named remotes sorted by the names
+func (config *ArtConfig) Remotes() []Remote {
+	sections, _ := config.Get("remote").(map[string]interface{})
+	defaultRemote := config.DefaultRemote()
+
+	remotes := []Remote{}
+	for name := range sections {
+		remotes = append(remotes, Remote{Name: name, Url: config.GetString("remote." + name + ".url"), Default: name == defaultRemote})
+	}
+	sort.Slice(remotes, func(i, j int) bool {
+		return remotes[i].Name < remotes[j].Name
+	})
+	return remotes
+}
+
+// GetRemote returns the named remote
+func (config *ArtConfig) GetRemote(name string) (Remote, error) {
+	for _, remote := range config.Remotes() {
+		if remote.Name == name {
+			return remote, nil
+		}
+	}
+	return Remote{}, fmt.Errorf("remote not found: %s", name)
+}
+
+// AddRemote adds a named remote
+func (config *ArtConfig) AddRemote(name, repoUrl string) error {
+	if !remoteNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid remote name: %s", name)
+	}
+
+	if _, err := config.GetRemote(name); err == nil {
+		return fmt.Errorf("remote already exists: %s", name)
+	}
+
+	config.Set("remote."+name+".url", repoUrl)
+	return nil
+}
+
+// RemoveRemote removes the named remote. If it is the default remote, "repo.url" becomes the default.
+func (config *ArtConfig) RemoveRemote(name string) error {
+	if _, err := config.GetRemote(name); err != nil {
+		return err
+	}
+
+	config.Unset("remote." + name)
+	if len(config.Remotes()) == 0 {
+		config.Unset("remote")
+	}
+	if config.DefaultRemote() == name {
+		config.Unset("repo.remote")
+	}
+	return nil
+}
+
+// RenameRemote renames the named remote with its settings
+func (config *ArtConfig) RenameRemote(name, newName string) error {
+	if _, err := config.GetRemote(name); err != nil {
+		return err
+	}
+
+	if !remoteNamePattern.MatchString(newName) {
+		return fmt.Errorf("invalid remote name: %s", newName)
+	}
+
+	if _, err := config.GetRemote(newName); err == nil {
+		return fmt.Errorf("remote already exists: %s", newName)
+	}
+
+	config.Set("remote."+newName, config.Get("remote."+name))
+	config.Unset("remote." + name)
+	if config.DefaultRemote() == name {
+		config.Set("repo.remote", newName)
+	}
+	return nil
+}
+
+// DefaultRemote returns the name of the default remote. It is empty if "repo.url" is the default.
+func (config *ArtConfig) DefaultRemote() string {
+	return config.GetString("repo.remote")
+}
+
+// SetDefaultRemote sets the default remote. An empty name makes "repo.url" the default.
+func (config *ArtConfig) SetDefaultRemote(name string) error {
+	if name == "" {
+		config.Unset("repo.remote")
+		return nil
+	}
+
+	if _, err := config.GetRemote(name); err != nil {
+		return err
+	}
+
+	config.Set("repo.remote", name)
+	return nil
+}
+
+// UseRemote selects the named remote as the repository. The metadata of the remote is kept separately in
+// ".avc/remotes/<name>", so the references of the remotes do not mix.
+func (config *ArtConfig) UseRemote(name string) error {
+	if _, err := config.GetRemote(name); err != nil {
+		return err
+	}
+
+	config.remote = name
+	config.MetadataDir = config.RemoteMetadataDir(name)
+	return nil
+}
+
+// Remote returns the name of the selected remote. It is empty if "repo.url" is used.
+func (config *ArtConfig) Remote() string {
+	return config.remote
+}
+
+// RemoteMetadataDir returns the metadata dir of the named remote in the workspace
+func (config *ArtConfig) RemoteMetadataDir(name string) string {
+	return path.Join(config.BaseDir, ".avc", "remotes", name)
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemotes(t *testing.T) {
+	wp := t.TempDir()
+	assert.NoError(t, InitWorkspace(wp, "/tmp/origin"))
+
+	config, err := LoadConfig(wp)
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/origin", config.RepoUrl())
+	assert.Equal(t, filepath.Join(wp, ".avc"), config.MetadataDir)
+
+	assert.NoError(t, config.AddRemote("team", "/tmp/team"))
+	assert.NoError(t, config.AddRemote("partner", "/tmp/partner"))
+	assert.Error(t, config.AddRemote("team", "/tmp/team2"))
+	assert.Error(t, config.AddRemote("a.b", "/tmp/ab"))
+	assert.Error(t, config.SetDefaultRemote("unknown"))
+	assert.NoError(t, config.SetDefaultRemote("team"))
+	config.Set("remote.partner.http.token", "secret")
+	config.Set("s3.endpoint", "http://localhost:9000")
+	config.Set("remote.partner.s3.path-style", true)
+	config.Set("remote.partner.gcs.user-project", "billing")
+	config.Set("remote.partner.ssh.port", "2222")
+	config.Set("remote.partner.ssh.connect-timeout", "10s")
+	assert.NoError(t, config.Save())
+
+	// the default remote is selected
+	config, err = LoadConfig(wp)
+	assert.NoError(t, err)
+	assert.Equal(t, []Remote{
+		{Name: "partner", Url: "/tmp/partner"},
+		{Name: "team", Url: "/tmp/team", Default: true},
+	}, config.Remotes())
+	assert.Equal(t, "team", config.Remote())
+	assert.Equal(t, "/tmp/team", config.RepoUrl())
+	assert.Equal(t, filepath.Join(wp, ".avc/remotes/team"), config.MetadataDir)
+
+	// the settings of the remote
+	repoConfig, _ := config.RepositoryConfig()
+	assert.Equal(t, "", repoConfig.Http.Token)
+	assert.False(t, repoConfig.S3.PathStyle)
+	assert.NoError(t, config.UseRemote("partner"))
+	repoConfig, _ = config.RepositoryConfig()
+	assert.Equal(t, "secret", repoConfig.Http.Token)
+	assert.Equal(t, "http://localhost:9000", repoConfig.S3.Endpoint)
+	assert.True(t, repoConfig.S3.PathStyle)
+	assert.Equal(t, "billing", repoConfig.GCS.UserProject)
+	assert.Equal(t, 2222, repoConfig.SSH.Port)
+	assert.Equal(t, 10*time.Second, repoConfig.SSH.ConnectTimeout)
+	assert.Error(t, config.UseRemote("unknown"))
+
+	// rename and remove
+	assert.NoError(t, config.RenameRemote("team", "team2"))
+	assert.Equal(t, "team2", config.DefaultRemote())
+	assert.Error(t, config.RenameRemote("team2", "partner"))
+	assert.NoError(t, config.RemoveRemote("team2"))
+	assert.Equal(t, "", config.DefaultRemote())
+	assert.NoError(t, config.RemoveRemote("partner"))
+	assert.Nil(t, config.Get("remote"))
+	assert.Error(t, config.RemoveRemote("partner"))
+}
+
+func TestRemoteMetadata(t *testing.T) {
+	ctx := context.Background()
+	wp := t.TempDir()
+	repo1 := t.TempDir()
+	repo2 := t.TempDir()
+
+	assert.NoError(t, writeFile([]byte("a"), filepath.Join(wp, "a.txt")))
+	assert.NoError(t, InitWorkspace(wp, repo1))
+	config, _ := LoadConfig(wp)
+	assert.NoError(t, config.AddRemote("backup", repo2))
+	assert.NoError(t, config.Save())
+
+	mngr1, err := NewArtifactManager(config)
+	assert.NoError(t, err)
+	result1, err := mngr1.Push(ctx, PushOptions{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, writeFile([]byte("b"), filepath.Join(wp, "b.txt")))
+	assert.NoError(t, config.UseRemote("backup"))
+	mngr2, err := NewArtifactManager(config)
+	assert.NoError(t, err)
+	result2, err := mngr2.Push(ctx, PushOptions{})
+	assert.NoError(t, err)
+
+	// the references of the remotes do not mix
+	ref1, _ := readFile(filepath.Join(wp, ".avc/refs/latest"))
+	assert.Equal(t, result1.Commit, string(ref1))
+	ref2, _ := readFile(filepath.Join(wp, ".avc/remotes/backup/refs/latest"))
+	assert.Equal(t, result2.Commit, string(ref2))
+
+	commit2, err := mngr2.GetCommit(ctx, result2.Commit)
+	assert.NoError(t, err)
+	assert.Equal(t, "", commit2.Parent)
+	assert.Len(t, commit2.Blobs, 2)
+}
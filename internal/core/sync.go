@@ -0,0 +1,378 @@
+package core
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/infuseai/artivc/internal/executor"
+	"github.com/infuseai/artivc/internal/log"
+	"github.com/infuseai/artivc/internal/repository"
+)
+
+type SyncOptions struct {
+	// List the changes without copying
+	DryRun bool
+	// Delete the tags of the destination which are not in the source
+	Delete bool
+	// Download the copied objects from the destination and verify the hashes. The objects streamed through the client
+	// are always verified during the copy, but only the sizes of the objects copied natively by the provider are
+	// checked without this.
+	Verify bool
+	// Write the static listing index to the destination for the plain HTTP mirrors. The existing index of the
+	// destination is always refreshed.
+	Index bool
+}
+
+// SyncRefChange is a reference created, updated or deleted in the destination
+type SyncRefChange struct {
+	Ref string `json:"ref"`
+	// The commit in the source. It is empty if the reference is deleted.
+	Commit string `json:"commit,omitempty"`
+	// The commit in the destination before the sync. It is empty if the reference is created.
+	Previous string `json:"previous,omitempty"`
+}
+
+type SyncResult struct {
+	DryRun bool `json:"dryRun,omitempty"`
+	// The number of the commits and objects missing in the destination
+	Commits int             `json:"commits"`
+	Objects int             `json:"objects"`
+	Refs    []SyncRefChange `json:"refs"`
+	// The objects copied by the sync. It is nil for the dry run.
+	Transfer *TransferSummary `json:"transfer,omitempty"`
+}
+
+// Sync copies the references, commits and objects of the repository to the destination repository. Only the commits
+// not in the destination are copied, and the objects of them are checked and copied if missing. The objects are
+// copied before the commits, and the commits before the references, so an interrupted sync can be resumed. If both the
+// repositories are of the same provider, the objects are copied natively and the content never leaves the provider.
+func (mngr *ArtifactManager) Sync(ctx context.Context, dst *ArtifactManager, options SyncOptions) (*SyncResult, error) {
+	log.Debugln("list the source references")
+	srcRefs, err := mngr.listRefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := srcRefs[RefLatest]; !ok {
+		return nil, ErrEmptyRepository
+	}
+
+	log.Debugln("list the destination references")
+	dstRefs, err := dst.listRefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// the commits
+	srcCommits, err := listNames(ctx, mngr.repo, "commits")
+	if err != nil {
+		return nil, err
+	}
+	dstCommits, err := listNames(ctx, dst.repo, "commits")
+	if repository.IsNotExist(err) {
+		// the destination may be empty
+		dstCommits = []string{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	existing := map[string]bool{}
+	for _, commit := range dstCommits {
+		existing[commit] = true
+	}
+
+	commits := []string{}
+	objects := map[string]int64{}
+	for _, hash := range srcCommits {
+		if existing[hash] {
+			continue
+		}
+
+		commit, err := mngr.GetCommit(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, hash)
+		for _, blob := range commit.Blobs {
+			if blob.Link == "" {
+				objects[blob.Hash] = blob.Size
+			}
+		}
+	}
+
+	// the references
+	refs := []SyncRefChange{}
+	for _, ref := range sortedKeys(srcRefs) {
+		if dstRefs[ref] != srcRefs[ref] {
+			refs = append(refs, SyncRefChange{Ref: ref, Commit: srcRefs[ref], Previous: dstRefs[ref]})
+		}
+	}
+	if options.Delete {
+		for _, ref := range sortedKeys(dstRefs) {
+			if _, ok := srcRefs[ref]; !ok && ref != RefLatest {
+				refs = append(refs, SyncRefChange{Ref: ref, Previous: dstRefs[ref]})
+			}
+		}
+	}
+
+	result := &SyncResult{DryRun: options.DryRun, Commits: len(commits), Refs: refs}
+
+	// find the missing objects
+	pending := map[string]bool{}
+	mtx := sync.Mutex{}
+	statTasks := []executor.TaskFunc{}
+	for hash := range objects {
+		hash := hash
+		statTasks = append(statTasks, func(ctx context.Context) error {
+			if _, err := dst.repo.Stat(ctx, MakeObjectPath(hash)); err == nil {
+				return nil
+			}
+
+			mtx.Lock()
+			pending[hash] = true
+			mtx.Unlock()
+			return nil
+		})
+	}
+	if err := executor.ExecuteAllWithContext(ctx, dst.transfer.Concurrency, statTasks...); err != nil {
+		return nil, err
+	}
+	result.Objects = len(pending)
+
+	if options.DryRun {
+		return result, nil
+	}
+
+	// copy the objects
+	session := repository.NewSession()
+	if !dst.transfer.BandwidthLimit.Upload.IsUnlimited() {
+		session.SetRateLimiter(repository.NewRateLimiter(dst.transfer.BandwidthLimit.Upload))
+	}
+	tracker := newTransferTracker(dst, "copy", session)
+	copyTasks := []executor.TaskFunc{}
+	hashes := []string{}
+	for hash := range pending {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		hash, size := hash, objects[hash]
+		tracker.add(size)
+		copyTasks = append(copyTasks, func(ctx context.Context) error {
+			objectPath := MakeObjectPath(hash)
+			tracker.start(objectPath, hash, size)
+			meter := session.NewMeter()
+			native := true
+			err := repository.Copy(ctx, mngr.repo, objectPath, dst.repo, objectPath)
+			if errors.Is(err, repository.ErrCopyNotSupported) {
+				native = false
+				err = copyObject(ctx, mngr.repo, dst.repo, objectPath, sha1.New(), hash, meter)
+			} else if err != nil {
+				err = fmt.Errorf("copy %s: %w", objectPath, err)
+			} else {
+				// the content did not pass through the client
+				meter.AddBytes(int(size))
+			}
+			if err != nil {
+				return err
+			}
+
+			// the natively copied object is not verified during the copy, so delete the corrupted one
+			if native {
+				if err := checkObjectSize(ctx, dst.repo, objectPath, size); err != nil {
+					dst.repo.Delete(ctx, objectPath)
+					return err
+				}
+			}
+			if options.Verify {
+				if err := verifyObject(ctx, dst.repo, objectPath, hash); err != nil {
+					if native {
+						dst.repo.Delete(ctx, objectPath)
+					}
+					return err
+				}
+			}
+
+			tracker.finish(objectPath, hash, size, false)
+			return nil
+		})
+	}
+	if err := dst.executeTransfers(ctx, tracker, copyTasks); err != nil {
+		return nil, err
+	}
+	result.Transfer = tracker.summary()
+
+	// copy the commits
+	commitTasks := []executor.TaskFunc{}
+	for _, hash := range commits {
+		commitPath := MakeCommitPath(hash)
+		commitTasks = append(commitTasks, func(ctx context.Context) error {
+			err := repository.Copy(ctx, mngr.repo, commitPath, dst.repo, commitPath)
+			if errors.Is(err, repository.ErrCopyNotSupported) {
+				return copyObject(ctx, mngr.repo, dst.repo, commitPath, nil, "", nil)
+			}
+			return err
+		})
+	}
+	if err := executor.ExecuteAllWithContext(ctx, dst.transfer.Concurrency, commitTasks...); err != nil {
+		return nil, err
+	}
+
+	// update the references. the latest is the last one.
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[j].Ref == RefLatest && refs[i].Ref != RefLatest
+	})
+	for _, change := range refs {
+		if change.Commit == "" {
+			log.Debugf("delete ref: %s\n", change.Ref)
+			if err := dst.DeleteRef(ctx, change.Ref); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		log.Debugf("update ref: %s -> %s\n", change.Ref, change.Commit)
+		if err := dst.AddRef(ctx, change.Ref, change.Commit); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Index {
+		err = dst.WriteIndex(ctx)
+	} else {
+		err = dst.updateIndex(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// listRefs returns the commits of the latest and the tags. The tags are prefixed with "tags/".
+func (mngr *ArtifactManager) listRefs(ctx context.Context) (map[string]string, error) {
+	refs := map[string]string{}
+	if commit, err := mngr.GetRef(ctx, RefLatest); err == nil {
+		refs[RefLatest] = commit
+	} else if !repository.IsNotExist(err) {
+		return nil, err
+	}
+
+	// a missing tag would be deleted from the destination, so only the missing dir means no tags
+	tags, err := listNames(ctx, mngr.repo, "refs/tags")
+	if repository.IsNotExist(err) {
+		return refs, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		commit, err := mngr.GetRef(ctx, "tags/"+tag)
+		if err != nil {
+			return nil, err
+		}
+		refs["tags/"+tag] = commit
+	}
+	return refs, nil
+}
+
+// listNames lists the names of the files in the directory of the repository
+func listNames(ctx context.Context, repo repository.Repository, dir string) ([]string, error) {
+	entries, err := repo.List(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// copyObject streams the object from the source repository to the destination. If the hasher is given, the content
+// is verified against the hash before the upload completes, so a corrupted object is not created.
+func copyObject(ctx context.Context, src, dst repository.Repository, repoPath string, hasher hash.Hash, expected string, meter *repository.Meter) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(src.DownloadStream(ctx, repoPath, pw, nil))
+	}()
+	defer pr.Close()
+
+	var r io.Reader = pr
+	if hasher != nil {
+		r = &verifyReader{reader: pr, hasher: hasher, expected: expected, path: repoPath}
+	}
+
+	if err := dst.UploadStream(ctx, r, repoPath, meter); err != nil {
+		return fmt.Errorf("copy %s: %w", repoPath, err)
+	}
+	return nil
+}
+
+// checkObjectSize checks the object exists and has the expected size if the backend reports it
+func checkObjectSize(ctx context.Context, repo repository.Repository, repoPath string, expected int64) error {
+	info, err := repo.Stat(ctx, repoPath)
+	if err != nil {
+		return fmt.Errorf("check %s: %w", repoPath, err)
+	}
+
+	if size, ok := repository.FileSize(info); ok && size != expected {
+		return fmt.Errorf("check %s: the size is %d, expected %d", repoPath, size, expected)
+	}
+	return nil
+}
+
+// verifyObject downloads the object and checks the hash
+func verifyObject(ctx context.Context, repo repository.Repository, repoPath, expected string) error {
+	hasher := sha1.New()
+	if err := repo.DownloadStream(ctx, repoPath, hasher, nil); err != nil {
+		return fmt.Errorf("verify %s: %w", repoPath, err)
+	}
+
+	if actual := fmt.Sprintf("%x", hasher.Sum(nil)); actual != expected {
+		return fmt.Errorf("verify %s: the hash is %s", repoPath, actual)
+	}
+	return nil
+}
+
+// verifyReader hashes the content and fails at the end if the hash does not match
+type verifyReader struct {
+	reader   io.Reader
+	hasher   hash.Hash
+	expected string
+	path     string
+}
+
+func (r *verifyReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.hasher.Write(p[:n])
+	if err == io.EOF {
+		if actual := fmt.Sprintf("%x", r.hasher.Sum(nil)); actual != r.expected {
+			return n, fmt.Errorf("the hash of %s is %s", r.path, actual)
+		}
+	}
+	return n, err
+}
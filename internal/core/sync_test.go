@@ -0,0 +1,229 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/infuseai/artivc/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSyncManager(t *testing.T, repo string) *ArtifactManager {
+	metadataDir := t.TempDir()
+	mngr, err := NewArtifactManager(NewConfig(metadataDir, metadataDir, repo))
+	assert.NoError(t, err)
+	return mngr
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	wp := t.TempDir()
+	srcRepo := t.TempDir()
+	dstRepo := t.TempDir()
+
+	assert.NoError(t, writeFile([]byte("a"), filepath.Join(wp, "a.txt")))
+	assert.NoError(t, writeFile([]byte("b"), filepath.Join(wp, "b.txt")))
+	pushMngr, err := NewArtifactManager(NewConfig(wp, t.TempDir(), srcRepo))
+	assert.NoError(t, err)
+	tag := "v1"
+	_, err = pushMngr.Push(ctx, PushOptions{Tag: &tag})
+	assert.NoError(t, err)
+
+	// dry run
+	result, err := newSyncManager(t, srcRepo).Sync(ctx, newSyncManager(t, dstRepo), SyncOptions{DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Commits)
+	assert.Equal(t, 2, result.Objects)
+	assert.Len(t, result.Refs, 2)
+	_, err = os.Stat(filepath.Join(dstRepo, "refs/latest"))
+	assert.True(t, os.IsNotExist(err))
+
+	// sync to the empty repository
+	result, err = newSyncManager(t, srcRepo).Sync(ctx, newSyncManager(t, dstRepo), SyncOptions{Verify: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Transfer.Objects)
+
+	// the local objects are copied natively by the hard links
+	objectPath := MakeObjectPath(Sha1Sum([]byte("a")))
+	srcInfo, err := os.Stat(filepath.Join(srcRepo, objectPath))
+	assert.NoError(t, err)
+	dstInfo, err := os.Stat(filepath.Join(dstRepo, objectPath))
+	assert.NoError(t, err)
+	assert.True(t, os.SameFile(srcInfo, dstInfo))
+
+	wp2 := t.TempDir()
+	pullMngr, err := NewArtifactManager(NewConfig(wp2, t.TempDir(), dstRepo))
+	assert.NoError(t, err)
+	_, err = pullMngr.Pull(ctx, PullOptions{RefOrCommit: &tag})
+	assert.NoError(t, err)
+	data, _ := readFile(filepath.Join(wp2, "b.txt"))
+	assert.Equal(t, "b", string(data))
+
+	// the second commit. the existing objects are not copied.
+	assert.NoError(t, writeFile([]byte("c"), filepath.Join(wp, "c.txt")))
+	_, err = pushMngr.Push(ctx, PushOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, pushMngr.DeleteTag(ctx, tag))
+
+	result, err = newSyncManager(t, srcRepo).Sync(ctx, newSyncManager(t, dstRepo), SyncOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Commits)
+	assert.Equal(t, 1, result.Objects)
+	assert.Equal(t, []string{RefLatest}, syncRefNames(result.Refs))
+	_, err = os.Stat(filepath.Join(dstRepo, "refs/tags/v1"))
+	assert.NoError(t, err)
+
+	// delete the tag
+	result, err = newSyncManager(t, srcRepo).Sync(ctx, newSyncManager(t, dstRepo), SyncOptions{Delete: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Objects)
+	assert.Equal(t, []SyncRefChange{{Ref: "tags/v1", Previous: result.Refs[0].Previous}}, result.Refs)
+	_, err = os.Stat(filepath.Join(dstRepo, "refs/tags/v1"))
+	assert.True(t, os.IsNotExist(err))
+
+	srcLatest, _ := readFile(filepath.Join(srcRepo, "refs/latest"))
+	dstLatest, _ := readFile(filepath.Join(dstRepo, "refs/latest"))
+	assert.Equal(t, string(srcLatest), string(dstLatest))
+}
+
+// streamOnlyRepository hides the native copy of the repository
+type streamOnlyRepository struct {
+	repository.Repository
+}
+
+func TestSyncCorruptedObject(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		desc      string
+		corrupted string
+		native    bool
+		verify    bool
+	}{
+		{desc: "stream", corrupted: "corrupted", native: false},
+		{desc: "native with the different size", corrupted: "corrupted", native: true},
+		{desc: "native with the same size", corrupted: "b", native: true, verify: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			wp := t.TempDir()
+			srcRepo := t.TempDir()
+			dstRepo := t.TempDir()
+
+			assert.NoError(t, writeFile([]byte("a"), filepath.Join(wp, "a.txt")))
+			pushMngr, err := NewArtifactManager(NewConfig(wp, t.TempDir(), srcRepo))
+			assert.NoError(t, err)
+			_, err = pushMngr.Push(ctx, PushOptions{})
+			assert.NoError(t, err)
+
+			objectPath := MakeObjectPath(Sha1Sum([]byte("a")))
+			assert.NoError(t, writeFile([]byte(tC.corrupted), filepath.Join(srcRepo, objectPath)))
+
+			// the size of the natively copied objects is always checked, and the hash is verified by the option
+			dst := newSyncManager(t, dstRepo)
+			if !tC.native {
+				dst.repo = &streamOnlyRepository{dst.repo}
+			}
+			_, err = newSyncManager(t, srcRepo).Sync(ctx, dst, SyncOptions{Verify: tC.verify})
+			assert.Error(t, err)
+
+			// neither the object nor the reference is created
+			_, err = os.Stat(filepath.Join(dstRepo, objectPath))
+			assert.True(t, os.IsNotExist(err))
+			_, err = os.Stat(filepath.Join(dstRepo, "refs/latest"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+
+	_, err := newSyncManager(t, t.TempDir()).Sync(ctx, newSyncManager(t, t.TempDir()), SyncOptions{})
+	assert.ErrorIs(t, err, ErrEmptyRepository)
+}
+
+// listFailingRepository fails all the listings with the error
+type listFailingRepository struct {
+	repository.Repository
+	err error
+}
+
+func (repo *listFailingRepository) List(ctx context.Context, repoPath string) ([]repository.FileInfo, error) {
+	return nil, repo.err
+}
+
+func TestSyncIndex(t *testing.T) {
+	ctx := context.Background()
+	wp := t.TempDir()
+	srcRepo := t.TempDir()
+	dstRepo := t.TempDir()
+
+	assert.NoError(t, writeFile([]byte("a"), filepath.Join(wp, "a.txt")))
+	pushMngr, err := NewArtifactManager(NewConfig(wp, t.TempDir(), srcRepo))
+	assert.NoError(t, err)
+	_, err = pushMngr.Push(ctx, PushOptions{})
+	assert.NoError(t, err)
+
+	// the index is written by the option
+	_, err = newSyncManager(t, srcRepo).Sync(ctx, newSyncManager(t, dstRepo), SyncOptions{})
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dstRepo, repository.IndexPath))
+	assert.True(t, os.IsNotExist(err))
+	_, err = newSyncManager(t, srcRepo).Sync(ctx, newSyncManager(t, dstRepo), SyncOptions{Index: true})
+	assert.NoError(t, err)
+
+	// and refreshed by the later sync
+	tag := "v1"
+	assert.NoError(t, pushMngr.AddTag(ctx, RefLatest, tag))
+	_, err = newSyncManager(t, srcRepo).Sync(ctx, newSyncManager(t, dstRepo), SyncOptions{})
+	assert.NoError(t, err)
+
+	ts := httptest.NewServer(http.FileServer(http.Dir(dstRepo)))
+	defer ts.Close()
+	mirror, err := repository.NewHttpRepository(ts.URL, repository.HttpConfig{})
+	assert.NoError(t, err)
+	entries, err := mirror.List(ctx, "refs/tags")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, tag, entries[0].Name())
+	}
+}
+
+func TestSyncListError(t *testing.T) {
+	ctx := context.Background()
+	wp := t.TempDir()
+	srcRepo := t.TempDir()
+	dstRepo := t.TempDir()
+
+	assert.NoError(t, writeFile([]byte("a"), filepath.Join(wp, "a.txt")))
+	pushMngr, err := NewArtifactManager(NewConfig(wp, t.TempDir(), srcRepo))
+	assert.NoError(t, err)
+	tag := "v1"
+	_, err = pushMngr.Push(ctx, PushOptions{Tag: &tag})
+	assert.NoError(t, err)
+	_, err = newSyncManager(t, srcRepo).Sync(ctx, newSyncManager(t, dstRepo), SyncOptions{})
+	assert.NoError(t, err)
+
+	// the tags of the destination are not deleted if the source cannot be listed
+	src := newSyncManager(t, srcRepo)
+	src.repo = &listFailingRepository{src.repo, os.ErrPermission}
+	_, err = src.Sync(ctx, newSyncManager(t, dstRepo), SyncOptions{Delete: true})
+	assert.ErrorIs(t, err, os.ErrPermission)
+	_, err = os.Stat(filepath.Join(dstRepo, "refs/tags/v1"))
+	assert.NoError(t, err)
+
+	// nor is everything copied again if the destination cannot be listed
+	dst := newSyncManager(t, dstRepo)
+	dst.repo = &listFailingRepository{dst.repo, os.ErrPermission}
+	_, err = newSyncManager(t, srcRepo).Sync(ctx, dst, SyncOptions{})
+	assert.ErrorIs(t, err, os.ErrPermission)
+}
+
+func syncRefNames(changes []SyncRefChange) []string {
+	names := []string{}
+	for _, change := range changes {
+		names = append(names, change.Ref)
+	}
+	return names
+}
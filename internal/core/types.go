@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/infuseai/artivc/internal/repository"
 )
 
 const (
@@ -23,23 +25,84 @@ type BlobMetaData struct {
 }
 
 type Commit struct {
-	CreatedAt time.Time      `json:"createdAt"`
-	Parent    string         `json:"parent,omitempty"`
-	Message   *string        `json:"messaage,omitempty"`
-	Blobs     []BlobMetaData `json:"blobs"`
+	CreatedAt time.Time `json:"createdAt"`
+	Parent    string    `json:"parent,omitempty"`
+	Message   *string   `json:"messaage,omitempty"`
+	// The author of the imported commit, e.g. "name <email>"
+	Author *string        `json:"author,omitempty"`
+	Blobs  []BlobMetaData `json:"blobs"`
 }
 
 type PushOptions struct {
 	DryRun  bool
 	Message *string
 	Tag     *string
+	// Write the static listing index even if it is not enabled by the config
+	Index bool
+}
+
+type PushResult struct {
+	DryRun bool       `json:"dryRun,omitempty"`
+	Diff   DiffResult `json:"diff"`
+	// The created commit. It is empty for dry run or nothing changed.
+	Commit   string           `json:"commit,omitempty"`
+	Tag      string           `json:"tag,omitempty"`
+	Transfer *TransferSummary `json:"transfer,omitempty"`
+}
+
+type PullResult struct {
+	DryRun bool       `json:"dryRun,omitempty"`
+	Diff   DiffResult `json:"diff"`
+	// The pulled commit
+	Commit   string           `json:"commit,omitempty"`
+	Transfer *TransferSummary `json:"transfer,omitempty"`
+}
+
+type TransferSummary struct {
+	Objects int   `json:"objects"`
+	Skipped int   `json:"skipped"`
+	Bytes   int64 `json:"bytes"`
+	// The elapsed seconds
+	Elapsed float64 `json:"elapsed"`
+}
+
+type LogEntry struct {
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+	Parent    string    `json:"parent,omitempty"`
+	Message   string    `json:"message"`
+	Author    string    `json:"author,omitempty"`
+	// The latest reference and the tags pointing to the commit
+	Refs []string `json:"refs,omitempty"`
+}
+
+type TagEntry struct {
+	Name   string `json:"name"`
+	Commit string `json:"commit"`
 }
 
 type ChangeMode int
 
+const DefaultMaxConcurrency = 64
+
+type TransferConfig struct {
+	// The number of concurrent transfers. It is the initial number in the adaptive mode.
+	Concurrency int
+	// Grow or shrink the number of concurrent transfers by the throughput
+	Adaptive bool
+	// The upper bound of the number of concurrent transfers in the adaptive mode
+	MaxConcurrency int
+	// The number of concurrent file hashing
+	HashConcurrency int
+	BandwidthLimit  repository.BandwidthLimit
+}
+
+type FetchOptions struct {
+	All bool
+}
+
 type PullOptions struct {
 	DryRun      bool
-	NoFetch     bool
 	Delete      bool
 	RefOrCommit *string
 	FileFilter  PathFilter
@@ -68,22 +131,52 @@ const (
 	DiffTypeRename
 )
 
+var diffTypeNames = []string{"add", "delete", "change", "rename"}
+
+func (t DiffType) String() string {
+	if int(t) < 0 || int(t) >= len(diffTypeNames) {
+		return fmt.Sprintf("DiffType(%d)", int(t))
+	}
+	return diffTypeNames[t]
+}
+
+func (t DiffType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *DiffType) UnmarshalText(text []byte) error {
+	for i, name := range diffTypeNames {
+		if name == string(text) {
+			*t = DiffType(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown diff type: %s", text)
+}
+
 type DiffRecord struct {
-	Type    DiffType
-	Hash    string
-	Link    string
-	Path    string
-	Size    int64
-	Mode    fs.FileMode
-	OldPath string
-	OldLink string
-	OldHash string
-	OldSize int64
-	OldMode fs.FileMode
+	Type    DiffType    `json:"type"`
+	Hash    string      `json:"hash,omitempty"`
+	Link    string      `json:"link,omitempty"`
+	Path    string      `json:"path"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	OldPath string      `json:"oldPath,omitempty"`
+	OldLink string      `json:"oldLink,omitempty"`
+	OldHash string      `json:"oldHash,omitempty"`
+	OldSize int64       `json:"oldSize,omitempty"`
+	OldMode fs.FileMode `json:"oldMode,omitempty"`
 }
 
 type DiffResult struct {
-	Records []DiffRecord
+	Records []DiffRecord `json:"records"`
+}
+
+type DiffStats struct {
+	Added    int `json:"added"`
+	Deleted  int `json:"deleted"`
+	Modified int `json:"modified"`
+	Renamed  int `json:"renamed"`
 }
 
 type BlobDownloadResult struct {
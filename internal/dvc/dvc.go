@@ -0,0 +1,403 @@
+// Package dvc reads the data tracked by a DVC project. The outputs of the ".dvc" and "dvc.lock" files are resolved to
+// the files in the DVC cache or a local DVC remote, which are addressed by the MD5 hashes.
+package dvc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/internal/log"
+	"gopkg.in/yaml.v2"
+)
+
+// Output is a file or directory tracked by DVC
+type Output struct {
+	// The slash-separated path relative to the project
+	Path string
+	// The MD5 hash. The hash of a directory has the ".dir" suffix.
+	MD5  string
+	Size int64
+}
+
+// IsDir checks if the output is a directory
+func (o Output) IsDir() bool {
+	return strings.HasSuffix(o.MD5, ".dir")
+}
+
+type dvcOut struct {
+	Path string `yaml:"path"`
+	MD5  string `yaml:"md5"`
+	Size int64  `yaml:"size"`
+}
+
+type dvcFile struct {
+	Outs []dvcOut `yaml:"outs"`
+}
+
+type lockFile struct {
+	// the stages in the order of the file. The order of a map is random, which would change the commit.
+	Stages yaml.MapSlice `yaml:"stages"`
+}
+
+type lockStage struct {
+	Outs []dvcOut `yaml:"outs"`
+}
+
+// ParseDVCFile parses the outputs of the ".dvc" file. The paths are relative to the directory of the file.
+func ParseDVCFile(data []byte, dir string) ([]Output, error) {
+	var file dvcFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	return makeOutputs(file.Outs, dir)
+}
+
+// ParseLockFile parses the outputs of the stages in the "dvc.lock" file. The outputs are in the order of the stages in
+// the file. The paths are relative to the directory of the file.
+func ParseLockFile(data []byte, dir string) ([]Output, error) {
+	var file lockFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	outs := []dvcOut{}
+	for _, item := range file.Stages {
+		// decode the stage from the generic value
+		data, err := yaml.Marshal(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		var stage lockStage
+		if err := yaml.Unmarshal(data, &stage); err != nil {
+			return nil, fmt.Errorf("invalid stage %v: %w", item.Key, err)
+		}
+		outs = append(outs, stage.Outs...)
+	}
+	return makeOutputs(outs, dir)
+}
+
+func makeOutputs(outs []dvcOut, dir string) ([]Output, error) {
+	outputs := []Output{}
+	for _, out := range outs {
+		if out.MD5 == "" {
+			// the output is not committed or not cached
+			log.Debugf("skip the output without the md5: %s\n", out.Path)
+			continue
+		}
+
+		outputPath := path.Join(dir, filepath.ToSlash(out.Path))
+		if outputPath == ".." || strings.HasPrefix(outputPath, "../") || path.IsAbs(outputPath) {
+			return nil, fmt.Errorf("the output is outside of the project: %s", out.Path)
+		}
+
+		outputs = append(outputs, Output{Path: outputPath, MD5: out.MD5, Size: out.Size})
+	}
+	return outputs, nil
+}
+
+// Snapshot is the state of the DVC files in the working tree or in a git revision
+type Snapshot struct {
+	// The git commit. It is empty for the working tree.
+	Revision  string
+	CreatedAt time.Time
+	Message   string
+
+	// list the slash-separated paths of the DVC files
+	list func() ([]string, error)
+	read func(name string) ([]byte, error)
+}
+
+// Outputs returns the outputs of all the ".dvc" and "dvc.lock" files of the snapshot
+func (s *Snapshot) Outputs() ([]Output, error) {
+	names, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := []Output{}
+	for _, name := range names {
+		data, err := s.read(name)
+		if err != nil {
+			return nil, err
+		}
+
+		var fileOutputs []Output
+		if path.Base(name) == "dvc.lock" {
+			fileOutputs, err = ParseLockFile(data, path.Dir(name))
+		} else {
+			fileOutputs, err = ParseDVCFile(data, path.Dir(name))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		outputs = append(outputs, fileOutputs...)
+	}
+	return outputs, nil
+}
+
+func isDVCFile(name string) bool {
+	return strings.HasSuffix(name, ".dvc") || path.Base(name) == "dvc.lock"
+}
+
+// Project is a DVC project on the disk
+type Project struct {
+	dir string
+	// the cache and the local remotes to find the objects
+	cacheDirs []string
+	// the sha1 of the objects by the md5
+	sha1s map[string]string
+}
+
+// NewProject opens the DVC project. The objects are looked up in the cache dirs in order. The cache of the project
+// is used if no cache dir is given.
+func NewProject(dir string, cacheDirs []string) (*Project, error) {
+	info, err := os.Stat(filepath.Join(dir, ".dvc"))
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("not a DVC project: %s", dir)
+	}
+
+	if len(cacheDirs) == 0 {
+		cacheDirs = []string{filepath.Join(dir, ".dvc", "cache")}
+	}
+
+	return &Project{dir: dir, cacheDirs: cacheDirs, sha1s: map[string]string{}}, nil
+}
+
+// WorkingTree returns the snapshot of the DVC files in the working tree
+func (p *Project) WorkingTree() *Snapshot {
+	return &Snapshot{
+		CreatedAt: time.Now(),
+		list: func() ([]string, error) {
+			names := []string{}
+			err := filepath.WalkDir(p.dir, func(absPath string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if d.IsDir() {
+					if d.Name() == ".dvc" || d.Name() == ".git" {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+
+				name, err := filepath.Rel(p.dir, absPath)
+				if err != nil {
+					return err
+				}
+				name = filepath.ToSlash(name)
+				if isDVCFile(name) {
+					names = append(names, name)
+				}
+				return nil
+			})
+			return names, err
+		},
+		read: func(name string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(p.dir, filepath.FromSlash(name)))
+		},
+	}
+}
+
+// History returns the snapshots of the git commits changing the DVC files, from the oldest to the newest. Only the
+// first parents of the merges are followed. It requires the git command.
+func (p *Project) History(ctx context.Context) ([]*Snapshot, error) {
+	out, err := p.git(ctx, "log", "--reverse", "--first-parent", "--format=%H%x00%cI%x00%B%x1e", "HEAD", "--", "*.dvc", "*dvc.lock")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []*Snapshot{}
+	for _, record := range strings.Split(string(out), "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x00", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		createdAt, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			return nil, err
+		}
+
+		rev := fields[0]
+		snapshots = append(snapshots, &Snapshot{
+			Revision:  rev,
+			CreatedAt: createdAt,
+			Message:   strings.TrimSpace(fields[2]),
+			list: func() ([]string, error) {
+				out, err := p.git(ctx, "ls-tree", "-r", "--name-only", rev)
+				if err != nil {
+					return nil, err
+				}
+
+				names := []string{}
+				for _, name := range strings.Split(string(out), "\n") {
+					if isDVCFile(name) && !strings.HasPrefix(name, ".dvc/") {
+						names = append(names, name)
+					}
+				}
+				return names, nil
+			},
+			read: func(name string) ([]byte, error) {
+				return p.git(ctx, "show", rev+":./"+name)
+			},
+		})
+	}
+	return snapshots, nil
+}
+
+func (p *Project) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", p.dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// objectPath finds the object of the md5 in the cache dirs. Both the layouts of DVC 2 ("ab/cdef...") and DVC 3
+// ("files/md5/ab/cdef...") are supported.
+func (p *Project) objectPath(md5 string) (string, error) {
+	if len(md5) < 3 {
+		return "", fmt.Errorf("invalid md5: %s", md5)
+	}
+
+	for _, cacheDir := range p.cacheDirs {
+		for _, objectPath := range []string{
+			filepath.Join(cacheDir, "files", "md5", md5[:2], md5[2:]),
+			filepath.Join(cacheDir, md5[:2], md5[2:]),
+		} {
+			if _, err := os.Stat(objectPath); err == nil {
+				return objectPath, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("the object %s is not found in the cache. Please fetch it by \"dvc fetch\" first: %w", md5, os.ErrNotExist)
+}
+
+type dirEntry struct {
+	MD5     string `json:"md5"`
+	RelPath string `json:"relpath"`
+}
+
+// Files resolves the outputs of the snapshot to the files to import. The files are in the order of the outputs, and
+// a later output overwrites the same path of an earlier one.
+func (p *Project) Files(snapshot *Snapshot) ([]core.ImportFile, error) {
+	outputs, err := snapshot.Outputs()
+	if err != nil {
+		return nil, err
+	}
+
+	files := []core.ImportFile{}
+	indexes := map[string]int{}
+	add := func(filePath, md5 string) error {
+		file, err := p.file(filePath, md5)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filePath, err)
+		}
+
+		if i, ok := indexes[filePath]; ok {
+			files[i] = file
+			return nil
+		}
+		indexes[filePath] = len(files)
+		files = append(files, file)
+		return nil
+	}
+
+	for _, output := range outputs {
+		if !output.IsDir() {
+			if err := add(output.Path, output.MD5); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		entries, err := p.readDir(output.MD5)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", output.Path, err)
+		}
+
+		for _, entry := range entries {
+			if err := add(path.Join(output.Path, filepath.ToSlash(entry.RelPath)), entry.MD5); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+func (p *Project) readDir(md5 string) ([]dirEntry, error) {
+	objectPath, err := p.objectPath(md5)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(objectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []dirEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid directory object %s: %w", md5, err)
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(path.Clean("/"+filepath.ToSlash(entry.RelPath)), "/..") || entry.RelPath == "" {
+			return nil, errors.New("invalid path in the directory object: " + entry.RelPath)
+		}
+	}
+	return entries, nil
+}
+
+func (p *Project) file(filePath, md5 string) (core.ImportFile, error) {
+	objectPath, err := p.objectPath(md5)
+	if err != nil {
+		return core.ImportFile{}, err
+	}
+
+	info, err := os.Stat(objectPath)
+	if err != nil {
+		return core.ImportFile{}, err
+	}
+
+	hash, ok := p.sha1s[md5]
+	if !ok {
+		hash, err = core.Sha1SumFromFile(objectPath)
+		if err != nil {
+			return core.ImportFile{}, err
+		}
+		p.sha1s[md5] = hash
+	}
+
+	return core.ImportFile{
+		BlobMetaData: core.BlobMetaData{
+			Path: filePath,
+			Hash: hash,
+			// the objects in the DVC cache are read-only
+			Mode: 0644,
+			Size: info.Size(),
+		},
+		Open: func() (io.ReadCloser, error) {
+			return os.Open(objectPath)
+		},
+	}, nil
+}
@@ -0,0 +1,246 @@
+package dvc
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/infuseai/artivc/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeObject writes the content to the DVC cache and returns the md5
+func writeObject(t *testing.T, cacheDir string, content []byte, dvc3 bool) string {
+	hash := fmt.Sprintf("%x", md5.Sum(content))
+	return writeObjectAs(t, cacheDir, hash, content, dvc3)
+}
+
+func writeObjectAs(t *testing.T, cacheDir, hash string, content []byte, dvc3 bool) string {
+	dir := filepath.Join(cacheDir, hash[:2])
+	if dvc3 {
+		dir = filepath.Join(cacheDir, "files", "md5", hash[:2])
+	}
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, hash[2:]), content, 0444))
+	return hash
+}
+
+func writeDirObject(t *testing.T, cacheDir string, entries []dirEntry, dvc3 bool) string {
+	data, _ := json.Marshal(entries)
+	return writeObjectAs(t, cacheDir, fmt.Sprintf("%x", md5.Sum(data))+".dir", data, dvc3)
+}
+
+func writeProjectFile(t *testing.T, dir, name, content string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
+
+func TestParseDVCFile(t *testing.T) {
+	outputs, err := ParseDVCFile([]byte(`
+outs:
+- md5: 0cc175b9c0f1b6a831c399e269772661
+  size: 1
+  hash: md5
+  path: a.txt
+- md5: 3863d9c8dbe3e1e9bbc2b3c8a2b1e5f8.dir
+  size: 2
+  nfiles: 2
+  path: images
+- path: uncommitted
+`), "data")
+	assert.NoError(t, err)
+	assert.Equal(t, []Output{
+		{Path: "data/a.txt", MD5: "0cc175b9c0f1b6a831c399e269772661", Size: 1},
+		{Path: "data/images", MD5: "3863d9c8dbe3e1e9bbc2b3c8a2b1e5f8.dir", Size: 2},
+	}, outputs)
+	assert.True(t, outputs[1].IsDir())
+
+	_, err = ParseDVCFile([]byte("outs:\n- md5: 0cc175b9c0f1b6a831c399e269772661\n  path: ../../a.txt\n"), "data")
+	assert.Error(t, err)
+}
+
+func TestParseLockFile(t *testing.T) {
+	outputs, err := ParseLockFile([]byte(`
+schema: '2.0'
+stages:
+  train:
+    cmd: python train.py
+    deps:
+    - path: data
+      md5: 3863d9c8dbe3e1e9bbc2b3c8a2b1e5f8.dir
+    outs:
+    - path: model.pkl
+      md5: 0cc175b9c0f1b6a831c399e269772661
+      size: 1
+`), ".")
+	assert.NoError(t, err)
+	assert.Equal(t, []Output{{Path: "model.pkl", MD5: "0cc175b9c0f1b6a831c399e269772661", Size: 1}}, outputs)
+
+	// the outputs are in the order of the stages in the file
+	data := []byte(`
+stages:
+  train:
+    outs:
+    - path: model.pkl
+      md5: 0cc175b9c0f1b6a831c399e269772661
+  evaluate:
+    outs:
+    - path: metrics.json
+      md5: 92eb5ffee6ae2fec3ad71c777531578f
+  prepare:
+    outs:
+    - path: model.pkl
+      md5: 4a8a08f09d37b73795649038408b5f33
+`)
+	for i := 0; i < 10; i++ {
+		outputs, err = ParseLockFile(data, "data")
+		assert.NoError(t, err)
+		assert.Equal(t, []Output{
+			{Path: "data/model.pkl", MD5: "0cc175b9c0f1b6a831c399e269772661"},
+			{Path: "data/metrics.json", MD5: "92eb5ffee6ae2fec3ad71c777531578f"},
+			{Path: "data/model.pkl", MD5: "4a8a08f09d37b73795649038408b5f33"},
+		}, outputs)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	testCases := []struct {
+		desc string
+		dvc3 bool
+	}{
+		{desc: "dvc 2", dvc3: false},
+		{desc: "dvc 3", dvc3: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			cacheDir := t.TempDir()
+			assert.NoError(t, os.MkdirAll(filepath.Join(dir, ".dvc"), 0755))
+
+			a := writeObject(t, cacheDir, []byte("a"), tC.dvc3)
+			b := writeObject(t, cacheDir, []byte("b"), tC.dvc3)
+			images := writeDirObject(t, cacheDir, []dirEntry{{MD5: a, RelPath: "1.jpg"}, {MD5: b, RelPath: "sub/2.jpg"}}, tC.dvc3)
+
+			writeProjectFile(t, dir, "data/a.txt.dvc", fmt.Sprintf("outs:\n- md5: %s\n  size: 1\n  path: a.txt\n", a))
+			writeProjectFile(t, dir, "images.dvc", fmt.Sprintf("outs:\n- md5: %s\n  path: images\n", images))
+			writeProjectFile(t, dir, "dvc.lock", fmt.Sprintf("stages:\n  train:\n    outs:\n    - path: model.pkl\n      md5: %s\n", b))
+
+			project, err := NewProject(dir, []string{cacheDir})
+			assert.NoError(t, err)
+			files, err := project.Files(project.WorkingTree())
+			assert.NoError(t, err)
+
+			blobs := []core.BlobMetaData{}
+			for _, file := range files {
+				blobs = append(blobs, file.BlobMetaData)
+			}
+			assert.ElementsMatch(t, []core.BlobMetaData{
+				{Path: "data/a.txt", Hash: core.Sha1Sum([]byte("a")), Mode: 0644, Size: 1},
+				{Path: "images/1.jpg", Hash: core.Sha1Sum([]byte("a")), Mode: 0644, Size: 1},
+				{Path: "images/sub/2.jpg", Hash: core.Sha1Sum([]byte("b")), Mode: 0644, Size: 1},
+				{Path: "model.pkl", Hash: core.Sha1Sum([]byte("b")), Mode: 0644, Size: 1},
+			}, blobs)
+		})
+	}
+}
+
+func TestFilesNotInCache(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, ".dvc"), 0755))
+	writeProjectFile(t, dir, "a.txt.dvc", "outs:\n- md5: 0cc175b9c0f1b6a831c399e269772661\n  path: a.txt\n")
+
+	project, err := NewProject(dir, nil)
+	assert.NoError(t, err)
+	_, err = project.Files(project.WorkingTree())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, err = NewProject(t.TempDir(), nil)
+	assert.Error(t, err)
+}
+
+func TestHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, ".dvc", "cache")
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE=2021-03-31T00:00:00Z")
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+
+	git("init", "-q")
+	a := writeObject(t, cacheDir, []byte("a"), false)
+	b := writeObject(t, cacheDir, []byte("b"), false)
+
+	writeProjectFile(t, dir, "data.dvc", fmt.Sprintf("outs:\n- md5: %s\n  path: data\n", a))
+	git("add", "data.dvc")
+	git("commit", "-q", "-m", "first version")
+
+	writeProjectFile(t, dir, "README.md", "readme")
+	git("add", "README.md")
+	git("commit", "-q", "-m", "no data change")
+
+	writeProjectFile(t, dir, "data.dvc", fmt.Sprintf("outs:\n- md5: %s\n  path: data\n", b))
+	git("commit", "-q", "-a", "-m", "second version")
+
+	// the working tree is not committed
+	writeProjectFile(t, dir, "data.dvc", "outs:\n- md5: 0cc175b9c0f1b6a831c399e269772661\n  path: data\n")
+
+	project, err := NewProject(dir, nil)
+	assert.NoError(t, err)
+	snapshots, err := project.History(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, "first version", snapshots[0].Message)
+	assert.Equal(t, "second version", snapshots[1].Message)
+	assert.Equal(t, "2021-03-31T00:00:00Z", snapshots[1].CreatedAt.UTC().Format("2006-01-02T15:04:05Z07:00"))
+
+	files, err := project.Files(snapshots[1])
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, core.Sha1Sum([]byte("b")), files[0].Hash)
+}
+
+func TestImportFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, ".dvc", "cache")
+	a := writeObject(t, cacheDir, []byte("a"), true)
+	writeProjectFile(t, dir, "a.txt.dvc", fmt.Sprintf("outs:\n- md5: %s\n  path: a.txt\n", a))
+	writeProjectFile(t, dir, "b.txt.dvc", fmt.Sprintf("outs:\n- md5: %s\n  path: b.txt\n", a))
+
+	project, err := NewProject(dir, nil)
+	assert.NoError(t, err)
+	files, err := project.Files(project.WorkingTree())
+	assert.NoError(t, err)
+
+	metadataDir := t.TempDir()
+	repo := t.TempDir()
+	mngr, err := core.NewArtifactManager(core.NewConfig(metadataDir, metadataDir, repo))
+	assert.NoError(t, err)
+	result, err := mngr.ImportFiles(ctx, dir, files, core.ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Files)
+	assert.Equal(t, 1, result.Transfer.Objects)
+
+	// pull the imported commit
+	wp := t.TempDir()
+	assert.NoError(t, core.InitWorkspace(wp, repo))
+	config, _ := core.LoadConfig(wp)
+	mngr2, _ := core.NewArtifactManager(config)
+	_, err = mngr2.Pull(ctx, core.PullOptions{})
+	assert.NoError(t, err)
+
+	data, _ := os.ReadFile(filepath.Join(wp, "b.txt"))
+	assert.Equal(t, "a", string(data))
+}
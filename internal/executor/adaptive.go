@@ -0,0 +1,189 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/infuseai/artivc/internal/log"
+)
+
+const DefaultAdaptiveInterval = 2 * time.Second
+
+type AdaptiveOptions struct {
+	// The initial number of workers
+	Initial int
+	// The lower and upper bounds of the number of workers
+	Min int
+	Max int
+	// The interval to measure the throughput and adjust the number of workers
+	Interval time.Duration
+	// Returns the accumulated bytes transferred
+	Progress func() int64
+}
+
+// ExecuteAllAdaptive executes the tasks concurrently. The number of workers grows or shrinks by the throughput
+// measured in each interval.
+func ExecuteAllAdaptive(parent context.Context, options AdaptiveOptions, tasks ...TaskFunc) error {
+	if options.Interval <= 0 {
+		options.Interval = DefaultAdaptiveInterval
+	}
+
+	controller := NewAdaptiveController(options.Initial, options.Min, options.Max)
+	gate := newGate(controller.Limit())
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	// adjust the limit periodically
+	go func() {
+		ticker := time.NewTicker(options.Interval)
+		defer ticker.Stop()
+
+		last := options.Progress()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current := options.Progress()
+				throughput := float64(current-last) / options.Interval.Seconds()
+				last = current
+
+				limit := controller.Adjust(throughput)
+				log.Debugf("adaptive concurrency: throughput %.0f B/s, workers %d\n", throughput, limit)
+				gate.setLimit(limit)
+			}
+		}
+	}()
+
+	gated := make([]TaskFunc, len(tasks))
+	for i, task := range tasks {
+		t := task
+		gated[i] = func(ctx context.Context) error {
+			if err := gate.acquire(ctx); err != nil {
+				return err
+			}
+			defer gate.release()
+			return t(ctx)
+		}
+	}
+
+	return ExecuteAllWithContext(ctx, controller.max, gated...)
+}
+
+// AdaptiveController tunes the concurrency by hill climbing. It keeps moving in the same direction while
+// the throughput improves and turns back when the throughput drops.
+type AdaptiveController struct {
+	limit     int
+	min       int
+	max       int
+	direction int
+	last      float64
+}
+
+func NewAdaptiveController(initial, min, max int) *AdaptiveController {
+	if min <= 0 {
+		min = 1
+	}
+
+	if max < min {
+		max = min
+	}
+
+	if initial < min {
+		initial = min
+	} else if initial > max {
+		initial = max
+	}
+
+	return &AdaptiveController{
+		limit:     initial,
+		min:       min,
+		max:       max,
+		direction: 1,
+	}
+}
+
+func (c *AdaptiveController) Limit() int {
+	return c.limit
+}
+
+// Adjust updates the limit by the throughput of the last interval and returns the new limit
+func (c *AdaptiveController) Adjust(throughput float64) int {
+	if throughput < c.last*0.95 {
+		// worse. turn back
+		c.direction = -c.direction
+	}
+	c.last = throughput
+
+	step := c.limit / 4
+	if step < 1 {
+		step = 1
+	}
+
+	c.limit += c.direction * step
+	if c.limit >= c.max {
+		c.limit = c.max
+		c.direction = -1
+	} else if c.limit <= c.min {
+		c.limit = c.min
+		c.direction = 1
+	}
+
+	return c.limit
+}
+
+// gate is a semaphore with adjustable capacity
+type gate struct {
+	mtx     sync.Mutex
+	cond    *sync.Cond
+	limit   int
+	running int
+}
+
+func newGate(limit int) *gate {
+	g := &gate{limit: limit}
+	g.cond = sync.NewCond(&g.mtx)
+	return g
+}
+
+func (g *gate) acquire(ctx context.Context) error {
+	// wake up the waiters once the context is done
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			g.mtx.Lock()
+			g.cond.Broadcast()
+			g.mtx.Unlock()
+		case <-stop:
+		}
+	}()
+
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	for g.running >= g.limit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		g.cond.Wait()
+	}
+	g.running++
+	return nil
+}
+
+func (g *gate) release() {
+	g.mtx.Lock()
+	g.running--
+	g.mtx.Unlock()
+	g.cond.Broadcast()
+}
+
+func (g *gate) setLimit(limit int) {
+	g.mtx.Lock()
+	g.limit = limit
+	g.mtx.Unlock()
+	g.cond.Broadcast()
+}
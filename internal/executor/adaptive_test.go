@@ -0,0 +1,86 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveController(t *testing.T) {
+	c := NewAdaptiveController(4, 1, 8)
+	assert.Equal(t, 4, c.Limit())
+
+	// grow while the throughput improves
+	assert.Equal(t, 5, c.Adjust(100))
+	assert.Equal(t, 6, c.Adjust(200))
+	assert.Equal(t, 7, c.Adjust(300))
+
+	// turn back when the throughput drops
+	assert.Equal(t, 6, c.Adjust(200))
+	assert.Equal(t, 5, c.Adjust(200))
+
+	// bounded by max and min
+	c = NewAdaptiveController(100, 1, 8)
+	assert.Equal(t, 8, c.Limit())
+	assert.Equal(t, 8, c.Adjust(100))
+	c = NewAdaptiveController(1, 1, 8)
+	assert.Equal(t, 2, c.Adjust(100))
+	assert.Equal(t, 1, c.Adjust(50))
+}
+
+func TestExecuteAllAdaptive(t *testing.T) {
+	tasks := []TaskFunc{}
+	var counter, running, maxRunning int32
+
+	for i := 0; i < 50; i++ {
+		f := func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			atomic.AddInt32(&counter, 1)
+			return nil
+		}
+		tasks = append(tasks, f)
+	}
+
+	err := ExecuteAllAdaptive(context.Background(), AdaptiveOptions{
+		Initial:  2,
+		Min:      1,
+		Max:      8,
+		Interval: time.Hour,
+		Progress: func() int64 { return 0 },
+	}, tasks...)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(50), counter)
+	assert.LessOrEqual(t, maxRunning, int32(2))
+}
+
+func TestExecuteAllAdaptiveFailed(t *testing.T) {
+	ErrFoo := errors.New("foo")
+
+	taskForever := func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}
+
+	taskErr := func(ctx context.Context) error {
+		return ErrFoo
+	}
+
+	err := ExecuteAllAdaptive(context.Background(), AdaptiveOptions{
+		Initial:  1,
+		Max:      4,
+		Progress: func() int64 { return 0 },
+	}, taskErr, taskForever, taskForever)
+	assert.Equal(t, ErrFoo, err)
+}
@@ -9,8 +9,15 @@ import (
 type TaskFunc func(ctx context.Context) error
 
 func ExecuteAll(numCPU int, tasks ...TaskFunc) error {
+	return ExecuteAllWithContext(context.Background(), numCPU, tasks...)
+}
+
+// ExecuteAllWithContext executes the tasks concurrently. It stops when the first error occurs or
+// the parent context is done.
+func ExecuteAllWithContext(parent context.Context, numCPU int, tasks ...TaskFunc) error {
 	var err error
-	ctx, cancel := context.WithCancel(context.Background())
+	var mtx sync.Mutex
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
 
 	if numCPU == 0 {
@@ -38,7 +45,11 @@ func ExecuteAll(numCPU int, tasks ...TaskFunc) error {
 						return
 					}
 					if e := task(ctx); e != nil {
-						err = e
+						mtx.Lock()
+						if err == nil {
+							err = e
+						}
+						mtx.Unlock()
 						cancel()
 					}
 				case <-ctx.Done():
@@ -50,5 +61,8 @@ func ExecuteAll(numCPU int, tasks ...TaskFunc) error {
 
 	// wait for all task done
 	wg.Wait()
+	if err == nil {
+		err = parent.Err()
+	}
 	return err
 }
@@ -85,3 +85,22 @@ func TestContext(t *testing.T) {
 	err := ExecuteAll(3, taskForever, taskErr)
 	assert.Equal(t, ErrFoo, err)
 }
+
+func TestParentContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var counter int32
+
+	taskCancel := func(ctx context.Context) error {
+		cancel()
+		return nil
+	}
+
+	taskCount := func(ctx context.Context) error {
+		atomic.AddInt32(&counter, 1)
+		return nil
+	}
+
+	err := ExecuteAllWithContext(ctx, 1, taskCancel, taskCount, taskCount)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, int32(0), counter)
+}
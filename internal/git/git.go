@@ -0,0 +1,272 @@
+// Package git reads the history of the files in a git repository by the git command, for importing the files
+// tracked by git as commits.
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/infuseai/artivc/internal/core"
+)
+
+// Commit is a git commit
+type Commit struct {
+	Hash string
+	// The name and email of the author, e.g. "name <email>"
+	Author string
+	// The time of the author
+	CreatedAt time.Time
+	Message   string
+}
+
+// Repository is a git repository on the disk
+type Repository struct {
+	dir string
+	// the sha1 of the blob contents by the git object ids
+	sha1s map[string]string
+	// the targets of the symbolic links by the git object ids
+	links map[string]string
+}
+
+// Open opens the git repository, which is a work tree or a bare repository
+func Open(ctx context.Context, dir string) (*Repository, error) {
+	repo := &Repository{dir: dir, sha1s: map[string]string{}, links: map[string]string{}}
+	if _, err := repo.git(ctx, "rev-parse", "--git-dir"); err != nil {
+		return nil, fmt.Errorf("not a git repository: %s", dir)
+	}
+	return repo, nil
+}
+
+// Log returns the commits changing the subdir from the oldest to the newest. Only the first parents of the merges are
+// followed. All the commits of the revision are returned if the subdir is empty.
+func (r *Repository) Log(ctx context.Context, rev, subdir string) ([]Commit, error) {
+	args := []string{"log", "--reverse", "--first-parent", "--format=%H%x00%an <%ae>%x00%aI%x00%B%x1e", rev, "--"}
+	if subdir = cleanSubdir(subdir); subdir != "" {
+		args = append(args, ":(top)"+subdir)
+	}
+
+	out, err := r.git(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []Commit{}
+	for _, record := range strings.Split(string(out), "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x00", 4)
+		if len(fields) != 4 {
+			continue
+		}
+
+		createdAt, err := time.Parse(time.RFC3339, fields[2])
+		if err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, Commit{
+			Hash:      fields[0],
+			Author:    fields[1],
+			CreatedAt: createdAt,
+			Message:   strings.TrimSpace(fields[3]),
+		})
+	}
+	return commits, nil
+}
+
+type treeEntry struct {
+	mode   string
+	object string
+	size   int64
+	path   string
+}
+
+// Files returns the files under the subdir of the commit. The paths are relative to the subdir. The contents are
+// hashed once for each git object, and the submodules are skipped.
+func (r *Repository) Files(ctx context.Context, commit, subdir string) ([]core.ImportFile, error) {
+	out, err := r.git(ctx, "ls-tree", "-r", "-l", "-z", "--full-tree", commit)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	if subdir = cleanSubdir(subdir); subdir != "" {
+		prefix = subdir + "/"
+	}
+
+	entries := []treeEntry{}
+	unknown := []string{}
+	unknownLinks := map[string]bool{}
+	for _, line := range strings.Split(string(out), "\x00") {
+		// <mode> SP <type> SP <object> SP <size> TAB <path>
+		meta, entryPath, ok := cut(line, "\t")
+		if !ok || !strings.HasPrefix(entryPath, prefix) {
+			continue
+		}
+
+		fields := strings.Fields(meta)
+		if len(fields) != 4 || fields[1] != "blob" {
+			continue
+		}
+
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ls-tree output: %s", line)
+		}
+
+		entry := treeEntry{mode: fields[0], object: fields[2], size: size, path: strings.TrimPrefix(entryPath, prefix)}
+		if _, ok := r.sha1s[entry.object]; !ok {
+			unknown = append(unknown, entry.object)
+			if entry.mode == "120000" {
+				unknownLinks[entry.object] = true
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := r.hashObjects(ctx, unknown, unknownLinks); err != nil {
+		return nil, err
+	}
+
+	files := []core.ImportFile{}
+	for _, entry := range entries {
+		if entry.mode == "120000" {
+			files = append(files, core.ImportFile{BlobMetaData: core.BlobMetaData{Path: entry.path, Link: r.links[entry.object]}})
+			continue
+		}
+
+		var mode fs.FileMode = 0644
+		if entry.mode == "100755" {
+			mode = 0755
+		}
+
+		object := entry.object
+		files = append(files, core.ImportFile{
+			BlobMetaData: core.BlobMetaData{Path: entry.path, Hash: r.sha1s[object], Mode: mode, Size: entry.size},
+			Open: func() (io.ReadCloser, error) {
+				return r.openObject(ctx, object)
+			},
+		})
+	}
+	return files, nil
+}
+
+// hashObjects reads the objects by one "git cat-file --batch" command and hashes the contents. The contents of the
+// symbolic links are kept as the targets.
+func (r *Repository) hashObjects(ctx context.Context, objects []string, links map[string]bool) error {
+	if len(objects) == 0 {
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "-C", r.dir, "cat-file", "--batch")
+	cmd.Stdin = strings.NewReader(strings.Join(objects, "\n") + "\n")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	err = r.readBatch(bufio.NewReader(stdout), len(objects), links)
+	if waitErr := cmd.Wait(); err == nil && waitErr != nil {
+		err = fmt.Errorf("git cat-file: %w %s", waitErr, strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
+
+func (r *Repository) readBatch(br *bufio.Reader, count int, links map[string]bool) error {
+	for i := 0; i < count; i++ {
+		// <object> SP <type> SP <size> LF <contents> LF
+		header, err := br.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return fmt.Errorf("git cat-file: %s", strings.TrimSpace(header))
+		}
+
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("git cat-file: %s", strings.TrimSpace(header))
+		}
+
+		var content bytes.Buffer
+		hasher := sha1.New()
+		w := io.Writer(hasher)
+		if links[fields[0]] {
+			w = io.MultiWriter(hasher, &content)
+		}
+		if _, err := io.CopyN(w, br, size); err != nil {
+			return err
+		}
+		if _, err := br.Discard(1); err != nil {
+			return err
+		}
+
+		r.sha1s[fields[0]] = fmt.Sprintf("%x", hasher.Sum(nil))
+		if links[fields[0]] {
+			r.links[fields[0]] = content.String()
+		}
+	}
+	return nil
+}
+
+type objectReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (r *objectReader) Close() error {
+	r.ReadCloser.Close()
+	return r.cmd.Wait()
+}
+
+func (r *Repository) openObject(ctx context.Context, object string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", r.dir, "cat-file", "blob", object)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &objectReader{ReadCloser: stdout, cmd: cmd}, nil
+}
+
+func (r *Repository) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", r.dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// cleanSubdir cleans the slash-separated path relative to the top of the repository
+func cleanSubdir(subdir string) string {
+	return strings.Trim(path.Clean("/"+subdir), "/")
+}
+
+// cut is strings.Cut of go 1.18
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
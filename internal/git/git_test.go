@@ -0,0 +1,107 @@
+package git
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/infuseai/artivc/internal/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func newGitFixture(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	day := 0
+	git := func(args ...string) {
+		date := time.Date(2021, 3, 1+day, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=alice", "-c", "user.email=alice@example.com"}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+	write := func(name, content string) {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	git("init", "-q")
+	write("data/a.txt", "a")
+	write("README.md", "readme")
+	git("add", "-A")
+	git("commit", "-q", "-m", "first version")
+
+	day++
+	write("README.md", "readme v2")
+	git("commit", "-q", "-a", "-m", "no data change")
+
+	day++
+	write("data/sub/b.txt", "a")
+	write("data/run.sh", "#!/bin/sh")
+	assert.NoError(t, os.Chmod(filepath.Join(dir, "data/run.sh"), 0755))
+	assert.NoError(t, os.Symlink("a.txt", filepath.Join(dir, "data/link")))
+	git("add", "-A")
+	git("commit", "-q", "-m", "second version\n\nadd more files")
+	return dir
+}
+
+func TestLog(t *testing.T) {
+	ctx := context.Background()
+	dir := newGitFixture(t)
+
+	repo, err := Open(ctx, dir)
+	assert.NoError(t, err)
+
+	commits, err := repo.Log(ctx, "HEAD", "data/")
+	assert.NoError(t, err)
+	assert.Len(t, commits, 2)
+	assert.Equal(t, "first version", commits[0].Message)
+	assert.Equal(t, "second version\n\nadd more files", commits[1].Message)
+	assert.Equal(t, "alice <alice@example.com>", commits[1].Author)
+	assert.True(t, time.Date(2021, 3, 3, 0, 0, 0, 0, time.UTC).Equal(commits[1].CreatedAt))
+
+	commits, err = repo.Log(ctx, "HEAD", "")
+	assert.NoError(t, err)
+	assert.Len(t, commits, 3)
+
+	_, err = Open(ctx, t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := newGitFixture(t)
+
+	repo, err := Open(ctx, dir)
+	assert.NoError(t, err)
+	commits, err := repo.Log(ctx, "HEAD", "data")
+	assert.NoError(t, err)
+
+	files, err := repo.Files(ctx, commits[1].Hash, "data")
+	assert.NoError(t, err)
+
+	blobs := []core.BlobMetaData{}
+	for _, file := range files {
+		blobs = append(blobs, file.BlobMetaData)
+	}
+	assert.Equal(t, []core.BlobMetaData{
+		{Path: "a.txt", Hash: core.Sha1Sum([]byte("a")), Mode: 0644, Size: 1},
+		{Path: "link", Link: "a.txt"},
+		{Path: "run.sh", Hash: core.Sha1Sum([]byte("#!/bin/sh")), Mode: 0755, Size: 9},
+		{Path: "sub/b.txt", Hash: core.Sha1Sum([]byte("a")), Mode: 0644, Size: 1},
+	}, blobs)
+
+	r, err := files[2].Open()
+	assert.NoError(t, err)
+	data, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, "#!/bin/sh", string(data))
+}
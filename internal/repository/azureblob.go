@@ -4,75 +4,304 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	neturl "net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/infuseai/artivc/internal/log"
 )
 
+// AzureBlobConfig is the settings of the Azure Blob Storage repository. The credential is resolved in the order of the
+// SAS token, the connection string, the account key, the well-known key of the emulator account and the default
+// Azure credential.
+type AzureBlobConfig struct {
+	// The SAS token of the account or the container. The SAS of the repository URL or the AZURE_STORAGE_SAS_TOKEN
+	// environment variable is used if it is empty.
+	SASToken string
+	// The connection string of the storage account. The AZURE_STORAGE_CONNECTION_STRING environment variable is used
+	// if it is empty.
+	ConnectionString string
+	// The access key of the storage account. The AZURE_STORAGE_ACCOUNT_KEY environment variable is used if it is empty.
+	AccountKey string
+	// The endpoint of the blob service for the "azblob://" repository, e.g. "http://127.0.0.1:10000/devstoreaccount1".
+	// The "endpoint" parameter of the repository URL is used if it is empty.
+	Endpoint string
+}
+
+const (
+	// azuriteAccount is the default account of the Azurite emulator
+	azuriteAccount = "devstoreaccount1"
+	// azuriteAccountKey is the well-known key of the default account of the Azurite emulator
+	azuriteAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+// azureBlobHostSuffixes are the blob service hosts of the Azure clouds
+var azureBlobHostSuffixes = []string{
+	".blob.core.windows.net",
+	".blob.core.chinacloudapi.cn",
+	".blob.core.usgovcloudapi.net",
+}
+
 type AzureBlobRepository struct {
 	Client   *azblob.ContainerClient
 	Prefix   string
 	BasePath string
+	// the service URL without the SAS, and the SAS token
+	serviceUrl string
+	sas        string
 }
 
+// IsAzureStorageUrl checks if the repository is in Azure Blob Storage. The repository is one of
+//
+//	https://<account>.blob.core.windows.net/<container>/<prefix>
+//	http://127.0.0.1:10000/devstoreaccount1/<container>/<prefix> (the Azurite emulator)
+//	azblob://<container>/<prefix> (the endpoint is from the config or the connection string)
 func IsAzureStorageUrl(repoUrl string) bool {
 	url, err := neturl.Parse(repoUrl)
 	if err != nil {
 		return false
 	}
 
-	return strings.HasSuffix(url.Host, ".blob.core.windows.net")
+	switch url.Scheme {
+	case "azblob":
+		return true
+	case "http", "https":
+		return isAzureBlobHost(url.Hostname()) || strings.HasPrefix(url.Path, "/"+azuriteAccount+"/")
+	default:
+		return false
+	}
 }
 
-func ParseAzureBlobUrl(urlString string) (storageAccount, container, prefix string, err error) {
+func isAzureBlobHost(host string) bool {
+	for _, suffix := range azureBlobHostSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// azureBlobLocation is the parsed repository URL
+type azureBlobLocation struct {
+	// the service URL without the SAS. It is empty for the "azblob://" repository without the endpoint parameter.
+	serviceUrl string
+	account    string
+	container  string
+	prefix     string
+	// the SAS token in the query
+	sas string
+	// the service URL is from the endpoint parameter
+	endpointParam bool
+}
+
+func parseAzureBlobLocation(urlString string) (azureBlobLocation, error) {
+	var location azureBlobLocation
+
 	url, err := neturl.Parse(urlString)
 	if err != nil {
-		return
+		return location, err
 	}
 
-	storageAccount = url.Host[:len(url.Host)-len(".blob.core.windows.net")]
+	query := url.Query()
 	comps := strings.Split(url.Path, "/")
-	if len(comps) < 2 {
-		err = fmt.Errorf("invalid azure blob url: " + urlString)
+	if url.Scheme == "azblob" {
+		// azblob://<container>/<prefix>
+		location.container = url.Host
+		location.prefix = strings.Join(comps[1:], "/")
+		if endpoint := query.Get("endpoint"); endpoint != "" {
+			query.Del("endpoint")
+			location.serviceUrl = strings.TrimSuffix(endpoint, "/")
+			location.account = azureAccountOfEndpoint(endpoint)
+			location.endpointParam = true
+		}
+	} else if isAzureBlobHost(url.Hostname()) {
+		// https://<account>.blob.core.windows.net/<container>/<prefix>
+		location.serviceUrl = fmt.Sprintf("%s://%s", url.Scheme, url.Host)
+		location.account = strings.SplitN(url.Hostname(), ".", 2)[0]
+		if len(comps) < 2 || comps[1] == "" {
+			return location, fmt.Errorf("invalid azure blob url: " + urlString)
+		}
+		location.container = comps[1]
+		location.prefix = strings.Join(comps[2:], "/")
+	} else {
+		// http://<host>/<account>/<container>/<prefix>
+		if len(comps) < 3 || comps[1] == "" || comps[2] == "" {
+			return location, fmt.Errorf("invalid azure blob url: " + urlString)
+		}
+		location.serviceUrl = fmt.Sprintf("%s://%s/%s", url.Scheme, url.Host, comps[1])
+		location.account = comps[1]
+		location.container = comps[2]
+		location.prefix = strings.Join(comps[3:], "/")
+	}
+
+	if query.Get("sig") != "" {
+		location.sas = query.Encode()
+	}
+
+	return location, nil
+}
+
+// azureAccountOfEndpoint returns the account of the blob service endpoint. The account is the first label of the
+// Azure host, or the first path component of the emulator endpoint.
+func azureAccountOfEndpoint(endpoint string) string {
+	url, err := neturl.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+
+	if isAzureBlobHost(url.Hostname()) {
+		return strings.SplitN(url.Hostname(), ".", 2)[0]
+	}
+	return strings.Trim(url.Path, "/")
+}
+
+func ParseAzureBlobUrl(urlString string) (storageAccount, container, prefix string, err error) {
+	location, err := parseAzureBlobLocation(urlString)
+	if err != nil {
 		return
 	}
 
-	container = comps[1]
-	prefix = strings.Join(comps[2:], "/")
+	return location.account, location.container, location.prefix, nil
+}
 
-	return
+// azureConnectionString is the parsed storage account connection string
+type azureConnectionString struct {
+	serviceUrl string
+	account    string
+	accountKey string
+	sas        string
 }
 
-func makeAzureServiceAccountUrl(accountName string) string {
-	return fmt.Sprintf("https://%s.blob.core.windows.net/", accountName)
+// parseAzureConnectionString parses the connection string, e.g.
+// "DefaultEndpointsProtocol=https;AccountName=<account>;AccountKey=<key>;EndpointSuffix=core.windows.net".
+// The "UseDevelopmentStorage=true" is the default account of the emulator.
+func parseAzureConnectionString(connectionString string) (azureConnectionString, error) {
+	var result azureConnectionString
+
+	values := map[string]string{}
+	for _, part := range strings.Split(strings.Trim(connectionString, "; "), ";") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			return result, errors.New("invalid azure storage connection string")
+		}
+		values[kv[0]] = kv[1]
+	}
+
+	if strings.EqualFold(values["UseDevelopmentStorage"], "true") {
+		return azureConnectionString{
+			serviceUrl: "http://127.0.0.1:10000/" + azuriteAccount,
+			account:    azuriteAccount,
+			accountKey: azuriteAccountKey,
+		}, nil
+	}
+
+	result.account = values["AccountName"]
+	result.accountKey = values["AccountKey"]
+	result.sas = strings.TrimPrefix(values["SharedAccessSignature"], "?")
+
+	if endpoint := values["BlobEndpoint"]; endpoint != "" {
+		result.serviceUrl = strings.TrimSuffix(endpoint, "/")
+		if result.account == "" {
+			result.account = azureAccountOfEndpoint(endpoint)
+		}
+	} else if result.account != "" {
+		protocol := values["DefaultEndpointsProtocol"]
+		if protocol == "" {
+			protocol = "https"
+		}
+		suffix := values["EndpointSuffix"]
+		if suffix == "" {
+			suffix = "core.windows.net"
+		}
+		result.serviceUrl = fmt.Sprintf("%s://%s.blob.%s", protocol, result.account, suffix)
+	} else {
+		return result, errors.New("invalid azure storage connection string: no AccountName or BlobEndpoint")
+	}
+
+	if result.accountKey == "" && result.sas == "" {
+		return result, errors.New("invalid azure storage connection string: no AccountKey or SharedAccessSignature")
+	}
+
+	return result, nil
 }
 
-func NewAzureBlobRepository(repo string) (*AzureBlobRepository, error) {
-	accountName, container, prefix, err := ParseAzureBlobUrl(repo)
+func NewAzureBlobRepository(repo string, config AzureBlobConfig) (*AzureBlobRepository, error) {
+	location, err := parseAzureBlobLocation(repo)
 	if err != nil {
 		return nil, err
 	}
 
-	serviceUrl := makeAzureServiceAccountUrl(accountName)
-	var serviceClient azblob.ServiceClient
+	sas := location.sas
+	if sas == "" {
+		sas = strings.TrimPrefix(config.SASToken, "?")
+	}
+	if sas == "" {
+		sas = strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?")
+	}
+
+	accountKey := config.AccountKey
+	if accountKey == "" {
+		accountKey = os.Getenv("AZURE_STORAGE_ACCOUNT_KEY")
+	}
 
-	var accountKey string
-	if value := os.Getenv("AZURE_STORAGE_ACCOUNT_KEY"); value != "" {
-		accountKey = value
+	if location.serviceUrl == "" && config.Endpoint != "" {
+		location.serviceUrl = strings.TrimSuffix(config.Endpoint, "/")
+		location.account = azureAccountOfEndpoint(config.Endpoint)
 	}
 
-	if accountKey != "" {
-		credential, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+	connectionString := config.ConnectionString
+	if connectionString == "" {
+		connectionString = os.Getenv("AZURE_STORAGE_CONNECTION_STRING")
+	}
+	if connectionString != "" {
+		conn, err := parseAzureConnectionString(connectionString)
 		if err != nil {
 			return nil, err
 		}
 
-		serviceClient, err = azblob.NewServiceClientWithSharedKey(serviceUrl, credential, nil)
+		if location.serviceUrl == "" {
+			location.serviceUrl = conn.serviceUrl
+			location.account = conn.account
+		}
+		if sas == "" && accountKey == "" {
+			sas = conn.sas
+			accountKey = conn.accountKey
+		}
+	}
+
+	if location.serviceUrl == "" {
+		return nil, fmt.Errorf("the endpoint of %s is not configured", repo)
+	}
+
+	if accountKey == "" && location.account == azuriteAccount {
+		accountKey = azuriteAccountKey
+	}
+
+	// The endpoint parameter may come from a shared URL. Only the SAS in the same URL or the shared key signature is
+	// sent to it, never the SAS of the config or the token of the Azure AD login.
+	if location.endpointParam && location.sas == "" && (sas != "" || accountKey == "") {
+		return nil, errors.New("the endpoint parameter requires the SAS token in the URL or the account key, set azure.endpoint in the config instead")
+	}
+
+	var serviceClient azblob.ServiceClient
+	if sas != "" {
+		serviceClient, err = azblob.NewServiceClientWithNoCredential(location.serviceUrl+"/?"+sas, nil)
+		if err != nil {
+			return nil, err
+		}
+	} else if accountKey != "" {
+		credential, err := azblob.NewSharedKeyCredential(location.account, accountKey)
+		if err != nil {
+			return nil, err
+		}
+
+		serviceClient, err = azblob.NewServiceClientWithSharedKey(location.serviceUrl+"/", credential, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -82,18 +311,22 @@ func NewAzureBlobRepository(repo string) (*AzureBlobRepository, error) {
 			return nil, err
 		}
 
-		serviceClient, err = azblob.NewServiceClient(serviceUrl, credential, nil)
+		serviceClient, err = azblob.NewServiceClient(location.serviceUrl+"/", credential, nil)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	accountName := location.account
+	container, prefix := location.container, location.prefix
 	containerClient := serviceClient.NewContainerClient(container)
 
 	r := &AzureBlobRepository{
-		Client:   &containerClient,
-		BasePath: repo,
-		Prefix:   prefix,
+		Client:     &containerClient,
+		BasePath:   repo,
+		Prefix:     prefix,
+		serviceUrl: location.serviceUrl,
+		sas:        sas,
 	}
 
 	// check if the client has enough permission
@@ -103,7 +336,7 @@ func NewAzureBlobRepository(repo string) (*AzureBlobRepository, error) {
 	}
 	defer os.RemoveAll(dir) // clean up
 
-	err = r.Download("refs/latest", filepath.Join(dir, "latest"), nil)
+	err = r.Download(context.Background(), "refs/latest", filepath.Join(dir, "latest"), nil)
 	if err != nil {
 		var internalError *azblob.InternalError
 		if !errors.As(err, &internalError) {
@@ -133,9 +366,7 @@ func NewAzureBlobRepository(repo string) (*AzureBlobRepository, error) {
 	return r, nil
 }
 
-func (repo *AzureBlobRepository) Upload(localPath, repoPath string, m *Meter) error {
-	ctx := context.Background()
-
+func (repo *AzureBlobRepository) Upload(ctx context.Context, localPath, repoPath string, m *Meter) error {
 	// file
 	src, err := os.Open(localPath)
 	if err != nil {
@@ -151,11 +382,7 @@ func (repo *AzureBlobRepository) Upload(localPath, repoPath string, m *Meter) er
 		ctx,
 		src,
 		azblob.HighLevelUploadToBlockBlobOption{
-			Progress: func(bytesTransferred int64) {
-				if m != nil {
-					m.SetBytes(bytesTransferred)
-				}
-			},
+			Progress:    azureProgress(ctx, m),
 			Parallelism: 10,
 		},
 	)
@@ -163,9 +390,7 @@ func (repo *AzureBlobRepository) Upload(localPath, repoPath string, m *Meter) er
 	return err
 }
 
-func (repo *AzureBlobRepository) Download(repoPath, localPath string, m *Meter) error {
-	ctx := context.Background()
-
+func (repo *AzureBlobRepository) Download(ctx context.Context, repoPath, localPath string, m *Meter) error {
 	// file
 	dest, err := os.Create(localPath)
 	if err != nil {
@@ -177,11 +402,7 @@ func (repo *AzureBlobRepository) Download(repoPath, localPath string, m *Meter)
 	blobPath := filepath.Join(repo.Prefix, repoPath)
 	blobClient := repo.Client.NewBlockBlobClient(blobPath)
 	err = blobClient.DownloadBlobToFile(ctx, 0, 0, dest, azblob.HighLevelDownloadFromBlobOptions{
-		Progress: func(bytesTransferred int64) {
-			if m != nil {
-				m.SetBytes(bytesTransferred)
-			}
-		},
+		Progress:    azureProgress(ctx, m),
 		Parallelism: 10,
 	})
 	if err != nil {
@@ -191,36 +412,116 @@ func (repo *AzureBlobRepository) Download(repoPath, localPath string, m *Meter)
 	return nil
 }
 
-func (repo *AzureBlobRepository) Delete(repoPath string) error {
-	ctx := context.Background()
+func (repo *AzureBlobRepository) UploadStream(ctx context.Context, src io.Reader, repoPath string, m *Meter) error {
+	blobPath := filepath.Join(repo.Prefix, repoPath)
+	blobClient := repo.Client.NewBlockBlobClient(blobPath)
+
+	// the blocks are not committed if the upload fails
+	_, err := blobClient.UploadStreamToBlockBlob(ctx, NewMeterReader(ctx, src, m), azblob.UploadStreamToBlockBlobOptions{
+		BufferSize: 4 * 1024 * 1024,
+		MaxBuffers: 4,
+	})
+	return err
+}
+
+func (repo *AzureBlobRepository) DownloadStream(ctx context.Context, repoPath string, dest io.Writer, m *Meter) error {
+	src, err := repo.ReadRange(ctx, repoPath, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = CopyWithMeter(ctx, dest, src, m)
+	return err
+}
 
+func (repo *AzureBlobRepository) ReadRange(ctx context.Context, repoPath string, offset, length int64) (io.ReadCloser, error) {
 	blobPath := filepath.Join(repo.Prefix, repoPath)
 	blobClient := repo.Client.NewBlockBlobClient(blobPath)
-	_, err := blobClient.Delete(ctx, nil)
+
+	options := &azblob.DownloadBlobOptions{Offset: &offset}
+	if length > 0 {
+		options.Count = &length
+	}
+
+	resp, err := blobClient.Download(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body(&azblob.RetryReaderOptions{MaxRetryRequests: 3}), nil
+}
+
+// CopyFrom copies the blob of another repository by StartCopyFromURL, and waits until the copy completes. The source
+// in another storage account is supported only if it is accessed by a SAS token, because the source is not authorized
+// by the credential of the destination.
+func (repo *AzureBlobRepository) CopyFrom(ctx context.Context, src Repository, srcPath, repoPath string) error {
+	srcRepo, ok := unwrap(src).(*AzureBlobRepository)
+	if !ok || (srcRepo.serviceUrl != repo.serviceUrl && srcRepo.sas == "") {
+		return ErrCopyNotSupported
+	}
+
+	srcBlobClient := srcRepo.Client.NewBlockBlobClient(filepath.Join(srcRepo.Prefix, srcPath))
+	blobClient := repo.Client.NewBlockBlobClient(filepath.Join(repo.Prefix, repoPath))
+	resp, err := blobClient.StartCopyFromURL(ctx, srcBlobClient.URL(), nil)
 	if err != nil {
 		return err
 	}
 
+	status := azblob.CopyStatusTypePending
+	if resp.CopyStatus != nil {
+		status = *resp.CopyStatus
+	}
+	for status == azblob.CopyStatusTypePending {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+
+		props, err := blobClient.GetProperties(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if props.CopyStatus == nil {
+			return fmt.Errorf("copy %s: unknown copy status", repoPath)
+		}
+		status = *props.CopyStatus
+	}
+
+	if status != azblob.CopyStatusTypeSuccess {
+		return fmt.Errorf("copy %s: %s", repoPath, status)
+	}
 	return nil
 }
 
-func (repo *AzureBlobRepository) Stat(repoPath string) (FileInfo, error) {
-	ctx := context.Background()
+func (repo *AzureBlobRepository) Delete(ctx context.Context, repoPath string) error {
+	blobPath := filepath.Join(repo.Prefix, repoPath)
+	blobClient := repo.Client.NewBlockBlobClient(blobPath)
+	_, err := blobClient.Delete(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
 
+func (repo *AzureBlobRepository) Stat(ctx context.Context, repoPath string) (FileInfo, error) {
 	blobPath := filepath.Join(repo.Prefix, repoPath)
 	blobClient := repo.Client.NewBlockBlobClient(blobPath)
-	_, err := blobClient.GetProperties(ctx, nil)
+	resp, err := blobClient.GetProperties(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return &SimpleFileInfo{
-		name: filepath.Base(repoPath),
-	}, nil
+	info := &AzureBlobFileInfo{name: filepath.Base(repoPath)}
+	if resp.ContentLength != nil {
+		info.size = *resp.ContentLength
+	}
+	return info, nil
 }
 
-func (repo *AzureBlobRepository) List(repoPath string) ([]FileInfo, error) {
-	ctx := context.Background()
+func (repo *AzureBlobRepository) List(ctx context.Context, repoPath string) ([]FileInfo, error) {
 	entries := make([]FileInfo, 0)
 	prefix := filepath.Join(repo.Prefix, repoPath) + "/"
 	pager := repo.Client.ListBlobsHierarchy("/", &azblob.ContainerListBlobHierarchySegmentOptions{Prefix: &prefix})
@@ -229,17 +530,17 @@ func (repo *AzureBlobRepository) List(repoPath string) ([]FileInfo, error) {
 
 		for _, blobInfo := range resp.Segment.BlobItems {
 			n := *blobInfo.Name
-			name := n[len(prefix):]
-			entries = append(entries, &SimpleFileInfo{
-				name:  name,
-				isDir: false,
-			})
+			entry := &AzureBlobFileInfo{name: n[len(prefix):]}
+			if blobInfo.Properties != nil && blobInfo.Properties.ContentLength != nil {
+				entry.size = *blobInfo.Properties.ContentLength
+			}
+			entries = append(entries, entry)
 		}
 
 		for _, blobPrefix := range resp.Segment.BlobPrefixes {
 			p := *blobPrefix.Name
 			name := p[len(prefix) : len((p))-1]
-			entries = append(entries, &SimpleFileInfo{
+			entries = append(entries, &AzureBlobFileInfo{
 				name:  name,
 				isDir: true,
 			})
@@ -248,3 +549,48 @@ func (repo *AzureBlobRepository) List(repoPath string) ([]FileInfo, error) {
 
 	return entries, nil
 }
+
+type AzureBlobFileInfo struct {
+	name  string
+	isDir bool
+	size  int64
+}
+
+func (fi *AzureBlobFileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *AzureBlobFileInfo) IsDir() bool {
+	return fi.isDir
+}
+
+func (fi *AzureBlobFileInfo) Size() int64 {
+	return fi.size
+}
+
+// azureProgress updates the meter by the transferred bytes and throttles the transfer by the rate limiter of the meter
+func azureProgress(ctx context.Context, m *Meter) func(bytesTransferred int64) {
+	var last int64
+	return func(bytesTransferred int64) {
+		if m == nil {
+			return
+		}
+
+		m.SetBytes(bytesTransferred)
+		delta := bytesTransferred - atomic.SwapInt64(&last, bytesTransferred)
+		_ = m.Wait(ctx, int(delta))
+	}
+}
+
+// IsRetryableError checks the throttling and server errors of Azure Blob Storage
+func (repo *AzureBlobRepository) IsRetryableError(err error) bool {
+	var errStorage *azblob.StorageError
+	if errors.As(err, &errStorage) {
+		if res := errStorage.Response(); res != nil {
+			return isRetryableStatusCode(res.StatusCode)
+		}
+		return false
+	}
+
+	return isRetryableError(err)
+}
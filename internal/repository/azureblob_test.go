@@ -1,8 +1,13 @@
 package repository
 
 import (
+	"context"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,6 +42,30 @@ func Test_ParseAzureBlobUrl(t *testing.T) {
 			container:      "avc",
 			prefix:         "abc/",
 		},
+		{
+			repo:           "https://artivc.blob.core.windows.net/avc/abc?sv=2020-10-02&sp=rl&sig=secret",
+			storageAccount: "artivc",
+			container:      "avc",
+			prefix:         "abc",
+		},
+		{
+			repo:           "http://127.0.0.1:10000/devstoreaccount1/avc/abc",
+			storageAccount: "devstoreaccount1",
+			container:      "avc",
+			prefix:         "abc",
+		},
+		{
+			repo:           "azblob://avc/abc?endpoint=http://azurite:10000/myaccount",
+			storageAccount: "myaccount",
+			container:      "avc",
+			prefix:         "abc",
+		},
+		{
+			repo:           "azblob://avc",
+			storageAccount: "",
+			container:      "avc",
+			prefix:         "",
+		},
 	}
 	for _, tC := range testCases {
 		t.Run(tC.repo, func(t *testing.T) {
@@ -53,3 +82,150 @@ func Test_ParseAzureBlobUrl(t *testing.T) {
 		})
 	}
 }
+
+func TestIsAzureStorageUrl(t *testing.T) {
+	testCases := []struct {
+		repo     string
+		expected bool
+	}{
+		{repo: "https://artivc.blob.core.windows.net/avc", expected: true},
+		{repo: "https://artivc.blob.core.chinacloudapi.cn/avc", expected: true},
+		{repo: "http://127.0.0.1:10000/devstoreaccount1/avc", expected: true},
+		{repo: "azblob://avc/abc", expected: true},
+		{repo: "https://example.com/avc", expected: false},
+		{repo: "http://127.0.0.1:10000/avc", expected: false},
+		{repo: "s3://avc/abc", expected: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.repo, func(t *testing.T) {
+			assert.Equal(t, tC.expected, IsAzureStorageUrl(tC.repo))
+		})
+	}
+}
+
+func TestParseAzureConnectionString(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		conn     string
+		expected azureConnectionString
+		err      bool
+	}{
+		{
+			desc:     "account key",
+			conn:     "DefaultEndpointsProtocol=https;AccountName=artivc;AccountKey=a2V5;EndpointSuffix=core.windows.net",
+			expected: azureConnectionString{serviceUrl: "https://artivc.blob.core.windows.net", account: "artivc", accountKey: "a2V5"},
+		},
+		{
+			desc:     "sas",
+			conn:     "BlobEndpoint=https://artivc.blob.core.windows.net/;SharedAccessSignature=sv=2020-10-02&sig=secret",
+			expected: azureConnectionString{serviceUrl: "https://artivc.blob.core.windows.net", account: "artivc", sas: "sv=2020-10-02&sig=secret"},
+		},
+		{
+			desc:     "emulator endpoint",
+			conn:     "DefaultEndpointsProtocol=http;AccountName=devstoreaccount1;AccountKey=a2V5;BlobEndpoint=http://azurite:10000/devstoreaccount1;",
+			expected: azureConnectionString{serviceUrl: "http://azurite:10000/devstoreaccount1", account: "devstoreaccount1", accountKey: "a2V5"},
+		},
+		{
+			desc:     "development storage",
+			conn:     "UseDevelopmentStorage=true",
+			expected: azureConnectionString{serviceUrl: "http://127.0.0.1:10000/devstoreaccount1", account: azuriteAccount, accountKey: azuriteAccountKey},
+		},
+		{desc: "no credential", conn: "AccountName=artivc", err: true},
+		{desc: "no account", conn: "AccountKey=a2V5", err: true},
+		{desc: "malformed", conn: "artivc", err: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			conn, err := parseAzureConnectionString(tC.conn)
+			if tC.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tC.expected, conn)
+		})
+	}
+}
+
+// The repository is not created, so the test does not need the service
+func TestAzureBlobEndpointParam(t *testing.T) {
+	for _, name := range []string{"AZURE_STORAGE_SAS_TOKEN", "AZURE_STORAGE_CONNECTION_STRING", "AZURE_STORAGE_ACCOUNT_KEY"} {
+		t.Setenv(name, "")
+	}
+
+	testCases := []struct {
+		desc   string
+		config AzureBlobConfig
+	}{
+		{desc: "azure ad login"},
+		{desc: "sas token config", config: AzureBlobConfig{SASToken: "sv=2020-10-02&sp=rl&sig=secret"}},
+		{desc: "sas token config and account key", config: AzureBlobConfig{SASToken: "sv=2020-10-02&sp=rl&sig=secret", AccountKey: azuriteAccountKey}},
+		{
+			desc:   "sas in the connection string",
+			config: AzureBlobConfig{ConnectionString: "BlobEndpoint=https://myaccount.blob.core.windows.net;SharedAccessSignature=sv=2020-10-02&sp=rl&sig=secret"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, err := NewAzureBlobRepository("azblob://avc/abc?endpoint=https://example.com/myaccount", tC.config)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "endpoint parameter")
+			}
+		})
+	}
+}
+
+// Run the tests against the Azurite emulator
+//
+// TEST_AZURITE_ENDPOINT=http://127.0.0.1:10000/devstoreaccount1 go test -v ./internal/repository
+func TestAzureBlobRepository(t *testing.T) {
+	endpoint := os.Getenv("TEST_AZURITE_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("TEST_AZURITE_ENDPOINT is not set")
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	for _, name := range []string{"AZURE_STORAGE_SAS_TOKEN", "AZURE_STORAGE_CONNECTION_STRING", "AZURE_STORAGE_ACCOUNT_KEY"} {
+		t.Setenv(name, "")
+	}
+
+	// create the container by the well-known key
+	credential, err := azblob.NewSharedKeyCredential(azuriteAccount, azuriteAccountKey)
+	assert.NoError(t, err)
+	containerName := strings.ToLower(strings.ReplaceAll(t.Name(), "_", "")) + time.Now().Format("20060102150405")
+	container, err := azblob.NewContainerClientWithSharedKey(endpoint+"/"+containerName, credential, nil)
+	assert.NoError(t, err)
+	_, err = container.Create(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer container.Delete(context.Background(), nil)
+
+	sas, err := container.GetSASToken(azblob.ContainerSASPermissions{Read: true, Add: true, Create: true, Write: true, Delete: true, List: true}, time.Now().Add(-time.Minute), time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		desc   string
+		repo   string
+		config AzureBlobConfig
+	}{
+		{desc: "emulator url", repo: endpoint + "/" + containerName + "/emulator"},
+		{desc: "sas url", repo: endpoint + "/" + containerName + "/sas?" + sas.Encode()},
+		{desc: "sas token", repo: endpoint + "/" + containerName + "/token", config: AzureBlobConfig{SASToken: sas.Encode()}},
+		{desc: "endpoint", repo: "azblob://" + containerName + "/endpoint", config: AzureBlobConfig{Endpoint: endpoint}},
+		{
+			desc:   "connection string",
+			repo:   "azblob://" + containerName + "/conn",
+			config: AzureBlobConfig{ConnectionString: "AccountName=" + azuriteAccount + ";AccountKey=" + azuriteAccountKey + ";BlobEndpoint=" + endpoint},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			repo, err := NewAzureBlobRepository(tC.repo, tC.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			testRepository(t, repo)
+		})
+	}
+}
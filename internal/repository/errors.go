@@ -1,6 +1,14 @@
 package repository
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
 
 var ErrUnsupportedRepository = errors.New("Unsupported repository")
 
@@ -11,3 +19,42 @@ type UnsupportedRepositoryError struct {
 func (err UnsupportedRepositoryError) Error() string {
 	return err.Message
 }
+
+type HttpStatusError struct {
+	StatusCode int
+}
+
+func (err HttpStatusError) Error() string {
+	return fmt.Sprintf("status code: %d", err.StatusCode)
+}
+
+// Is reports the not found status as os.ErrNotExist
+func (err HttpStatusError) Is(target error) bool {
+	return target == os.ErrNotExist && err.StatusCode == http.StatusNotFound
+}
+
+// IsNotExist reports whether the error means the object does not exist in the repository. The backends report it in
+// their own ways, e.g. os.ErrNotExist, storage.ErrObjectNotExist of GCS or the 404 status code of S3 and Azure. Other
+// errors (e.g. the access denied or network errors) are not the missing object.
+func IsNotExist(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, storage.ErrObjectNotExist) {
+		return true
+	}
+
+	var errStorage *azblob.StorageError
+	if errors.As(err, &errStorage) {
+		res := errStorage.Response()
+		return res != nil && res.StatusCode == http.StatusNotFound
+	}
+
+	var respErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode() == http.StatusNotFound
+	}
+
+	return false
+}
@@ -2,25 +2,142 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	neturl "net/url"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 )
 
-// Local Filesystem
+// GCSConfig is the settings of the GCS repository. The zero value uses the application default credentials.
+type GCSConfig struct {
+	// The service account key file in JSON. The application default credentials are used if it is empty.
+	CredentialsFile string
+	// The endpoint URL of the service, e.g. the fake-gcs-server emulator. The path "/storage/v1/" is appended if the
+	// URL has no path.
+	Endpoint string
+	// The project billed for the requests to a requester-pays bucket
+	UserProject string
+	// The predefined ACL of the objects, e.g. "bucketOwnerFullControl"
+	PredefinedACL string
+	// Send the requests without the credentials, e.g. to an emulator or a public bucket
+	Anonymous bool
+}
+
+var gcsPredefinedACLs = []string{
+	"authenticatedRead",
+	"bucketOwnerFullControl",
+	"bucketOwnerRead",
+	"private",
+	"projectPrivate",
+	"publicRead",
+}
+
+// ParseGCSParams reads the settings from the query parameters of the repository URL, e.g.
+// "gs://bucket/path?endpoint=http://localhost:4443&anonymous=true".
+//
+// The endpoint in the URL requires the anonymous access or the credentials file of the config. Otherwise a shared
+// URL could send the token of the application default credentials to any host.
+func ParseGCSParams(query neturl.Values, config GCSConfig) (GCSConfig, error) {
+	credentialsFile := config.CredentialsFile
+	err := parseURLParams(query, map[string]*string{
+		"credentials-file": &config.CredentialsFile,
+		"endpoint":         &config.Endpoint,
+		"user-project":     &config.UserProject,
+		"predefined-acl":   &config.PredefinedACL,
+	}, map[string]*bool{
+		"anonymous": &config.Anonymous,
+	})
+	if err != nil {
+		return config, err
+	}
+
+	if query.Get("endpoint") != "" && !config.Anonymous &&
+		(credentialsFile == "" || config.CredentialsFile != credentialsFile) {
+		return config, errors.New("the endpoint parameter requires anonymous=true or the gcs.credentials-file config")
+	}
+
+	return config, nil
+}
+
+func (config GCSConfig) validate() error {
+	if config.Anonymous && config.CredentialsFile != "" {
+		return errors.New("the credentials file cannot be used by the anonymous access")
+	}
+
+	if config.PredefinedACL != "" {
+		valid := false
+		for _, acl := range gcsPredefinedACLs {
+			if config.PredefinedACL == acl {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("invalid predefined ACL: %s", config.PredefinedACL)
+		}
+	}
+
+	return nil
+}
+
+// clientOptions returns the options of the storage client
+func (config GCSConfig) clientOptions() ([]option.ClientOption, error) {
+	opts := []option.ClientOption{}
+
+	if config.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(normalizeKeyPath(config.CredentialsFile)))
+	}
+
+	if config.Anonymous {
+		opts = append(opts, option.WithoutAuthentication())
+	}
+
+	if config.Endpoint != "" {
+		url, err := neturl.Parse(config.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if url.Scheme == "" || url.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint: %s", config.Endpoint)
+		}
+		if url.Path == "" || url.Path == "/" {
+			url.Path = "/storage/v1/"
+		}
+		opts = append(opts, option.WithEndpoint(url.String()))
+	}
+
+	return opts, nil
+}
+
 type GCSRepository struct {
 	Bucket   string
 	BasePath string
 	Client   *storage.Client
+	config   GCSConfig
 }
 
-func NewGCSRepository(bucket, basePath string) (*GCSRepository, error) {
+func NewGCSRepository(bucket, basePath string, gcsConfig GCSConfig) (*GCSRepository, error) {
 	ctx := context.Background()
 	basePath = strings.TrimPrefix(basePath, "/")
-	client, err := storage.NewClient(ctx)
+
+	if err := gcsConfig.validate(); err != nil {
+		return nil, err
+	}
+
+	opts, err := gcsConfig.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := storage.NewClient(ctx, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -29,17 +146,28 @@ func NewGCSRepository(bucket, basePath string) (*GCSRepository, error) {
 		Bucket:   bucket,
 		BasePath: basePath,
 		Client:   client,
+		config:   gcsConfig,
 	}, nil
 }
 
-func (repo *GCSRepository) Upload(localPath, repoPath string, m *Meter) error {
-	ctx := context.Background()
+// bucket returns the bucket handle. The requests are billed to the user project if it is set.
+func (repo *GCSRepository) bucket() *storage.BucketHandle {
+	bkt := repo.Client.Bucket(repo.Bucket)
+	if repo.config.UserProject != "" {
+		bkt = bkt.UserProject(repo.config.UserProject)
+	}
+	return bkt
+}
 
-	// client, bucket, obj
-	client := repo.Client
-	bkt := client.Bucket(repo.Bucket)
-	obj := bkt.Object(filepath.Join(repo.BasePath, repoPath))
+// newWriter creates the writer of the object with the predefined ACL
+func (repo *GCSRepository) newWriter(ctx context.Context, repoPath string) *storage.Writer {
+	obj := repo.bucket().Object(filepath.Join(repo.BasePath, repoPath))
+	writer := obj.NewWriter(ctx)
+	writer.PredefinedACL = repo.config.PredefinedACL
+	return writer
+}
 
+func (repo *GCSRepository) Upload(ctx context.Context, localPath, repoPath string, m *Meter) error {
 	// src
 	src, err := os.Open(localPath)
 	if err != nil {
@@ -47,26 +175,25 @@ func (repo *GCSRepository) Upload(localPath, repoPath string, m *Meter) error {
 	}
 	defer src.Close()
 
-	// dest
-
-	dest := obj.NewWriter(ctx)
-	defer dest.Close()
+	// dest. the upload is discarded if the context is canceled before the writer is closed
+	writerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	dest := repo.newWriter(writerCtx, repoPath)
 
 	// copy
-	_, err = CopyWithMeter(dest, src, m)
+	_, err = CopyWithMeter(ctx, dest, src, m)
 	if err != nil {
+		cancel()
+		dest.Close()
 		return err
 	}
 
-	return nil
+	return dest.Close()
 }
 
-func (repo *GCSRepository) Download(repoPath, localPath string, m *Meter) error {
-	ctx := context.Background()
-
-	// client, bucket, obj
-	client := repo.Client
-	bkt := client.Bucket(repo.Bucket)
+func (repo *GCSRepository) Download(ctx context.Context, repoPath, localPath string, m *Meter) error {
+	// bucket, obj
+	bkt := repo.bucket()
 	obj := bkt.Object(filepath.Join(repo.BasePath, repoPath))
 
 	// src
@@ -84,7 +211,7 @@ func (repo *GCSRepository) Download(repoPath, localPath string, m *Meter) error
 	defer dest.Close()
 
 	// copy
-	_, err = CopyWithMeter(dest, src, m)
+	_, err = CopyWithMeter(ctx, dest, src, m)
 	if err != nil {
 		return err
 	}
@@ -92,12 +219,67 @@ func (repo *GCSRepository) Download(repoPath, localPath string, m *Meter) error
 	return nil
 }
 
-func (repo *GCSRepository) Delete(repoPath string) error {
-	ctx := context.Background()
+func (repo *GCSRepository) UploadStream(ctx context.Context, src io.Reader, repoPath string, m *Meter) error {
+	// the upload is discarded if the context is canceled before the writer is closed
+	writerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	dest := repo.newWriter(writerCtx, repoPath)
+
+	_, err := CopyWithMeter(ctx, dest, src, m)
+	if err != nil {
+		cancel()
+		dest.Close()
+		return err
+	}
+
+	return dest.Close()
+}
+
+func (repo *GCSRepository) DownloadStream(ctx context.Context, repoPath string, dest io.Writer, m *Meter) error {
+	obj := repo.bucket().Object(filepath.Join(repo.BasePath, repoPath))
+
+	src, err := obj.NewReader(ctx)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, err = CopyWithMeter(ctx, dest, src, m)
+	return err
+}
+
+func (repo *GCSRepository) ReadRange(ctx context.Context, repoPath string, offset, length int64) (io.ReadCloser, error) {
+	obj := repo.bucket().Object(filepath.Join(repo.BasePath, repoPath))
 
-	// client, bucket, obj
-	client := repo.Client
-	bkt := client.Bucket(repo.Bucket)
+	// the negative length of the range reader reads to the end as well
+	src, err := obj.NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	return src, nil
+}
+
+// CopyFrom copies the object of another GCS repository by the rewrite API, so the content stays in GCS
+func (repo *GCSRepository) CopyFrom(ctx context.Context, src Repository, srcPath, repoPath string) error {
+	srcRepo, ok := unwrap(src).(*GCSRepository)
+	if !ok || srcRepo.config.Endpoint != repo.config.Endpoint || srcRepo.config.CredentialsFile != repo.config.CredentialsFile || srcRepo.config.Anonymous != repo.config.Anonymous {
+		return ErrCopyNotSupported
+	}
+
+	srcObj := srcRepo.bucket().Object(filepath.Join(srcRepo.BasePath, srcPath))
+	obj := repo.bucket().Object(filepath.Join(repo.BasePath, repoPath))
+
+	// the copier repeats the rewrite requests until the object is copied
+	copier := obj.CopierFrom(srcObj)
+	copier.PredefinedACL = repo.config.PredefinedACL
+	_, err := copier.Run(ctx)
+	return err
+}
+
+func (repo *GCSRepository) Delete(ctx context.Context, repoPath string) error {
+	// bucket, obj
+	bkt := repo.bucket()
 	obj := bkt.Object(filepath.Join(repo.BasePath, repoPath))
 
 	// delete
@@ -109,16 +291,13 @@ func (repo *GCSRepository) Delete(repoPath string) error {
 	return nil
 }
 
-func (repo *GCSRepository) Stat(repoPath string) (FileInfo, error) {
-	ctx := context.Background()
-
-	// client, bucket, obj
-	client := repo.Client
-	bkt := client.Bucket(repo.Bucket)
+func (repo *GCSRepository) Stat(ctx context.Context, repoPath string) (FileInfo, error) {
+	// bucket, obj
+	bkt := repo.bucket()
 	obj := bkt.Object(filepath.Join(repo.BasePath, repoPath))
 
 	// get object stat
-	_, err := obj.Attrs(ctx)
+	attrs, err := obj.Attrs(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -126,16 +305,15 @@ func (repo *GCSRepository) Stat(repoPath string) (FileInfo, error) {
 	return &GCSFileInfo{
 		name:  filepath.Base(repoPath),
 		isDir: false,
+		size:  attrs.Size,
 	}, nil
 }
 
-func (repo *GCSRepository) List(repoPath string) ([]FileInfo, error) {
-	ctx := context.Background()
+func (repo *GCSRepository) List(ctx context.Context, repoPath string) ([]FileInfo, error) {
 	records := []FileInfo{}
 
-	// client, bucket, obj
-	client := repo.Client
-	bkt := client.Bucket(repo.Bucket)
+	// bucket, obj
+	bkt := repo.bucket()
 	prefix := filepath.Join(repo.BasePath, repoPath) + "/"
 	query := &storage.Query{Prefix: prefix, Delimiter: "/"}
 
@@ -154,6 +332,7 @@ func (repo *GCSRepository) List(repoPath string) ([]FileInfo, error) {
 		if attrs.Name != "" {
 			fileinfo.name = attrs.Name[len(prefix):]
 			fileinfo.isDir = false
+			fileinfo.size = attrs.Size
 		} else {
 			fileinfo.name = attrs.Prefix[len(prefix) : len(attrs.Prefix)-1]
 			fileinfo.isDir = true
@@ -167,6 +346,7 @@ func (repo *GCSRepository) List(repoPath string) ([]FileInfo, error) {
 type GCSFileInfo struct {
 	name  string
 	isDir bool
+	size  int64
 }
 
 func (fi *GCSFileInfo) Name() string {
@@ -176,3 +356,21 @@ func (fi *GCSFileInfo) Name() string {
 func (fi *GCSFileInfo) IsDir() bool {
 	return fi.isDir
 }
+
+func (fi *GCSFileInfo) Size() int64 {
+	return fi.size
+}
+
+// IsRetryableError checks the rate limit and server errors of GCS
+func (repo *GCSRepository) IsRetryableError(err error) bool {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return isRetryableStatusCode(apiErr.Code)
+	}
+
+	return isRetryableError(err)
+}
@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gcsTestServer is a minimal GCS compatible server (as fake-gcs-server). It keeps the objects in memory and supports
+// the JSON API and the media download used by the GCS repository.
+type gcsTestServer struct {
+	mtx     sync.Mutex
+	objects map[string][]byte
+	// the query parameters of the requests creating the objects
+	params map[string]neturl.Values
+	// the user projects of the requests, from the query parameter or the header
+	userProjects []string
+	// the authorization headers of the requests
+	authorizations []string
+}
+
+func newGCSTestServer(t *testing.T) (*httptest.Server, *gcsTestServer) {
+	server := &gcsTestServer{objects: map[string][]byte{}, params: map[string]neturl.Values{}}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return ts, server
+}
+
+func (s *gcsTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	query := r.URL.Query()
+	userProject := query.Get("userProject")
+	if userProject == "" {
+		userProject = r.Header.Get("X-Goog-User-Project")
+	}
+	s.userProjects = append(s.userProjects, userProject)
+	s.authorizations = append(s.authorizations, r.Header.Get("Authorization"))
+
+	// the object names are escaped in the path of the JSON API
+	segments := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	for i, segment := range segments {
+		segments[i], _ = neturl.PathUnescape(segment)
+	}
+
+	switch {
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/"):
+		// /upload/storage/v1/b/<bucket>/o
+		s.upload(w, r, segments[4])
+	case strings.HasPrefix(r.URL.Path, "/storage/v1/b/"):
+		// /storage/v1/b/<bucket>/o[/<object>[/rewriteTo/b/<bucket>/o/<object>]]
+		bucket := segments[3]
+		switch {
+		case len(segments) == 5:
+			s.list(w, bucket, query.Get("prefix"), query.Get("delimiter"))
+		case len(segments) == 11 && segments[6] == "rewriteTo":
+			data, ok := s.objects[bucket+"/"+segments[5]]
+			if !ok {
+				writeGCSError(w, http.StatusNotFound)
+				return
+			}
+			name := segments[8] + "/" + segments[10]
+			s.objects[name] = data
+			s.params[name] = query
+			writeJSON(w, map[string]interface{}{
+				"kind":                "storage#rewriteResponse",
+				"done":                true,
+				"totalBytesRewritten": fmt.Sprint(len(data)),
+				"objectSize":          fmt.Sprint(len(data)),
+				"resource":            gcsObjectResource(segments[8], segments[10], data),
+			})
+		case len(segments) == 6:
+			name := bucket + "/" + segments[5]
+			data, ok := s.objects[name]
+			if !ok {
+				writeGCSError(w, http.StatusNotFound)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				delete(s.objects, name)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			writeJSON(w, gcsObjectResource(bucket, segments[5], data))
+		default:
+			writeGCSError(w, http.StatusBadRequest)
+		}
+	case r.Method == http.MethodGet:
+		// /<bucket>/<object>
+		data, ok := s.objects[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			writeGCSError(w, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Write(data)
+	default:
+		writeGCSError(w, http.StatusBadRequest)
+	}
+}
+
+// upload receives the object of the multipart upload. The first part is the metadata and the second is the content.
+func (s *gcsTestServer) upload(w http.ResponseWriter, r *http.Request, bucket string) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		writeGCSError(w, http.StatusBadRequest)
+		return
+	}
+
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	part, err := reader.NextPart()
+	if err != nil {
+		writeGCSError(w, http.StatusBadRequest)
+		return
+	}
+	var metadata struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(part).Decode(&metadata); err != nil {
+		writeGCSError(w, http.StatusBadRequest)
+		return
+	}
+
+	part, err = reader.NextPart()
+	if err != nil {
+		writeGCSError(w, http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(part)
+	if err != nil {
+		writeGCSError(w, http.StatusBadRequest)
+		return
+	}
+
+	name := bucket + "/" + metadata.Name
+	s.objects[name] = data
+	s.params[name] = r.URL.Query()
+	writeJSON(w, gcsObjectResource(bucket, metadata.Name, data))
+}
+
+func (s *gcsTestServer) list(w http.ResponseWriter, bucket, prefix, delimiter string) {
+	items := []interface{}{}
+	prefixes := map[string]bool{}
+	names := []string{}
+	for name := range s.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, bucket+"/"+prefix) {
+			continue
+		}
+		object := strings.TrimPrefix(name, bucket+"/")
+		rest := strings.TrimPrefix(object, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			prefixes[prefix+rest[:i+1]] = true
+			continue
+		}
+		items = append(items, gcsObjectResource(bucket, object, s.objects[name]))
+	}
+
+	result := map[string]interface{}{"kind": "storage#objects", "items": items}
+	if len(prefixes) > 0 {
+		list := []string{}
+		for p := range prefixes {
+			list = append(list, p)
+		}
+		sort.Strings(list)
+		result["prefixes"] = list
+	}
+	writeJSON(w, result)
+}
+
+func gcsObjectResource(bucket, name string, data []byte) map[string]interface{} {
+	return map[string]interface{}{
+		"kind":   "storage#object",
+		"bucket": bucket,
+		"name":   name,
+		"size":   fmt.Sprint(len(data)),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeGCSError(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": status, "message": http.StatusText(status)},
+	})
+}
+
+// setGCSTestEnv points the application default credentials to a missing file and unsets the emulator host, so only
+// the configured credentials and endpoint are used
+func setGCSTestEnv(t *testing.T) {
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(t.TempDir(), "not-exist.json"))
+	t.Setenv("STORAGE_EMULATOR_HOST", "")
+	os.Unsetenv("STORAGE_EMULATOR_HOST")
+}
+
+// setupGCSTestRepository returns the repository on the stand-in server. The server is set by the URL parameters.
+func setupGCSTestRepository(t *testing.T) (string, RepositoryConfig) {
+	setGCSTestEnv(t)
+	ts, _ := newGCSTestServer(t)
+	return "gs://bucket/path/to/repo?endpoint=" + neturl.QueryEscape(ts.URL) + "&anonymous=true", RepositoryConfig{}
+}
+
+func TestGCSRepositoryCredentials(t *testing.T) {
+	setGCSTestEnv(t)
+	ts, server := newGCSTestServer(t)
+
+	// the token endpoint of the service account
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"access_token": "sa-token", "token_type": "Bearer", "expires_in": 3600})
+	}))
+	defer tokenServer.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	credentials, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "test",
+		"private_key_id": "1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"client_email":   "avc@test.iam.gserviceaccount.com",
+		"token_uri":      tokenServer.URL,
+	})
+	assert.NoError(t, err)
+	credentialsFile := filepath.Join(t.TempDir(), "sa.json")
+	assert.NoError(t, os.WriteFile(credentialsFile, credentials, 0600))
+
+	repo, err := NewGCSRepository("bucket", "/repo", GCSConfig{Endpoint: ts.URL, CredentialsFile: credentialsFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStat(t, repo)
+	assert.Equal(t, "Bearer sa-token", server.authorizations[0])
+
+	// no credentials are sent by the anonymous access
+	repo, err = NewGCSRepository("bucket", "/repo", GCSConfig{Endpoint: ts.URL, Anonymous: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.authorizations = nil
+	testStat(t, repo)
+	for _, authorization := range server.authorizations {
+		assert.Equal(t, "", authorization)
+	}
+
+	// the application default credentials are not found
+	repo, err = NewGCSRepository("bucket", "/repo", GCSConfig{Endpoint: ts.URL})
+	if err == nil {
+		_, err = repo.Stat(context.Background(), "bin")
+	}
+	assert.Error(t, err)
+}
+
+func TestGCSObjectOptions(t *testing.T) {
+	setGCSTestEnv(t)
+	ts, server := newGCSTestServer(t)
+	ctx := context.Background()
+
+	repo, err := NewGCSRepository("bucket", "/repo", GCSConfig{
+		Endpoint:      ts.URL,
+		Anonymous:     true,
+		UserProject:   "billing",
+		PredefinedACL: "bucketOwnerFullControl",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := t.TempDir() + "/bin"
+	assert.NoError(t, generateRandomFile(path, 1024))
+	assert.NoError(t, repo.Upload(ctx, path, "objects/ab/bin", nil))
+	assert.NoError(t, repo.UploadStream(ctx, strings.NewReader("commit"), "refs/latest", nil))
+	assert.NoError(t, repo.CopyFrom(ctx, repo, "objects/ab/bin", "objects/ab/copied"))
+	_, err = repo.Stat(ctx, "objects/ab/copied")
+	assert.NoError(t, err)
+
+	for _, name := range []string{"objects/ab/bin", "refs/latest", "objects/ab/copied"} {
+		params := server.params["bucket/repo/"+name]
+		if !assert.NotNil(t, params, name) {
+			continue
+		}
+
+		acl := params.Get("predefinedAcl")
+		if name == "objects/ab/copied" {
+			acl = params.Get("destinationPredefinedAcl")
+		}
+		assert.Equal(t, "bucketOwnerFullControl", acl, name)
+	}
+	for _, userProject := range server.userProjects {
+		assert.Equal(t, "billing", userProject)
+	}
+}
+
+func TestGCSConfigValidate(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		config GCSConfig
+		err    bool
+	}{
+		{desc: "empty", config: GCSConfig{}},
+		{desc: "predefined acl", config: GCSConfig{PredefinedACL: "publicRead"}},
+		{desc: "invalid predefined acl", config: GCSConfig{PredefinedACL: "public-read"}, err: true},
+		{desc: "anonymous with credentials file", config: GCSConfig{Anonymous: true, CredentialsFile: "/tmp/sa.json"}, err: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := tC.config.validate()
+			if tC.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
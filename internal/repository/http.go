@@ -1,88 +1,190 @@
 package repository
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
-	"time"
 )
 
+// HttpConfig is the settings of the http repository
+type HttpConfig struct {
+	// The bearer token sent to the server. The AVC_HTTP_TOKEN environment variable is used if it is empty.
+	Token string
+}
+
+// HttpRepository reads the objects by GET. The writes and the listing are supported by the "avc serve" server.
 type HttpRepository struct {
 	RepoUrl string
+	token   string
 }
 
-func NewHttpRepository(repo string) (*HttpRepository, error) {
+func NewHttpRepository(repo string, config HttpConfig) (*HttpRepository, error) {
 	if !strings.HasSuffix(repo, "/") {
 		repo += "/"
 	}
 
+	token := config.Token
+	if token == "" {
+		token = os.Getenv("AVC_HTTP_TOKEN")
+	}
+
 	return &HttpRepository{
 		RepoUrl: repo,
+		token:   token,
 	}, nil
 }
 
-func (repo *HttpRepository) Upload(localPath, repoPath string, meter *Meter) error {
-	return errors.New("Upload is not supported in Http repository")
-}
+func (repo *HttpRepository) Upload(ctx context.Context, localPath, repoPath string, meter *Meter) error {
+	sourceFileStat, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
 
-func (repo *HttpRepository) Download(repoPath, localPath string, m *Meter) error {
-	filePath, err := getFilePath(repo.RepoUrl, repoPath)
+	if !sourceFileStat.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", localPath)
+	}
+
+	source, err := os.Open(localPath)
 	if err != nil {
 		return err
 	}
+	defer source.Close()
 
-	res, err := http.Get(filePath)
+	return repo.put(ctx, NewMeterReader(ctx, source, meter), sourceFileStat.Size(), repoPath)
+}
+
+func (repo *HttpRepository) Download(ctx context.Context, repoPath, localPath string, m *Meter) error {
+	outputFile, err := os.Create(localPath)
 	if err != nil {
-		retry := 0
-		msg := err.Error()
+		return err
+	}
+	defer outputFile.Close()
 
-		for err != nil && strings.HasSuffix(msg, "connection reset by peer") && retry < 10 {
-			retry++
-			time.Sleep(time.Millisecond * 50 * time.Duration(retry))
-			res, err = http.Get(filePath)
-		}
+	return repo.DownloadStream(ctx, repoPath, outputFile, m)
+}
+
+func (repo *HttpRepository) UploadStream(ctx context.Context, src io.Reader, repoPath string, meter *Meter) error {
+	return repo.put(ctx, NewMeterReader(ctx, src, meter), -1, repoPath)
+}
 
-		if err != nil {
-			return err
+// put uploads the body. The size is -1 if it is unknown.
+func (repo *HttpRepository) put(ctx context.Context, body io.Reader, size int64, repoPath string) error {
+	res, err := repo.do(ctx, http.MethodPut, repoPath, body, func(req *http.Request) {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
 		}
+	})
+	if err != nil {
+		return err
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != 200 {
-		return fmt.Errorf("status code: %d", res.StatusCode)
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusNoContent {
+		return HttpStatusError{StatusCode: res.StatusCode}
 	}
 
-	outputFile, err := os.Create(localPath)
+	return nil
+}
+
+func (repo *HttpRepository) DownloadStream(ctx context.Context, repoPath string, dest io.Writer, m *Meter) error {
+	res, err := repo.get(ctx, repoPath, "")
 	if err != nil {
 		return err
 	}
-	defer outputFile.Close()
+	defer res.Body.Close()
 
-	_, err = CopyWithMeter(outputFile, res.Body, m)
+	if res.StatusCode != http.StatusOK {
+		return HttpStatusError{StatusCode: res.StatusCode}
+	}
+
+	_, err = CopyWithMeter(ctx, dest, res.Body, m)
 	return err
 }
 
-func (repo *HttpRepository) Delete(repoPath string) error {
-	return errors.New("Delete is not supported in Http repository")
+// ReadRange requests the range of the object. If the server ignores the Range header, the bytes before the
+// offset are discarded.
+func (repo *HttpRepository) ReadRange(ctx context.Context, repoPath string, offset, length int64) (io.ReadCloser, error) {
+	res, err := repo.get(ctx, repoPath, httpRange(offset, length))
+	if err != nil {
+		return nil, err
+	}
+
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		return res.Body, nil
+	case http.StatusOK:
+		if _, err := io.CopyN(io.Discard, res.Body, offset); err != nil {
+			res.Body.Close()
+			return nil, err
+		}
+		return limitReadCloser(res.Body, length), nil
+	default:
+		res.Body.Close()
+		return nil, HttpStatusError{StatusCode: res.StatusCode}
+	}
+}
+
+func (repo *HttpRepository) get(ctx context.Context, repoPath, byteRange string) (*http.Response, error) {
+	return repo.do(ctx, http.MethodGet, repoPath, nil, func(req *http.Request) {
+		if byteRange != "" {
+			req.Header.Set("Range", byteRange)
+		}
+	})
 }
 
-func (repo *HttpRepository) Stat(repoPath string) (FileInfo, error) {
+// do sends the request of the repository path with the token
+func (repo *HttpRepository) do(ctx context.Context, method, repoPath string, body io.Reader, prepare func(req *http.Request)) (*http.Response, error) {
 	filePath, err := getFilePath(repo.RepoUrl, repoPath)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := http.Head(filePath)
+	req, err := http.NewRequestWithContext(ctx, method, filePath, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if repo.token != "" {
+		req.Header.Set("Authorization", "Bearer "+repo.token)
+	}
+
+	if prepare != nil {
+		prepare(req)
+	}
+
+	return http.DefaultClient.Do(req)
+}
+
+func (repo *HttpRepository) Delete(ctx context.Context, repoPath string) error {
+	res, err := repo.do(ctx, http.MethodDelete, repoPath, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		return HttpStatusError{StatusCode: res.StatusCode}
+	}
+
+	return nil
+}
+
+func (repo *HttpRepository) Stat(ctx context.Context, repoPath string) (FileInfo, error) {
+	res, err := repo.do(ctx, http.MethodHead, repoPath, nil, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
-		return nil, fmt.Errorf("status code: %d", res.StatusCode)
+		return nil, HttpStatusError{StatusCode: res.StatusCode}
 	}
 
 	info := &HttpFileInfo{
@@ -92,8 +194,69 @@ func (repo *HttpRepository) Stat(repoPath string) (FileInfo, error) {
 	return info, nil
 }
 
-func (repo *HttpRepository) List(repoPath string) ([]FileInfo, error) {
-	return nil, errors.New("List is not supported in Http repository")
+// List requests the JSON listing of the "avc serve" server. If the server cannot list the directory (e.g. a static
+// web server), the static index of the repository is used.
+func (repo *HttpRepository) List(ctx context.Context, repoPath string) ([]FileInfo, error) {
+	entries, err := repo.listByServer(ctx, repoPath)
+	if !errors.Is(err, errListNotSupported) {
+		return entries, err
+	}
+
+	return repo.listByIndex(ctx, repoPath)
+}
+
+var errListNotSupported = errors.New("list is not supported by the server")
+
+func (repo *HttpRepository) listByServer(ctx context.Context, repoPath string) ([]FileInfo, error) {
+	res, err := repo.do(ctx, http.MethodGet, repoPath+"?list", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 500 {
+		return nil, HttpStatusError{StatusCode: res.StatusCode}
+	}
+
+	if res.StatusCode != http.StatusOK || !strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		return nil, errListNotSupported
+	}
+
+	var listing []IndexEntry
+	if err := json.NewDecoder(res.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("invalid listing of %s: %w", repoPath, err)
+	}
+
+	entries := make([]FileInfo, 0, len(listing))
+	for _, entry := range listing {
+		entries = append(entries, &SimpleFileInfo{name: entry.Name, isDir: entry.IsDir})
+	}
+	return entries, nil
+}
+
+func (repo *HttpRepository) listByIndex(ctx context.Context, repoPath string) ([]FileInfo, error) {
+	res, err := repo.get(ctx, IndexPath, "")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, errors.New("List is not supported in Http repository without the index. Please push with the index enabled")
+	} else if res.StatusCode != http.StatusOK {
+		return nil, HttpStatusError{StatusCode: res.StatusCode}
+	}
+
+	var index Index
+	if err := json.NewDecoder(res.Body).Decode(&index); err != nil {
+		return nil, fmt.Errorf("invalid index: %w", err)
+	}
+
+	entries, ok := index.List(strings.Trim(repoPath, "/"))
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the index", repoPath)
+	}
+	return entries, nil
 }
 
 func getFilePath(repoPath, filePath string) (string, error) {
@@ -119,3 +282,13 @@ func (info *HttpFileInfo) Name() string {
 func (info *HttpFileInfo) IsDir() bool {
 	return false
 }
+
+// IsRetryableError checks the http status and network errors
+func (repo *HttpRepository) IsRetryableError(err error) bool {
+	var statusErr HttpStatusError
+	if errors.As(err, &statusErr) {
+		return isRetryableStatusCode(statusErr.StatusCode)
+	}
+
+	return isRetryableError(err)
+}
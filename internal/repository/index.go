@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// IndexPath is the path of the static listing index in the repository
+const IndexPath = "index.json"
+
+// Index is the static listing of the repository directories. It is for the repositories which cannot list the
+// directories, e.g. a static web server.
+type Index struct {
+	UpdatedAt   time.Time               `json:"updatedAt"`
+	Directories map[string][]IndexEntry `json:"directories"`
+}
+
+type IndexEntry struct {
+	Name  string `json:"name"`
+	IsDir bool   `json:"isDir,omitempty"`
+}
+
+// BuildIndex lists the directories of the repository
+func BuildIndex(ctx context.Context, repo Repository, dirs []string) (*Index, error) {
+	index := &Index{
+		UpdatedAt:   time.Now(),
+		Directories: map[string][]IndexEntry{},
+	}
+
+	for _, dir := range dirs {
+		entries, err := repo.List(ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		indexEntries := []IndexEntry{}
+		for _, entry := range entries {
+			indexEntries = append(indexEntries, IndexEntry{Name: entry.Name(), IsDir: entry.IsDir()})
+		}
+		index.Directories[dir] = indexEntries
+	}
+
+	return index, nil
+}
+
+// WriteIndex uploads the index to the repository
+func WriteIndex(ctx context.Context, repo Repository, index *Index) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	return repo.UploadStream(ctx, bytes.NewReader(data), IndexPath, nil)
+}
+
+// List returns the entries of the directory. The directory is not indexed if ok is false.
+func (index *Index) List(dir string) (entries []FileInfo, ok bool) {
+	indexEntries, ok := index.Directories[dir]
+	if !ok {
+		return nil, false
+	}
+
+	entries = make([]FileInfo, 0, len(indexEntries))
+	for _, entry := range indexEntries {
+		entries = append(entries, &SimpleFileInfo{name: entry.Name, isDir: entry.IsDir})
+	}
+	return entries, true
+}
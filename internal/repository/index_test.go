@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+	repoDir := t.TempDir()
+	local, err := NewLocalFileSystemRepository(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"refs/latest", "refs/tags/v1", "refs/tags/v2", "commits/abc"} {
+		assert.NoError(t, local.UploadStream(ctx, strings.NewReader("data"), path, nil))
+	}
+
+	// a static web server
+	ts := httptest.NewServer(http.FileServer(http.Dir(repoDir)))
+	defer ts.Close()
+
+	repo, err := NewHttpRepository(ts.URL, HttpConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.List(ctx, "refs/tags")
+	assert.Error(t, err, "the listing is not supported without the index")
+
+	index, err := BuildIndex(ctx, local, []string{"refs", "refs/tags", "commits"})
+	assert.NoError(t, err)
+	assert.NoError(t, WriteIndex(ctx, local, index))
+
+	testCases := []struct {
+		desc  string
+		dir   string
+		names []string
+		err   bool
+	}{
+		{desc: "refs", dir: "refs", names: []string{"latest", "tags"}},
+		{desc: "tags", dir: "refs/tags", names: []string{"v1", "v2"}},
+		{desc: "commits", dir: "commits", names: []string{"abc"}},
+		{desc: "not indexed", dir: "objects", err: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			entries, err := repo.List(ctx, tC.dir)
+			if tC.err {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			names := []string{}
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			sort.Strings(names)
+			assert.Equal(t, tC.names, names)
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path"
@@ -36,7 +38,7 @@ func NewLocalFileSystemRepository(repoDir string) (*LocalFileSystemRepository, e
 	}, nil
 }
 
-func (repo *LocalFileSystemRepository) Upload(localPath, repoPath string, m *Meter) error {
+func (repo *LocalFileSystemRepository) Upload(ctx context.Context, localPath, repoPath string, m *Meter) error {
 	sourceFileStat, err := os.Stat(localPath)
 	if err != nil {
 		return err
@@ -52,9 +54,38 @@ func (repo *LocalFileSystemRepository) Upload(localPath, repoPath string, m *Met
 	}
 	defer source.Close()
 
+	return repo.UploadStream(ctx, source, repoPath, m)
+}
+
+func (repo *LocalFileSystemRepository) Download(ctx context.Context, repoPath, localPath string, m *Meter) error {
+	srcPath := path.Join(repo.RepoDir, repoPath)
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	written, err := CopyWithMeter(ctx, dest, src, m)
+	if err != nil {
+		return err
+	}
+
+	if written == 0 {
+		err = os.Truncate(localPath, 0)
+	}
+
+	return err
+}
+
+func (repo *LocalFileSystemRepository) UploadStream(ctx context.Context, src io.Reader, repoPath string, m *Meter) error {
 	// Copy from source to tmp
 	tmpDir := path.Join(repo.RepoDir, "tmp")
-	err = os.MkdirAll(tmpDir, fs.ModePerm)
+	err := os.MkdirAll(tmpDir, fs.ModePerm)
 	if err != nil {
 		return err
 	}
@@ -65,8 +96,9 @@ func (repo *LocalFileSystemRepository) Upload(localPath, repoPath string, m *Met
 	}
 	tmpPath := tmp.Name()
 	defer os.Remove(tmpPath)
-	_, err = CopyWithMeter(tmp, source, m)
+	_, err = CopyWithMeter(ctx, tmp, src, m)
 	if err != nil {
+		tmp.Close()
 		return err
 	}
 	err = tmp.Close()
@@ -93,7 +125,7 @@ func (repo *LocalFileSystemRepository) Upload(localPath, repoPath string, m *Met
 	return nil
 }
 
-func (repo *LocalFileSystemRepository) Download(repoPath, localPath string, m *Meter) error {
+func (repo *LocalFileSystemRepository) DownloadStream(ctx context.Context, repoPath string, dest io.Writer, m *Meter) error {
 	srcPath := path.Join(repo.RepoDir, repoPath)
 	src, err := os.Open(srcPath)
 	if err != nil {
@@ -101,34 +133,75 @@ func (repo *LocalFileSystemRepository) Download(repoPath, localPath string, m *M
 	}
 	defer src.Close()
 
-	dest, err := os.Create(localPath)
+	_, err = CopyWithMeter(ctx, dest, src, m)
+	return err
+}
+
+func (repo *LocalFileSystemRepository) ReadRange(ctx context.Context, repoPath string, offset, length int64) (io.ReadCloser, error) {
+	srcPath := path.Join(repo.RepoDir, repoPath)
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		src.Close()
+		return nil, err
+	}
+
+	return limitReadCloser(src, length), nil
+}
+
+// CopyFrom hard links the file of another local repository. The files are never modified in place, so the link is
+// as good as a copy. It is not supported across the filesystems.
+func (repo *LocalFileSystemRepository) CopyFrom(ctx context.Context, src Repository, srcPath, repoPath string) error {
+	srcRepo, ok := unwrap(src).(*LocalFileSystemRepository)
+	if !ok {
+		return ErrCopyNotSupported
+	}
+
+	tmpDir := path.Join(repo.RepoDir, "tmp")
+	err := os.MkdirAll(tmpDir, fs.ModePerm)
 	if err != nil {
 		return err
 	}
-	defer dest.Close()
-	written, err := CopyWithMeter(dest, src, m)
+
+	tmp, err := os.CreateTemp(tmpDir, "*")
 	if err != nil {
 		return err
 	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpPath)
 
-	if written == 0 {
-		err = os.Truncate(localPath, 0)
+	if err := os.Link(path.Join(srcRepo.RepoDir, srcPath), tmpPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return ErrCopyNotSupported
 	}
+	defer os.Remove(tmpPath)
 
-	return err
+	destPath := path.Join(repo.RepoDir, repoPath)
+	err = os.MkdirAll(filepath.Dir(destPath), fs.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, destPath)
 }
 
-func (repo *LocalFileSystemRepository) Delete(repoPath string) error {
+func (repo *LocalFileSystemRepository) Delete(ctx context.Context, repoPath string) error {
 	filePath := path.Join(repo.RepoDir, repoPath)
 	return os.Remove(filePath)
 }
 
-func (repo *LocalFileSystemRepository) Stat(repoPath string) (FileInfo, error) {
+func (repo *LocalFileSystemRepository) Stat(ctx context.Context, repoPath string) (FileInfo, error) {
 	filePath := path.Join(repo.RepoDir, repoPath)
 	return os.Stat(filePath)
 }
 
-func (repo *LocalFileSystemRepository) List(repoPath string) ([]FileInfo, error) {
+func (repo *LocalFileSystemRepository) List(ctx context.Context, repoPath string) ([]FileInfo, error) {
 	dir := path.Join(repo.RepoDir, repoPath)
 	fs, err := os.ReadDir(dir)
 	if err != nil {
@@ -144,3 +217,8 @@ func (repo *LocalFileSystemRepository) List(repoPath string) ([]FileInfo, error)
 	}
 	return fs2, nil
 }
+
+// IsRetryableError returns false because the errors of the local filesystem are not transient
+func (repo *LocalFileSystemRepository) IsRetryableError(err error) bool {
+	return false
+}
@@ -1,6 +1,9 @@
 package repository
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"os"
 	"testing"
 
@@ -34,7 +37,7 @@ func TestLocalUpload(t *testing.T) {
 				t.Error(err)
 			}
 
-			err = repo.Upload(tmpDir+"/test", "path/to/the/test", nil)
+			err = repo.Upload(context.Background(), tmpDir+"/test", "path/to/the/test", nil)
 			if err != nil {
 				t.Error(err)
 			}
@@ -79,7 +82,7 @@ func TestLocalDownload(t *testing.T) {
 				t.Error(err)
 			}
 
-			err = repo.Download("path/to/the/test", tmpDir+"/test", nil)
+			err = repo.Download(context.Background(), "path/to/the/test", tmpDir+"/test", nil)
 			if err != nil {
 				t.Error(err)
 			}
@@ -91,3 +94,142 @@ func TestLocalDownload(t *testing.T) {
 		})
 	}
 }
+
+func TestLocalUploadCanceled(t *testing.T) {
+	repoDir := t.TempDir()
+	tmpDir := t.TempDir()
+
+	repo, err := NewLocalFileSystemRepository(repoDir)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = os.WriteFile(tmpDir+"/test", []byte("hello"), 0644)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = repo.Upload(ctx, tmpDir+"/test", "path/to/the/test", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = os.Stat(repoDir + "/path/to/the/test")
+	assert.True(t, os.IsNotExist(err))
+
+	entries, err := os.ReadDir(repoDir + "/tmp")
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "the temp file should be removed")
+}
+
+func TestLocalStream(t *testing.T) {
+	repo, err := NewLocalFileSystemRepository(t.TempDir())
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = repo.UploadStream(context.Background(), bytes.NewReader([]byte("hello")), "path/to/the/test", nil)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = repo.DownloadStream(context.Background(), "path/to/the/test", &buf, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", buf.String())
+
+	err = repo.DownloadStream(context.Background(), "path/to/the/notfound", &buf, nil)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// streamOnlyRepository hides the native range reading of the repository
+type streamOnlyRepository struct {
+	Repository
+}
+
+func TestReadRange(t *testing.T) {
+	local, err := NewLocalFileSystemRepository(t.TempDir())
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = local.UploadStream(context.Background(), bytes.NewReader([]byte("0123456789")), "test", nil)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		desc   string
+		offset int64
+		length int64
+		data   string
+	}{
+		{desc: "all", offset: 0, length: -1, data: "0123456789"},
+		{desc: "to the end", offset: 3, length: -1, data: "3456789"},
+		{desc: "middle", offset: 3, length: 4, data: "3456"},
+		{desc: "exceed the end", offset: 8, length: 10, data: "89"},
+		{desc: "empty", offset: 3, length: 0, data: ""},
+	}
+	for _, tC := range testCases {
+		for name, repo := range map[string]Repository{"native": local, "fallback": &streamOnlyRepository{local}} {
+			t.Run(tC.desc+" "+name, func(t *testing.T) {
+				reader, err := ReadRange(context.Background(), repo, "test", tC.offset, tC.length)
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer reader.Close()
+
+				data, err := io.ReadAll(reader)
+				assert.NoError(t, err)
+				assert.Equal(t, tC.data, string(data))
+			})
+		}
+	}
+}
+
+func TestLocalCopy(t *testing.T) {
+	srcDir := t.TempDir()
+	src, err := NewLocalFileSystemRepository(srcDir)
+	if err != nil {
+		t.Error(err)
+	}
+	dstDir := t.TempDir()
+	dst, err := NewLocalFileSystemRepository(dstDir)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = src.UploadStream(context.Background(), bytes.NewReader([]byte("hello")), "path/to/the/test", nil)
+	assert.NoError(t, err)
+
+	// the decorators are unwrapped
+	err = Copy(context.Background(), newTestRetryRepository(src), "path/to/the/test", newTestRetryRepository(dst), "copied/test")
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(dstDir + "/copied/test")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	srcInfo, err := os.Stat(srcDir + "/path/to/the/test")
+	assert.NoError(t, err)
+	dstInfo, err := os.Stat(dstDir + "/copied/test")
+	assert.NoError(t, err)
+	assert.True(t, os.SameFile(srcInfo, dstInfo), "the file should be hard linked")
+
+	entries, err := os.ReadDir(dstDir + "/tmp")
+	assert.NoError(t, err)
+	assert.Empty(t, entries, "the temp file should be removed")
+
+	// the object is replaced without modifying the source
+	err = dst.UploadStream(context.Background(), bytes.NewReader([]byte("world")), "copied/test", nil)
+	assert.NoError(t, err)
+	data, err = os.ReadFile(srcDir + "/path/to/the/test")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	err = Copy(context.Background(), src, "path/to/the/notfound", dst, "copied/notfound")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	err = Copy(context.Background(), &streamOnlyRepository{src}, "path/to/the/test", dst, "copied/test")
+	assert.ErrorIs(t, err, ErrCopyNotSupported, "the source of another provider is not copied natively")
+
+	err = Copy(context.Background(), src, "path/to/the/test", &streamOnlyRepository{dst}, "copied/test")
+	assert.ErrorIs(t, err, ErrCopyNotSupported, "the destination is not a copier")
+}
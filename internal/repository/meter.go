@@ -1,8 +1,10 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -44,8 +46,10 @@ func (b ByteSize) String() string {
 }
 
 type Session struct {
+	mtx       sync.Mutex
 	startedAt time.Time
 	meters    []*Meter
+	limiter   *RateLimiter
 }
 
 func NewSession() *Session {
@@ -55,27 +59,43 @@ func NewSession() *Session {
 	}
 }
 
+// SetRateLimiter sets the rate limiter shared by the meters created afterward
+func (s *Session) SetRateLimiter(limiter *RateLimiter) {
+	s.limiter = limiter
+}
+
 func (s *Session) NewMeter() *Meter {
 	meter := &Meter{
-		total: 0,
+		total:   0,
+		limiter: s.limiter,
 	}
+	s.mtx.Lock()
 	s.meters = append(s.meters, meter)
+	s.mtx.Unlock()
 	return meter
 }
 
-func (s *Session) CalculateSpeed() ByteSize {
-	totalDiff := time.Since(s.startedAt).Seconds()
+// TotalBytes returns the bytes transferred by all the meters
+func (s *Session) TotalBytes() int64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
 	var total int64
 	for _, meter := range s.meters {
-		total = total + meter.total
+		total = total + atomic.LoadInt64(&meter.total)
 	}
+	return total
+}
 
-	speed := float64(total) / totalDiff
+func (s *Session) CalculateSpeed() ByteSize {
+	totalDiff := time.Since(s.startedAt).Seconds()
+	speed := float64(s.TotalBytes()) / totalDiff
 	return ByteSize(speed)
 }
 
 type Meter struct {
-	total int64
+	total   int64
+	limiter *RateLimiter
 }
 
 func (m *Meter) Write(p []byte) (n int, err error) {
@@ -92,12 +112,46 @@ func (m *Meter) SetBytes(bytes int64) {
 	atomic.StoreInt64(&m.total, bytes)
 }
 
-func CopyWithMeter(dest io.Writer, src io.Reader, meter *Meter) (int64, error) {
+// Wait blocks until the rate limiter of the meter allows to transfer n bytes
+func (m *Meter) Wait(ctx context.Context, n int) error {
+	if m == nil || m.limiter == nil || n <= 0 {
+		return nil
+	}
+
+	return m.limiter.WaitN(ctx, n)
+}
+
+func CopyWithMeter(ctx context.Context, dest io.Writer, src io.Reader, meter *Meter) (int64, error) {
 	buf := make([]byte, 1024*1024)
+	return io.CopyBuffer(dest, NewMeterReader(ctx, src, meter), buf)
+}
 
+// NewMeterReader wraps the reader to count the read bytes by the meter, throttle the reading by the rate limiter
+// of the meter and stop once the context is done. The meter can be nil.
+func NewMeterReader(ctx context.Context, src io.Reader, meter *Meter) io.Reader {
+	src = &contextReader{ctx: ctx, reader: src, meter: meter}
 	if meter != nil {
-		return io.CopyBuffer(dest, io.TeeReader(src, meter), buf)
+		return io.TeeReader(src, meter)
+	}
+
+	return src
+}
+
+// contextReader stops the reading once the context is done and throttles the reading by the rate limiter of the meter
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+	meter  *Meter
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
 	}
 
-	return io.CopyBuffer(dest, src, buf)
+	n, err := r.reader.Read(p)
+	if waitErr := r.meter.Wait(r.ctx, n); waitErr != nil {
+		return n, waitErr
+	}
+	return n, err
 }
@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BandwidthLimit is the bandwidth limits of uploading and downloading
+type BandwidthLimit struct {
+	Upload   BandwidthSchedule
+	Download BandwidthSchedule
+}
+
+// BandwidthSchedule is the time-of-day timetable of the bandwidth limit. The rate of an entry is effective
+// from its start time to the start time of the next entry.
+type BandwidthSchedule []BandwidthScheduleEntry
+
+type BandwidthScheduleEntry struct {
+	// The minutes from the midnight
+	Start int
+	// The bytes per second. 0 means unlimited.
+	Rate int64
+}
+
+// ParseBandwidthLimit parses the bandwidth limit in the rclone "--bwlimit" syntax.
+//
+//	""                       unlimited
+//	"10M"                    10 MiB/s for uploading and downloading
+//	"10M:1M"                 10 MiB/s for uploading and 1 MiB/s for downloading
+//	"08:00,1M 18:00,off"     1 MiB/s from 08:00 to 18:00, unlimited from 18:00 to 08:00
+//	"08:00,1M:off 18:00,off" 1 MiB/s for uploading only from 08:00 to 18:00
+//
+// The units are B, K, M, G and T in power of 1024. A rate without unit is in bytes per second.
+func ParseBandwidthLimit(value string) (BandwidthLimit, error) {
+	var limit BandwidthLimit
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return limit, nil
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) == 1 && !strings.Contains(fields[0], ",") {
+		up, down, err := parseBandwidthRates(fields[0])
+		if err != nil {
+			return limit, err
+		}
+
+		limit.Upload = BandwidthSchedule{{Start: 0, Rate: up}}
+		limit.Download = BandwidthSchedule{{Start: 0, Rate: down}}
+		return limit, nil
+	}
+
+	for _, field := range fields {
+		comps := strings.SplitN(field, ",", 2)
+		if len(comps) != 2 {
+			return limit, fmt.Errorf("invalid bandwidth timetable entry: %s", field)
+		}
+
+		start, err := parseTimeOfDay(comps[0])
+		if err != nil {
+			return limit, err
+		}
+
+		up, down, err := parseBandwidthRates(comps[1])
+		if err != nil {
+			return limit, err
+		}
+
+		limit.Upload = append(limit.Upload, BandwidthScheduleEntry{Start: start, Rate: up})
+		limit.Download = append(limit.Download, BandwidthScheduleEntry{Start: start, Rate: down})
+	}
+
+	sort.SliceStable(limit.Upload, func(i, j int) bool { return limit.Upload[i].Start < limit.Upload[j].Start })
+	sort.SliceStable(limit.Download, func(i, j int) bool { return limit.Download[i].Start < limit.Download[j].Start })
+
+	return limit, nil
+}
+
+func parseBandwidthRates(value string) (up int64, down int64, err error) {
+	comps := strings.Split(value, ":")
+	if len(comps) > 2 {
+		return 0, 0, fmt.Errorf("invalid bandwidth limit: %s", value)
+	}
+
+	up, err = parseBandwidthRate(comps[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	down = up
+	if len(comps) == 2 {
+		down, err = parseBandwidthRate(comps[1])
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return up, down, nil
+}
+
+func parseBandwidthRate(value string) (int64, error) {
+	if value == "off" || value == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	switch value[len(value)-1] {
+	case 'b', 'B':
+		value = value[:len(value)-1]
+	case 'k', 'K':
+		multiplier = int64(KB)
+		value = value[:len(value)-1]
+	case 'm', 'M':
+		multiplier = int64(MB)
+		value = value[:len(value)-1]
+	case 'g', 'G':
+		multiplier = int64(GB)
+		value = value[:len(value)-1]
+	case 't', 'T':
+		multiplier = int64(TB)
+		value = value[:len(value)-1]
+	}
+
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 {
+		return 0, fmt.Errorf("invalid bandwidth rate: %s", value)
+	}
+
+	return int64(rate * float64(multiplier)), nil
+}
+
+func parseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day: %s", value)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// IsUnlimited returns true if there is no limit at any time
+func (schedule BandwidthSchedule) IsUnlimited() bool {
+	for _, entry := range schedule {
+		if entry.Rate > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// RateAt returns the bytes per second at the time. 0 means unlimited.
+func (schedule BandwidthSchedule) RateAt(t time.Time) int64 {
+	if len(schedule) == 0 {
+		return 0
+	}
+
+	// before the first entry, the last entry of the previous day is effective
+	minutes := t.Hour()*60 + t.Minute()
+	rate := schedule[len(schedule)-1].Rate
+	for _, entry := range schedule {
+		if entry.Start > minutes {
+			break
+		}
+		rate = entry.Rate
+	}
+
+	return rate
+}
+
+// RateLimiter is a token bucket shared by the concurrent transfers. The bucket holds at most one second of tokens.
+// A transfer can take more tokens than available and then waits until the debt is paid off.
+type RateLimiter struct {
+	mtx      sync.Mutex
+	schedule BandwidthSchedule
+	tokens   float64
+	last     time.Time
+	now      func() time.Time
+}
+
+func NewRateLimiter(schedule BandwidthSchedule) *RateLimiter {
+	return &RateLimiter{
+		schedule: schedule,
+		now:      time.Now,
+	}
+}
+
+// WaitN blocks until n bytes are allowed to transfer or the context is done
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	wait := l.reserve(n)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes n tokens and returns the duration to wait
+func (l *RateLimiter) reserve(n int) time.Duration {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := l.now()
+	rate := float64(l.schedule.RateAt(now))
+	if rate <= 0 {
+		l.tokens = 0
+		l.last = now
+		return 0
+	}
+
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * rate
+	}
+	if l.tokens > rate {
+		l.tokens = rate
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / rate * float64(time.Second))
+}
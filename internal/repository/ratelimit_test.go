@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBandwidthLimit(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		in       string
+		upload   BandwidthSchedule
+		download BandwidthSchedule
+	}{
+		{desc: "unlimited", in: ""},
+		{desc: "off", in: "off", upload: BandwidthSchedule{{0, 0}}, download: BandwidthSchedule{{0, 0}}},
+		{desc: "bytes", in: "1000", upload: BandwidthSchedule{{0, 1000}}, download: BandwidthSchedule{{0, 1000}}},
+		{desc: "units", in: "1.5K", upload: BandwidthSchedule{{0, 1536}}, download: BandwidthSchedule{{0, 1536}}},
+		{desc: "upload and download", in: "10M:1M", upload: BandwidthSchedule{{0, 10 << 20}}, download: BandwidthSchedule{{0, 1 << 20}}},
+		{desc: "upload only", in: "1G:off", upload: BandwidthSchedule{{0, 1 << 30}}, download: BandwidthSchedule{{0, 0}}},
+		{
+			desc:     "timetable",
+			in:       "18:00,off 08:00,1M:2M",
+			upload:   BandwidthSchedule{{8 * 60, 1 << 20}, {18 * 60, 0}},
+			download: BandwidthSchedule{{8 * 60, 2 << 20}, {18 * 60, 0}},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			limit, err := ParseBandwidthLimit(tC.in)
+			assert.NoError(t, err)
+			assert.Equal(t, tC.upload, limit.Upload)
+			assert.Equal(t, tC.download, limit.Download)
+		})
+	}
+
+	for _, in := range []string{"abc", "10X", "1M:1M:1M", "25:00,1M", "08:00,1M 18:00"} {
+		_, err := ParseBandwidthLimit(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestBandwidthScheduleRateAt(t *testing.T) {
+	schedule := BandwidthSchedule{{8 * 60, 100}, {18 * 60, 0}}
+	at := func(hour, min int) time.Time {
+		return time.Date(2022, 1, 1, hour, min, 0, 0, time.Local)
+	}
+
+	assert.Equal(t, int64(0), schedule.RateAt(at(7, 59)))
+	assert.Equal(t, int64(100), schedule.RateAt(at(8, 0)))
+	assert.Equal(t, int64(100), schedule.RateAt(at(17, 59)))
+	assert.Equal(t, int64(0), schedule.RateAt(at(18, 0)))
+	assert.False(t, schedule.IsUnlimited())
+	assert.True(t, BandwidthSchedule{}.IsUnlimited())
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.Local)
+	limiter := NewRateLimiter(BandwidthSchedule{{0, 1000}})
+	limiter.now = func() time.Time { return now }
+
+	// the debt is shared by the transfers
+	assert.Equal(t, time.Duration(0), limiter.reserve(0))
+	assert.Equal(t, 500*time.Millisecond, limiter.reserve(500))
+	assert.Equal(t, time.Second, limiter.reserve(500))
+
+	// the tokens are refilled by time
+	now = now.Add(time.Second)
+	assert.Equal(t, time.Duration(0), limiter.reserve(0))
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, time.Duration(0), limiter.reserve(1000))
+	assert.Equal(t, 100*time.Millisecond, limiter.reserve(100))
+}
@@ -2,10 +2,15 @@ package repository
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 )
 
 // Local Filesystem
@@ -27,8 +32,8 @@ func NewRcloneRepository(remote, basePath string) (*RcloneRepository, error) {
 	}, nil
 }
 
-func (repo *RcloneRepository) Upload(localPath, repoPath string, m *Meter) error {
-	cmd := exec.Command("rclone", "copyto", "--no-check-dest", localPath, repo.remotePath(repoPath))
+func (repo *RcloneRepository) Upload(ctx context.Context, localPath, repoPath string, m *Meter) error {
+	cmd := exec.CommandContext(ctx, "rclone", "copyto", "--no-check-dest", localPath, repo.remotePath(repoPath))
 	err := cmd.Run()
 	if err != nil {
 		return err
@@ -37,18 +42,77 @@ func (repo *RcloneRepository) Upload(localPath, repoPath string, m *Meter) error
 	return nil
 }
 
-func (repo *RcloneRepository) Download(repoPath, localPath string, m *Meter) error {
-	cmd := exec.Command("rclone", "copyto", "--no-check-dest", repo.remotePath(repoPath), localPath)
+func (repo *RcloneRepository) Download(ctx context.Context, repoPath, localPath string, m *Meter) error {
+	cmd := exec.CommandContext(ctx, "rclone", "copyto", "--no-check-dest", repo.remotePath(repoPath), localPath)
 	err := cmd.Run()
 	if err != nil {
-		return err
+		return rcloneError(err)
 	}
 
 	return nil
 }
 
-func (repo *RcloneRepository) Delete(repoPath string) error {
-	cmd := exec.Command("rclone", "deletefile", repo.remotePath(repoPath))
+func (repo *RcloneRepository) UploadStream(ctx context.Context, src io.Reader, repoPath string, m *Meter) error {
+	cmd := exec.CommandContext(ctx, "rclone", "rcat", repo.remotePath(repoPath))
+	cmd.Stdin = NewMeterReader(ctx, src, m)
+	return cmd.Run()
+}
+
+func (repo *RcloneRepository) DownloadStream(ctx context.Context, repoPath string, dest io.Writer, m *Meter) error {
+	cmd := exec.CommandContext(ctx, "rclone", "cat", repo.remotePath(repoPath))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	_, err = CopyWithMeter(ctx, dest, stdout, m)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	return rcloneError(cmd.Wait())
+}
+
+// rcloneError reports the "directory not found" (3) and "file not found" (4) exit codes of rclone as os.ErrNotExist
+func rcloneError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && (exitErr.ExitCode() == 3 || exitErr.ExitCode() == 4) {
+		return fmt.Errorf("%w: %s", os.ErrNotExist, err.Error())
+	}
+	return err
+}
+
+func (repo *RcloneRepository) ReadRange(ctx context.Context, repoPath string, offset, length int64) (io.ReadCloser, error) {
+	args := []string{"cat", "--offset", strconv.FormatInt(offset, 10)}
+	if length >= 0 {
+		args = append(args, "--count", strconv.FormatInt(length, 10))
+	}
+	args = append(args, repo.remotePath(repoPath))
+
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := exec.CommandContext(ctx, "rclone", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &rcloneReader{ReadCloser: stdout, cmd: cmd, cancel: cancel}, nil
+}
+
+func (repo *RcloneRepository) Delete(ctx context.Context, repoPath string) error {
+	cmd := exec.CommandContext(ctx, "rclone", "deletefile", repo.remotePath(repoPath))
 	err := cmd.Run()
 	if err != nil {
 		return err
@@ -57,9 +121,9 @@ func (repo *RcloneRepository) Delete(repoPath string) error {
 	return nil
 }
 
-func (repo *RcloneRepository) Stat(repoPath string) (FileInfo, error) {
+func (repo *RcloneRepository) Stat(ctx context.Context, repoPath string) (FileInfo, error) {
 	var out bytes.Buffer
-	cmd := exec.Command("rclone", "size", "--json", repo.remotePath(repoPath))
+	cmd := exec.CommandContext(ctx, "rclone", "size", "--json", repo.remotePath(repoPath))
 	cmd.Stdout = &out
 	if err := cmd.Run(); err != nil {
 		return nil, err
@@ -84,9 +148,9 @@ func (repo *RcloneRepository) Stat(repoPath string) (FileInfo, error) {
 	}, nil
 }
 
-func (repo *RcloneRepository) List(repoPath string) ([]FileInfo, error) {
+func (repo *RcloneRepository) List(ctx context.Context, repoPath string) ([]FileInfo, error) {
 	var out bytes.Buffer
-	cmd := exec.Command("rclone", "lsjson", repo.remotePath(repoPath))
+	cmd := exec.CommandContext(ctx, "rclone", "lsjson", repo.remotePath(repoPath))
 	cmd.Stdout = &out
 	err := cmd.Run()
 	if err != nil {
@@ -111,6 +175,34 @@ func (repo *RcloneRepository) remotePath(repoPath string) string {
 	return repo.Remote + ":" + path
 }
 
+// rcloneReader reads the stdout of rclone. The exit error is returned at the end of the output.
+type rcloneReader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	waited bool
+}
+
+func (r *rcloneReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err == io.EOF && !r.waited {
+		r.waited = true
+		if waitErr := r.cmd.Wait(); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func (r *rcloneReader) Close() error {
+	r.cancel()
+	if !r.waited {
+		r.waited = true
+		r.cmd.Wait()
+	}
+	return nil
+}
+
 type RcloneFileInfo struct {
 	Name_  string `json:"Name"`
 	IsDir_ bool   `json:"IsDir"`
@@ -123,3 +215,13 @@ func (e *RcloneFileInfo) Name() string {
 func (e *RcloneFileInfo) IsDir() bool {
 	return e.IsDir_
 }
+
+// IsRetryableError checks the exit code of rclone. The exit code 5 stands for a temporary error.
+func (repo *RcloneRepository) IsRetryableError(err error) bool {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode() == 5
+	}
+
+	return isRetryableError(err)
+}
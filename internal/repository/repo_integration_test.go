@@ -4,6 +4,7 @@
 package repository
 
 import (
+	"context"
 	cryptorand "crypto/rand"
 	"crypto/sha1"
 	"fmt"
@@ -27,7 +28,7 @@ func getRepo() (Repository, error) {
 	if err != nil {
 		return nil, err
 	}
-	return NewRepository(result)
+	return NewRepository(result, RepositoryConfig{})
 }
 
 func sha1sum(path string) string {
@@ -59,6 +60,46 @@ func generateRandomFile(path string, size int64) error {
 	return nil
 }
 
+// testRepository runs the shared tests of the repository operations. The copy is skipped if the backend does not
+// support it.
+func testRepository(t *testing.T, repo Repository) {
+	t.Run("transfer", func(t *testing.T) { testTransfer(t, repo) })
+	t.Run("stat", func(t *testing.T) { testStat(t, repo) })
+	t.Run("list", func(t *testing.T) { testList(t, repo) })
+	t.Run("copy", func(t *testing.T) { testCopy(t, repo) })
+}
+
+// TestRepositoryBackends runs the shared tests against the stand-in server of each backend. The tests of the backend
+// specific settings are in the test files of the backends.
+func TestRepositoryBackends(t *testing.T) {
+	testCases := []struct {
+		desc string
+		// start the stand-in server and return the repository URL and the config
+		setup func(t *testing.T) (string, RepositoryConfig)
+	}{
+		{desc: "local", setup: func(t *testing.T) (string, RepositoryConfig) { return t.TempDir(), RepositoryConfig{} }},
+		{desc: "s3", setup: setupS3TestRepository},
+		{desc: "gcs", setup: setupGCSTestRepository},
+		{desc: "webdav", setup: setupWebDAVTestRepository},
+		{desc: "ssh", setup: setupSSHTestRepository},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			repoUrl, config := tC.setup(t)
+			result, err := ParseRepo(repoUrl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo, err := NewRepository(result, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			testRepository(t, repo)
+		})
+	}
+}
+
 func Test_Transfer(t *testing.T) {
 	repo, err := getRepo()
 	if repo == nil {
@@ -69,6 +110,11 @@ func Test_Transfer(t *testing.T) {
 		t.Error(err)
 	}
 
+	testTransfer(t, repo)
+}
+
+func testTransfer(t *testing.T, repo Repository) {
+
 	testCases := []struct {
 		desc     string
 		size     int64
@@ -85,17 +131,17 @@ func Test_Transfer(t *testing.T) {
 			path := tmpDir + "/in"
 			assert.NoError(t, generateRandomFile(path, tC.size))
 
-			if err := repo.Upload(path, tC.repoPath, nil); err != nil {
+			if err := repo.Upload(context.Background(), path, tC.repoPath, nil); err != nil {
 				t.Error(err)
 			}
 
-			if err := repo.Download(tC.repoPath, tmpDir+"/out", nil); err != nil {
+			if err := repo.Download(context.Background(), tC.repoPath, tmpDir+"/out", nil); err != nil {
 				t.Error(err)
 			}
 
 			assert.Equal(t, sha1sum(tmpDir+"/in"), sha1sum(tmpDir+"/out"))
 
-			if err := repo.Delete(tC.repoPath); err != nil {
+			if err := repo.Delete(context.Background(), tC.repoPath); err != nil {
 				t.Error(err)
 			}
 		})
@@ -112,36 +158,44 @@ func Test_Stat(t *testing.T) {
 		t.Error(err)
 	}
 
+	testStat(t, repo)
+}
+
+func testStat(t *testing.T, repo Repository) {
+
 	rand.Seed(time.Now().UnixNano())
 	tmpDir := t.TempDir()
 	path := tmpDir + "/bin"
 	repoPath := fmt.Sprintf("stat/%d", rand.Int())
 
 	// stat non-existed file
-	_, err = repo.Stat(repoPath)
+	_, err := repo.Stat(context.Background(), repoPath)
 	assert.Error(t, err, "Stat() should return error if the file does not exist")
 
 	// upload & stat
 	assert.NoError(t, generateRandomFile(path, 1024))
-	err = repo.Upload(path, repoPath, nil)
+	err = repo.Upload(context.Background(), path, repoPath, nil)
 	if err != nil {
 		t.Error(err)
 	}
 
-	info, err := repo.Stat(repoPath)
+	info, err := repo.Stat(context.Background(), repoPath)
 	if err != nil {
 		t.Error(err)
 	}
 	assert.Equal(t, filepath.Base(repoPath), info.Name(), "name of Stat() should be the last component of path")
 	assert.Equal(t, false, info.IsDir(), "result of Stat() should not be a directory ")
+	if size, ok := FileSize(info); ok {
+		assert.Equal(t, int64(1024), size, "size of Stat() should be the size of the file")
+	}
 
 	// delete
-	err = repo.Delete(repoPath)
+	err = repo.Delete(context.Background(), repoPath)
 	if err != nil {
 		t.Error(err)
 	}
 
-	_, err = repo.Stat(repoPath)
+	_, err = repo.Stat(context.Background(), repoPath)
 	assert.Error(t, err, "Stat() should return error after the file deleted")
 }
 
@@ -155,6 +209,11 @@ func Test_List(t *testing.T) {
 		t.Error(err)
 	}
 
+	testList(t, repo)
+}
+
+func testList(t *testing.T, repo Repository) {
+
 	rand.Seed(time.Now().UnixNano())
 	tmpDir := t.TempDir()
 	path := tmpDir + "/bin"
@@ -172,13 +231,13 @@ func Test_List(t *testing.T) {
 	// 	   └── 2
 	for i := 0; i < 3; i++ {
 		rpath := fmt.Sprintf("dir/%d", i)
-		err = repo.Upload(path, rpath, nil)
+		err := repo.Upload(context.Background(), path, rpath, nil)
 		if err != nil {
 			t.Error(err)
 		}
 
 		defer func() {
-			if err := repo.Delete(rpath); err != nil {
+			if err := repo.Delete(context.Background(), rpath); err != nil {
 				log.Debugln("can't delete repo: " + err.Error())
 			}
 		}()
@@ -186,13 +245,13 @@ func Test_List(t *testing.T) {
 	for i := 0; i < 3; i++ {
 		rpath := fmt.Sprintf("dir/3/%d", i)
 
-		err = repo.Upload(path, rpath, nil)
+		err := repo.Upload(context.Background(), path, rpath, nil)
 		if err != nil {
 			t.Error(err)
 		}
 
 		defer func() {
-			if err := repo.Delete(rpath); err != nil {
+			if err := repo.Delete(context.Background(), rpath); err != nil {
 				log.Debugln("can't delete repo: " + err.Error())
 			}
 		}()
@@ -200,7 +259,7 @@ func Test_List(t *testing.T) {
 
 	// test
 	// ls dir
-	list, err := repo.List("dir")
+	list, err := repo.List(context.Background(), "dir")
 	assert.NoError(t, err)
 	assert.Equal(t, 4, len(list))
 	for _, info := range list {
@@ -219,7 +278,7 @@ func Test_List(t *testing.T) {
 	}
 
 	// ls dir/3
-	list, err = repo.List("dir/3")
+	list, err = repo.List(context.Background(), "dir/3")
 	if err != nil {
 		t.Error(err)
 	}
@@ -238,9 +297,51 @@ func Test_List(t *testing.T) {
 	}
 
 	// ls nono-existing folder
-	list, err = repo.List("dir-12345")
+	list, err = repo.List(context.Background(), "dir-12345")
 	if err != nil {
 		t.Error(err)
 	}
 	assert.Equal(t, 0, len(list))
 }
+
+func Test_Copy(t *testing.T) {
+	repo, err := getRepo()
+	if repo == nil {
+		return
+	}
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	testCopy(t, repo)
+}
+
+func testCopy(t *testing.T, repo Repository) {
+
+	rand.Seed(time.Now().UnixNano())
+	tmpDir := t.TempDir()
+	path := tmpDir + "/bin"
+	srcPath := fmt.Sprintf("copy/%d", rand.Int())
+	dstPath := srcPath + "-copied"
+
+	assert.NoError(t, generateRandomFile(path, 1024))
+	err := repo.Upload(context.Background(), path, srcPath, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer repo.Delete(context.Background(), srcPath)
+
+	// copy within the same repository natively
+	err = Copy(context.Background(), repo, srcPath, repo, dstPath)
+	if err == ErrCopyNotSupported {
+		return
+	}
+	assert.NoError(t, err)
+	defer repo.Delete(context.Background(), dstPath)
+
+	copied := tmpDir + "/copied"
+	err = repo.Download(context.Background(), dstPath, copied, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, sha1sum(path), sha1sum(copied))
+}
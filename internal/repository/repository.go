@@ -1,9 +1,14 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	neturl "net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -25,12 +30,159 @@ func (fi *SimpleFileInfo) IsDir() bool {
 	return fi.isDir
 }
 
+// FileSize returns the size of the file if the backend reports it, e.g. the os.FileInfo of the local and ssh
+// backends or the Stat() result of the object storages
+func FileSize(info FileInfo) (int64, bool) {
+	if sized, ok := info.(interface{ Size() int64 }); ok {
+		return sized.Size(), true
+	}
+	return 0, false
+}
+
+// Repository is the storage backend of the artifacts. All the operations should abort and clean up
+// the intermediate state (e.g. temp files or pending uploads) when the context is done.
 type Repository interface {
-	Upload(localPath, repoPath string, meter *Meter) error
-	Download(repoPath, localPath string, meter *Meter) error
-	Delete(repoPath string) error
-	Stat(repoPath string) (FileInfo, error)
-	List(repoPath string) ([]FileInfo, error)
+	Upload(ctx context.Context, localPath, repoPath string, meter *Meter) error
+	Download(ctx context.Context, repoPath, localPath string, meter *Meter) error
+	// UploadStream uploads the content of the reader until EOF. The object is not created if it fails.
+	UploadStream(ctx context.Context, src io.Reader, repoPath string, meter *Meter) error
+	// DownloadStream writes the content of the object to the writer
+	DownloadStream(ctx context.Context, repoPath string, dest io.Writer, meter *Meter) error
+	Delete(ctx context.Context, repoPath string) error
+	Stat(ctx context.Context, repoPath string) (FileInfo, error)
+	List(ctx context.Context, repoPath string) ([]FileInfo, error)
+}
+
+// RangeReader is implemented by the repositories which can read a part of an object natively
+type RangeReader interface {
+	// ReadRange opens the object from the offset. A negative length reads to the end of the object.
+	ReadRange(ctx context.Context, repoPath string, offset, length int64) (io.ReadCloser, error)
+}
+
+// ReadRange reads a part of the object. If the repository cannot read a range natively, the object is
+// downloaded as a stream and the bytes before the offset are discarded. A negative length reads to the end.
+func ReadRange(ctx context.Context, repo Repository, repoPath string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, errors.New("negative offset")
+	}
+
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	if r, ok := repo.(RangeReader); ok {
+		return r.ReadRange(ctx, repoPath, offset, length)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	go func() {
+		err := repo.DownloadStream(ctx, repoPath, &rangeWriter{writer: pw, skip: offset, remaining: length}, nil)
+		if errors.Is(err, errRangeDone) {
+			err = nil
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return &cancelReadCloser{ReadCloser: pr, cancel: cancel}, nil
+}
+
+// Copier is implemented by the repositories which can copy an object from another repository of the same provider
+// without transferring the content through the client. CopyFrom returns ErrCopyNotSupported if the source cannot be
+// copied natively, e.g. the source is of another provider.
+type Copier interface {
+	CopyFrom(ctx context.Context, src Repository, srcPath, repoPath string) error
+}
+
+var ErrCopyNotSupported = errors.New("native copy not supported")
+
+// Copy copies the object from the source repository to the destination natively. It returns ErrCopyNotSupported
+// if the destination cannot copy from the source, and the caller should transfer the object as a stream instead.
+func Copy(ctx context.Context, src Repository, srcPath string, dst Repository, dstPath string) error {
+	if c, ok := dst.(Copier); ok {
+		return c.CopyFrom(ctx, src, srcPath, dstPath)
+	}
+	return ErrCopyNotSupported
+}
+
+// unwrap returns the backend repository under the decorators (e.g. RetryRepository)
+func unwrap(repo Repository) Repository {
+	for {
+		wrapper, ok := repo.(interface{ Unwrap() Repository })
+		if !ok {
+			return repo
+		}
+		repo = wrapper.Unwrap()
+	}
+}
+
+var errRangeDone = errors.New("range done")
+
+// rangeWriter passes the bytes in the range to the writer. It fails with errRangeDone once the range is written
+// to stop the download.
+type rangeWriter struct {
+	writer    io.Writer
+	skip      int64
+	remaining int64
+}
+
+func (w *rangeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if w.skip >= int64(len(p)) {
+		w.skip -= int64(len(p))
+		return n, nil
+	}
+	p = p[w.skip:]
+	w.skip = 0
+
+	done := false
+	if w.remaining >= 0 && int64(len(p)) >= w.remaining {
+		p = p[:w.remaining]
+		done = true
+	}
+
+	written, err := w.writer.Write(p)
+	if w.remaining >= 0 {
+		w.remaining -= int64(written)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if done {
+		return n, errRangeDone
+	}
+	return n, nil
+}
+
+// cancelReadCloser cancels the context of the reading when it is closed
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelReadCloser) Close() error {
+	r.cancel()
+	return r.ReadCloser.Close()
+}
+
+// limitReadCloser reads the first n bytes of the reader. A negative n reads all.
+func limitReadCloser(r io.ReadCloser, n int64) io.ReadCloser {
+	if n < 0 {
+		return r
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(r, n), r}
+}
+
+// httpRange returns the value of the http Range header
+func httpRange(offset, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
 }
 
 type RepoParseResult struct {
@@ -38,6 +190,11 @@ type RepoParseResult struct {
 	scheme string
 	host   string
 	path   string
+	// the query parameters of the repository URL. They are the backend settings, e.g. the endpoint of S3.
+	query neturl.Values
+	// the login user and the port of the ssh repository, e.g. ssh://user@host:2222/path or user@host:path
+	user string
+	port string
 }
 
 func ParseRepo(repo string) (RepoParseResult, error) {
@@ -59,6 +216,15 @@ func ParseRepo(repo string) (RepoParseResult, error) {
 		result.scheme = url.Scheme
 		result.host = url.Host
 		result.path = url.Path
+		result.query = url.Query()
+
+		if url.Scheme == "ssh" {
+			result.host = url.Hostname()
+			result.port = url.Port()
+			if url.User != nil {
+				result.user = url.User.Username()
+			}
+		}
 	} else {
 		i := strings.Index(repo, ":")
 		if i > 0 {
@@ -66,6 +232,11 @@ func ParseRepo(repo string) (RepoParseResult, error) {
 			result.scheme = "ssh"
 			result.host = repo[0:i]
 			result.path = repo[i+1:]
+
+			if j := strings.LastIndex(result.host, "@"); j >= 0 {
+				result.user = result.host[:j]
+				result.host = result.host[j+1:]
+			}
 		} else {
 			cwd, err := os.Getwd()
 			if err != nil {
@@ -88,6 +259,33 @@ func ParseRepo(repo string) (RepoParseResult, error) {
 	return result, nil
 }
 
+// Backend returns the name of the repository backend. It is used as the key of the backend settings.
+func (result RepoParseResult) Backend() string {
+	switch result.scheme {
+	case "http", "https", "azblob":
+		if IsAzureStorageUrl(result.Repo) {
+			return "azureblob"
+		}
+		return "http"
+	case "webdavs":
+		return "webdav"
+	default:
+		return result.scheme
+	}
+}
+
+// DefaultConcurrency returns the default number of concurrent transfers of the backend
+func DefaultConcurrency(backend string) int {
+	switch backend {
+	case "ssh", "rclone":
+		return 4
+	case "s3", "gs", "azureblob":
+		return 16
+	default:
+		return 10
+	}
+}
+
 func ParseRepoName(result RepoParseResult) (string, error) {
 	if result.scheme == "ssh" {
 		name := filepath.Base(result.path)
@@ -114,29 +312,103 @@ func ParseRepoName(result RepoParseResult) (string, error) {
 	}
 }
 
-func NewRepository(result RepoParseResult) (Repository, error) {
+// RepositoryConfig is the backend settings of a repository. The zero value uses the default settings.
+//
+// The settings in the repository URL take precedence over the config, because they are a part of the address of the
+// repository, e.g. the query parameters of s3:// and gs://, the user and the port of ssh://, or the SAS token and the
+// endpoint of the Azure Blob URL. The config applies to the other settings.
+type RepositoryConfig struct {
+	Retry  RetryConfig
+	Http   HttpConfig
+	WebDAV WebDAVConfig
+	S3     S3Config
+	GCS    GCSConfig
+	Azure  AzureBlobConfig
+	SSH    SSHConfig
+}
+
+// NewRepository creates the repository backend. The backend is wrapped with the retry middleware.
+func NewRepository(result RepoParseResult, config RepositoryConfig) (Repository, error) {
+	repo, err := newBackendRepository(result, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRetryRepository(repo, config.Retry), nil
+}
+
+// resolveRepositoryConfig applies the settings in the repository URL to the config
+func resolveRepositoryConfig(result RepoParseResult, config RepositoryConfig) (RepositoryConfig, error) {
+	var err error
+
+	switch result.scheme {
+	case "s3":
+		config.S3, err = ParseS3Params(result.query, config.S3)
+	case "gs":
+		config.GCS, err = ParseGCSParams(result.query, config.GCS)
+	case "ssh":
+		if config.SSH == (SSHConfig{}) {
+			if config.SSH, err = sshEnvConfig(); err != nil {
+				return config, err
+			}
+		}
+		config.SSH, err = ParseSSHParams(result, config.SSH)
+	}
+
+	return config, err
+}
+
+// parseURLParams overrides the string and boolean settings by the query parameters of the repository URL
+func parseURLParams(query neturl.Values, strs map[string]*string, bools map[string]*bool) error {
+	for key, value := range strs {
+		if query.Get(key) != "" {
+			*value = query.Get(key)
+		}
+	}
+
+	for key, value := range bools {
+		if query.Get(key) == "" {
+			continue
+		}
+
+		b, err := strconv.ParseBool(query.Get(key))
+		if err != nil {
+			return fmt.Errorf("invalid boolean of %s: %s", key, query.Get(key))
+		}
+		*value = b
+	}
+
+	return nil
+}
+
+func newBackendRepository(result RepoParseResult, config RepositoryConfig) (Repository, error) {
 	repo := result.Repo
 	host := result.host
 	path := result.path
 
+	config, err := resolveRepositoryConfig(result, config)
+	if err != nil {
+		return nil, err
+	}
+
 	switch result.scheme {
 	case "file":
 		return NewLocalFileSystemRepository(path)
 	case "s3":
-		return NewS3Repository(host, path)
+		return NewS3Repository(host, path, config.S3)
 	case "gs":
-		return NewGCSRepository(host, path)
+		return NewGCSRepository(host, path, config.GCS)
 	case "rclone":
 		return NewRcloneRepository(host, path)
 	case "ssh":
-		return NewSSHRepository(host, path)
-	case "http":
-		return NewHttpRepository(repo)
-	case "https":
+		return NewSSHRepository(host, path, config.SSH)
+	case "webdav", "webdavs":
+		return NewWebDAVRepository(repo, config.WebDAV)
+	case "http", "https", "azblob":
 		if IsAzureStorageUrl(repo) {
-			return NewAzureBlobRepository(repo)
+			return NewAzureBlobRepository(repo, config.Azure)
 		} else {
-			return NewHttpRepository(repo)
+			return NewHttpRepository(repo, config.Http)
 		}
 	default:
 		return nil, UnsupportedRepositoryError{
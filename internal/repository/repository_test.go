@@ -1,11 +1,14 @@
 package repository
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"cloud.google.com/go/storage"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,6 +34,8 @@ func Test(t *testing.T) {
 		host   string
 		path   string
 		name   string
+		user   string
+		port   string
 	}{
 		{repo: "/tmp", scheme: "file", host: "", path: getAbsFilePath("/tmp"), name: "tmp"},
 		{repo: "tmp", scheme: "file", host: "", path: getAbsFilePath("tmp"), name: "tmp"},
@@ -40,6 +45,9 @@ func Test(t *testing.T) {
 		{repo: "host:tmp", scheme: "ssh", host: "host", path: "tmp", name: "tmp"},
 		{repo: "host:../tmp", scheme: "ssh", host: "host", path: "../tmp", name: "tmp"},
 		{repo: "ssh://host/tmp", scheme: "ssh", host: "host", path: "/tmp", name: "tmp"},
+		{repo: "user@host:/tmp", scheme: "ssh", host: "host", path: "/tmp", name: "tmp", user: "user"},
+		{repo: "ssh://user@host:2222/tmp", scheme: "ssh", host: "host", path: "/tmp", name: "tmp", user: "user", port: "2222"},
+		{repo: "ssh://user@host:2222/", scheme: "ssh", host: "host", path: "/", name: "host", user: "user", port: "2222"},
 		{repo: "xyz://host/tmp", scheme: "xyz", host: "host", path: "/tmp", name: "tmp"},
 		{repo: "xyz://host", scheme: "xyz", host: "host", path: "", name: "host"},
 	}
@@ -55,6 +63,8 @@ func Test(t *testing.T) {
 			assert.Equal(t, tC.scheme, result.scheme)
 			assert.Equal(t, tC.host, result.host)
 			assert.Equal(t, tC.path, result.path)
+			assert.Equal(t, tC.user, result.user)
+			assert.Equal(t, tC.port, result.port)
 
 			repoName, err := ParseRepoName(result)
 			if err != nil {
@@ -66,3 +76,127 @@ func Test(t *testing.T) {
 		})
 	}
 }
+
+// statusCodeError is an error with the http status code, as the response errors of the AWS SDK
+type statusCodeError int
+
+func (err statusCodeError) Error() string {
+	return fmt.Sprintf("status code %d", int(err))
+}
+
+func (err statusCodeError) HTTPStatusCode() int {
+	return int(err)
+}
+
+func TestIsNotExist(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		err      error
+		expected bool
+	}{
+		{desc: "nil", err: nil, expected: false},
+		{desc: "os", err: fmt.Errorf("open: %w", os.ErrNotExist), expected: true},
+		{desc: "http 404", err: HttpStatusError{StatusCode: 404}, expected: true},
+		{desc: "gcs", err: storage.ErrObjectNotExist, expected: true},
+		{desc: "s3 404", err: fmt.Errorf("get object: %w", statusCodeError(404)), expected: true},
+		{desc: "s3 403", err: fmt.Errorf("get object: %w", statusCodeError(403)), expected: false},
+		{desc: "http 401", err: HttpStatusError{StatusCode: 401}, expected: false},
+		{desc: "permission", err: os.ErrPermission, expected: false},
+		{desc: "canceled", err: context.Canceled, expected: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			assert.Equal(t, tC.expected, IsNotExist(tC.err))
+		})
+	}
+}
+
+func TestResolveRepositoryConfig(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		repo     string
+		config   RepositoryConfig
+		env      map[string]string
+		expected RepositoryConfig
+		err      bool
+	}{
+		{desc: "no params", repo: "s3://bucket/repo", config: RepositoryConfig{S3: S3Config{Region: "eu-west-1"}}, expected: RepositoryConfig{S3: S3Config{Region: "eu-west-1"}}},
+		{
+			desc:     "s3 params",
+			repo:     "s3://bucket/repo?endpoint=http://localhost:9000&region=eu-west-1&path-style=true&profile=minio&anonymous=1",
+			expected: RepositoryConfig{S3: S3Config{Endpoint: "http://localhost:9000", Region: "eu-west-1", PathStyle: true, Profile: "minio", Anonymous: true}},
+		},
+		{
+			desc:     "s3 params take precedence",
+			repo:     "s3://bucket/repo?endpoint=http://localhost:9000&path-style=false",
+			config:   RepositoryConfig{S3: S3Config{Endpoint: "https://ceph.example.com", Region: "eu-west-1", PathStyle: true}},
+			expected: RepositoryConfig{S3: S3Config{Endpoint: "http://localhost:9000", Region: "eu-west-1"}},
+		},
+		{desc: "s3 invalid boolean", repo: "s3://bucket/repo?path-style=yes", err: true},
+		{
+			desc:     "gcs params",
+			repo:     "gs://bucket/repo?endpoint=http://localhost:4443&user-project=billing&credentials-file=/tmp/sa.json&predefined-acl=private&anonymous=true",
+			expected: RepositoryConfig{GCS: GCSConfig{Endpoint: "http://localhost:4443", UserProject: "billing", CredentialsFile: "/tmp/sa.json", PredefinedACL: "private", Anonymous: true}},
+		},
+		{
+			desc:     "gcs params take precedence",
+			repo:     "gs://bucket/repo?user-project=billing",
+			config:   RepositoryConfig{GCS: GCSConfig{Endpoint: "http://localhost:4443", UserProject: "other"}},
+			expected: RepositoryConfig{GCS: GCSConfig{Endpoint: "http://localhost:4443", UserProject: "billing"}},
+		},
+		{desc: "gcs invalid boolean", repo: "gs://bucket/repo?anonymous=yes!", err: true},
+		{
+			desc:     "gcs endpoint param with the credentials file",
+			repo:     "gs://bucket/repo?endpoint=http://localhost:4443",
+			config:   RepositoryConfig{GCS: GCSConfig{CredentialsFile: "/tmp/sa.json"}},
+			expected: RepositoryConfig{GCS: GCSConfig{Endpoint: "http://localhost:4443", CredentialsFile: "/tmp/sa.json"}},
+		},
+		{desc: "gcs endpoint param with the default credentials", repo: "gs://bucket/repo?endpoint=https://example.com", err: true},
+		{
+			desc:   "gcs endpoint param with the credentials file param",
+			repo:   "gs://bucket/repo?endpoint=https://example.com&credentials-file=/tmp/sa.json",
+			config: RepositoryConfig{GCS: GCSConfig{CredentialsFile: "/tmp/other.json"}},
+			err:    true,
+		},
+		{
+			desc:     "ssh user and port take precedence",
+			repo:     "ssh://avc@host:2222/repo",
+			config:   RepositoryConfig{SSH: SSHConfig{User: "other", Port: 22, Password: "secret"}},
+			expected: RepositoryConfig{SSH: SSHConfig{User: "avc", Port: 2222, Password: "secret"}},
+		},
+		{
+			desc:     "ssh environment variables without the ssh settings",
+			repo:     "avc@host:repo",
+			env:      map[string]string{"SSH_USER": "other", "SSH_PORT": "2222", "SSH_PASSWORD": "secret"},
+			expected: RepositoryConfig{SSH: SSHConfig{User: "avc", Port: 2222, Password: "secret"}},
+		},
+		{
+			desc:     "ssh environment variables with the ssh settings",
+			repo:     "host:repo",
+			config:   RepositoryConfig{SSH: SSHConfig{IdentityFile: "~/.ssh/id_avc"}},
+			env:      map[string]string{"SSH_USER": "other", "SSH_PASSWORD": "secret"},
+			expected: RepositoryConfig{SSH: SSHConfig{IdentityFile: "~/.ssh/id_avc"}},
+		},
+		{desc: "ssh invalid port", repo: "host:repo", env: map[string]string{"SSH_PORT": "ssh"}, err: true},
+		{desc: "params of other backends", repo: "s3://bucket/repo?user-project=billing", expected: RepositoryConfig{}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			setSSHTestEnv(t)
+			for name, value := range tC.env {
+				t.Setenv(name, value)
+			}
+
+			result, err := ParseRepo(tC.repo)
+			assert.NoError(t, err)
+
+			config, err := resolveRepositoryConfig(result, tC.config)
+			if tC.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tC.expected, config)
+		})
+	}
+}
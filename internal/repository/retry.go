@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/infuseai/artivc/internal/log"
+)
+
+const (
+	DefaultRetryMaxAttempts     = 5
+	DefaultRetryInitialInterval = 500 * time.Millisecond
+	DefaultRetryMaxInterval     = 30 * time.Second
+)
+
+type RetryConfig struct {
+	// The max number of attempts of an operation. 1 disables the retry.
+	MaxAttempts int
+	// The backoff interval before the first retry. It is doubled for each further retry.
+	InitialInterval time.Duration
+	// The upper bound of the backoff interval
+	MaxInterval time.Duration
+}
+
+// retryableErrorChecker is implemented by the backends which know their transient errors
+type retryableErrorChecker interface {
+	IsRetryableError(err error) bool
+}
+
+// RetryRepository is a repository decorator which retries the idempotent operations with exponential backoff and jitter.
+// Delete is not retried because a retry after a lost response would fail with a not-found error.
+type RetryRepository struct {
+	repo    Repository
+	checker func(err error) bool
+	config  RetryConfig
+}
+
+func NewRetryRepository(repo Repository, config RetryConfig) *RetryRepository {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultRetryMaxAttempts
+	}
+
+	if config.InitialInterval <= 0 {
+		config.InitialInterval = DefaultRetryInitialInterval
+	}
+
+	if config.MaxInterval <= 0 {
+		config.MaxInterval = DefaultRetryMaxInterval
+	}
+
+	checker := isRetryableError
+	if c, ok := repo.(retryableErrorChecker); ok {
+		checker = c.IsRetryableError
+	}
+
+	return &RetryRepository{
+		repo:    repo,
+		checker: checker,
+		config:  config,
+	}
+}
+
+// Unwrap returns the underlying repository
+func (repo *RetryRepository) Unwrap() Repository {
+	return repo.repo
+}
+
+func (repo *RetryRepository) Upload(ctx context.Context, localPath, repoPath string, meter *Meter) error {
+	return repo.retryTransfer(ctx, "upload "+repoPath, meter, func() error {
+		return repo.repo.Upload(ctx, localPath, repoPath, meter)
+	})
+}
+
+func (repo *RetryRepository) Download(ctx context.Context, repoPath, localPath string, meter *Meter) error {
+	return repo.retryTransfer(ctx, "download "+repoPath, meter, func() error {
+		return repo.repo.Download(ctx, repoPath, localPath, meter)
+	})
+}
+
+// UploadStream retries only if the reader is seekable. The reader is rewound to the starting position before
+// each retry.
+func (repo *RetryRepository) UploadStream(ctx context.Context, src io.Reader, repoPath string, meter *Meter) error {
+	seeker, ok := src.(io.Seeker)
+	if !ok {
+		return repo.repo.UploadStream(ctx, src, repoPath, meter)
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return repo.repo.UploadStream(ctx, src, repoPath, meter)
+	}
+
+	attempt := 0
+	return repo.retryTransfer(ctx, "upload "+repoPath, meter, func() error {
+		if attempt > 0 {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		attempt++
+		return repo.repo.UploadStream(ctx, src, repoPath, meter)
+	})
+}
+
+// DownloadStream retries only if nothing has been written to the writer
+func (repo *RetryRepository) DownloadStream(ctx context.Context, repoPath string, dest io.Writer, meter *Meter) error {
+	counter := &countingWriter{writer: dest}
+	return repo.retryTransfer(ctx, "download "+repoPath, meter, func() error {
+		err := repo.repo.DownloadStream(ctx, repoPath, counter, meter)
+		if err != nil && counter.written > 0 {
+			return permanentError{err}
+		}
+		return err
+	})
+}
+
+// ReadRange retries to open the range. The reading afterward is not retried.
+func (repo *RetryRepository) ReadRange(ctx context.Context, repoPath string, offset, length int64) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	err := repo.retry(ctx, "read "+repoPath, func() error {
+		var err error
+		reader, err = ReadRange(ctx, repo.repo, repoPath, offset, length)
+		return err
+	})
+	return reader, err
+}
+
+// CopyFrom copies natively if the underlying repository is a Copier
+func (repo *RetryRepository) CopyFrom(ctx context.Context, src Repository, srcPath, repoPath string) error {
+	copier, ok := repo.repo.(Copier)
+	if !ok {
+		return ErrCopyNotSupported
+	}
+
+	return repo.retry(ctx, "copy "+repoPath, func() error {
+		err := copier.CopyFrom(ctx, src, srcPath, repoPath)
+		if errors.Is(err, ErrCopyNotSupported) {
+			return permanentError{err}
+		}
+		return err
+	})
+}
+
+func (repo *RetryRepository) Delete(ctx context.Context, repoPath string) error {
+	return repo.repo.Delete(ctx, repoPath)
+}
+
+func (repo *RetryRepository) Stat(ctx context.Context, repoPath string) (FileInfo, error) {
+	var info FileInfo
+	err := repo.retry(ctx, "stat "+repoPath, func() error {
+		var err error
+		info, err = repo.repo.Stat(ctx, repoPath)
+		return err
+	})
+	return info, err
+}
+
+func (repo *RetryRepository) List(ctx context.Context, repoPath string) ([]FileInfo, error) {
+	var entries []FileInfo
+	err := repo.retry(ctx, "list "+repoPath, func() error {
+		var err error
+		entries, err = repo.repo.List(ctx, repoPath)
+		return err
+	})
+	return entries, err
+}
+
+// retryTransfer retries the transfer and rolls back the meter to discard the bytes of the failed attempts
+func (repo *RetryRepository) retryTransfer(ctx context.Context, op string, meter *Meter, f func() error) error {
+	var start int64
+	if meter != nil {
+		start = atomic.LoadInt64(&meter.total)
+	}
+
+	attempt := 0
+	return repo.retry(ctx, op, func() error {
+		if attempt > 0 && meter != nil {
+			meter.SetBytes(start)
+		}
+		attempt++
+		return f()
+	})
+}
+
+func (repo *RetryRepository) retry(ctx context.Context, op string, f func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = f()
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if err == nil || attempt >= repo.config.MaxAttempts || ctx.Err() != nil || !repo.checker(err) {
+			return err
+		}
+
+		backoff := repo.backoff(attempt)
+		log.Debugf("%s failed (attempt %d/%d), retry in %v: %s\n", op, attempt, repo.config.MaxAttempts, backoff, err.Error())
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// permanentError stops the retry and returns the wrapped error
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+// countingWriter counts the bytes written to the writer
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// backoff returns the full jitter backoff interval before the next attempt
+func (repo *RetryRepository) backoff(attempt int) time.Duration {
+	interval := repo.config.InitialInterval
+	for i := 1; i < attempt && interval < repo.config.MaxInterval; i++ {
+		interval *= 2
+	}
+
+	if interval > repo.config.MaxInterval {
+		interval = repo.config.MaxInterval
+	}
+
+	return time.Duration(rand.Int63n(int64(interval)) + 1)
+}
+
+// isRetryableError is the default checker for the transient errors. It covers the network errors shared by all
+// the backends. The backend specific errors are checked by the backend itself.
+func isRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	msg := err.Error()
+	return strings.HasSuffix(msg, "connection reset by peer") || strings.HasSuffix(msg, "broken pipe")
+}
+
+// isRetryableStatusCode checks if the http status code is a transient error
+func isRetryableStatusCode(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
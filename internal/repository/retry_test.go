@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errTransient = errors.New("transient")
+	errFatal     = errors.New("fatal")
+)
+
+// flakyRepository fails the first n operations with the given error
+type flakyRepository struct {
+	LocalFileSystemRepository
+	failures int
+	err      error
+	attempts int
+	// write the partial data before failing
+	partial bool
+}
+
+func (repo *flakyRepository) Stat(ctx context.Context, repoPath string) (FileInfo, error) {
+	repo.attempts++
+	if repo.attempts <= repo.failures {
+		return nil, repo.err
+	}
+	return &SimpleFileInfo{name: repoPath}, nil
+}
+
+func (repo *flakyRepository) Upload(ctx context.Context, localPath, repoPath string, meter *Meter) error {
+	repo.attempts++
+	meter.AddBytes(100)
+	if repo.attempts <= repo.failures {
+		return repo.err
+	}
+	return nil
+}
+
+func (repo *flakyRepository) DownloadStream(ctx context.Context, repoPath string, dest io.Writer, meter *Meter) error {
+	repo.attempts++
+	if repo.attempts <= repo.failures {
+		if repo.partial {
+			dest.Write([]byte("partial"))
+		}
+		return repo.err
+	}
+	_, err := dest.Write([]byte("data"))
+	return err
+}
+
+func (repo *flakyRepository) IsRetryableError(err error) bool {
+	return err == errTransient
+}
+
+func newTestRetryRepository(repo Repository) *RetryRepository {
+	return NewRetryRepository(repo, RetryConfig{
+		MaxAttempts:     3,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     time.Millisecond,
+	})
+}
+
+func TestRetry(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		failures int
+		err      error
+		attempts int
+		success  bool
+	}{
+		{desc: "no error", failures: 0, err: errTransient, attempts: 1, success: true},
+		{desc: "transient error", failures: 2, err: errTransient, attempts: 3, success: true},
+		{desc: "too many transient errors", failures: 3, err: errTransient, attempts: 3, success: false},
+		{desc: "fatal error", failures: 1, err: errFatal, attempts: 1, success: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			flaky := &flakyRepository{failures: tC.failures, err: tC.err}
+			repo := newTestRetryRepository(flaky)
+
+			_, err := repo.Stat(context.Background(), "path")
+			assert.Equal(t, tC.attempts, flaky.attempts)
+			if tC.success {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tC.err)
+			}
+		})
+	}
+}
+
+func TestRetryMeter(t *testing.T) {
+	flaky := &flakyRepository{failures: 2, err: errTransient}
+	repo := newTestRetryRepository(flaky)
+	session := NewSession()
+	meter := session.NewMeter()
+
+	assert.NoError(t, repo.Upload(context.Background(), "local", "path", meter))
+	assert.Equal(t, int64(100), meter.total, "the bytes of the failed attempts should be discarded")
+}
+
+func TestRetryCanceled(t *testing.T) {
+	flaky := &flakyRepository{failures: 5, err: errTransient}
+	repo := NewRetryRepository(flaky, RetryConfig{
+		MaxAttempts:     5,
+		InitialInterval: time.Hour,
+		MaxInterval:     time.Hour,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := repo.Stat(ctx, "path")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, flaky.attempts)
+}
+
+func TestIsRetryableStatusCode(t *testing.T) {
+	assert.True(t, isRetryableStatusCode(429))
+	assert.True(t, isRetryableStatusCode(503))
+	assert.False(t, isRetryableStatusCode(403))
+	assert.False(t, isRetryableStatusCode(404))
+
+	repo := &HttpRepository{}
+	assert.True(t, repo.IsRetryableError(HttpStatusError{StatusCode: 503}))
+	assert.False(t, repo.IsRetryableError(HttpStatusError{StatusCode: 403}))
+}
+
+func TestRetryDownloadStream(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		partial  bool
+		attempts int
+		data     string
+		success  bool
+	}{
+		{desc: "failed before writing", partial: false, attempts: 2, data: "data", success: true},
+		{desc: "failed after writing", partial: true, attempts: 1, data: "partial", success: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			flaky := &flakyRepository{failures: 1, err: errTransient, partial: tC.partial}
+			repo := newTestRetryRepository(flaky)
+
+			var buf bytes.Buffer
+			err := repo.DownloadStream(context.Background(), "path", &buf, nil)
+			assert.Equal(t, tC.attempts, flaky.attempts)
+			assert.Equal(t, tC.data, buf.String())
+			if tC.success {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, errTransient)
+			}
+		})
+	}
+}
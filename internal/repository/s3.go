@@ -2,40 +2,165 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	neturl "net/url"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// S3Config is the settings of the S3 repository. The settings are applied to the repository only, so the S3 compatible
+// services (e.g. MinIO, Ceph or Cloudflare R2) can be used without changing the global AWS settings.
+type S3Config struct {
+	// The endpoint URL of the S3 compatible service. The AWS endpoint is used if it is empty.
+	Endpoint string
+	// The region of the bucket. It falls back to "us-east-1" if the endpoint is set and no region is configured.
+	Region string
+	// Use the path-style addressing (e.g. http://host/bucket/key) instead of the virtual-hosted-style
+	PathStyle bool
+	// The profile of the shared config and credentials files
+	Profile string
+	// Send the requests without the credentials, e.g. to read a public bucket
+	Anonymous bool
+
+	// The storage class of the blobs (e.g. "STANDARD_IA" or "INTELLIGENT_TIERING")
+	StorageClass string
+	// The storage class of the metadata (e.g. the commits and the references). They are read by every operation, so
+	// the blob storage class is not applied to them.
+	MetadataStorageClass string
+	// The server-side encryption, "AES256" or "aws:kms"
+	SSE string
+	// The KMS key id of the "aws:kms" encryption. The default key of the account is used if it is empty.
+	SSEKMSKeyID string
+	// The canned ACL of the objects, e.g. "bucket-owner-full-control"
+	ACL string
+	// The tags of the objects in the URL query format, e.g. "team=ml&project=avc"
+	Tags string
+}
+
+// validate checks the object settings. The KMS key id implies the "aws:kms" encryption.
+func (config *S3Config) validate() error {
+	if config.SSEKMSKeyID != "" && config.SSE == "" {
+		config.SSE = string(types.ServerSideEncryptionAwsKms)
+	}
+
+	switch types.ServerSideEncryption(config.SSE) {
+	case "", types.ServerSideEncryptionAes256:
+		if config.SSEKMSKeyID != "" {
+			return fmt.Errorf("the KMS key id requires the %s encryption", types.ServerSideEncryptionAwsKms)
+		}
+	case types.ServerSideEncryptionAwsKms:
+	default:
+		return fmt.Errorf("invalid server-side encryption: %s", config.SSE)
+	}
+
+	for _, storageClass := range []string{config.StorageClass, config.MetadataStorageClass} {
+		if storageClass != "" && !isS3StorageClass(storageClass) {
+			return fmt.Errorf("invalid storage class: %s", storageClass)
+		}
+	}
+
+	if config.ACL != "" && !isS3CannedACL(config.ACL) {
+		return fmt.Errorf("invalid ACL: %s", config.ACL)
+	}
+
+	if _, err := neturl.ParseQuery(config.Tags); err != nil {
+		return fmt.Errorf("invalid tags: %s", config.Tags)
+	}
+
+	return nil
+}
+
+func isS3StorageClass(s string) bool {
+	for _, value := range types.StorageClass("").Values() {
+		if string(value) == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isS3CannedACL(s string) bool {
+	for _, value := range types.ObjectCannedACL("").Values() {
+		if string(value) == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseS3Params reads the settings from the query parameters of the repository URL, e.g.
+// "s3://bucket/path?endpoint=http://localhost:9000&path-style=true".
+func ParseS3Params(query neturl.Values, config S3Config) (S3Config, error) {
+	err := parseURLParams(query, map[string]*string{
+		"endpoint": &config.Endpoint,
+		"region":   &config.Region,
+		"profile":  &config.Profile,
+	}, map[string]*bool{
+		"path-style": &config.PathStyle,
+		"anonymous":  &config.Anonymous,
+	})
+	return config, err
+}
+
 type S3Repository struct {
 	Bucket   string
 	BasePath string
 	client   *s3.Client
+	config   S3Config
 }
 
-func NewS3Repository(bucket, basePath string) (*S3Repository, error) {
+func NewS3Repository(bucket, basePath string, s3Config S3Config) (*S3Repository, error) {
 	basePath = strings.TrimPrefix(basePath, "/")
 
-	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err := s3Config.validate(); err != nil {
+		return nil, err
+	}
+
+	optFns := []func(*config.LoadOptions) error{}
+	if s3Config.Region != "" {
+		optFns = append(optFns, config.WithRegion(s3Config.Region))
+	}
+	if s3Config.Profile != "" {
+		optFns = append(optFns, config.WithSharedConfigProfile(s3Config.Profile))
+	}
+	if s3Config.Anonymous {
+		optFns = append(optFns, config.WithCredentialsProvider(aws.AnonymousCredentials{}))
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO(), optFns...)
 	if err != nil {
 		return nil, err
 	}
-	client := s3.NewFromConfig(cfg)
+
+	if s3Config.Endpoint != "" && cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if s3Config.Endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(s3Config.Endpoint)
+		}
+		o.UsePathStyle = s3Config.PathStyle
+	})
 
 	return &S3Repository{
 		Bucket:   bucket,
 		BasePath: basePath,
 		client:   client,
+		config:   s3Config,
 	}, nil
 }
 
-func (repo *S3Repository) Upload(localPath, repoPath string, m *Meter) error {
+func (repo *S3Repository) Upload(ctx context.Context, localPath, repoPath string, m *Meter) error {
 	// Reference the code to show the progress when uploading
 	// https://github.com/aws/aws-sdk-go/blob/main/example/service/s3/putObjectWithProcess/putObjWithProcess.go
 	sourceFileStat, err := os.Stat(localPath)
@@ -59,6 +184,7 @@ func (repo *S3Repository) Upload(localPath, repoPath string, m *Meter) error {
 	}
 
 	reader := &progressReader{
+		ctx:   ctx,
 		fp:    source,
 		size:  fileInfo.Size(),
 		meter: m,
@@ -70,17 +196,19 @@ func (repo *S3Repository) Upload(localPath, repoPath string, m *Meter) error {
 		Key:    &key,
 		Body:   reader,
 	}
+	repo.applyPutOptions(input, repoPath)
 
 	if sourceFileStat.Size() < manager.DefaultUploadPartSize {
-		_, err = repo.client.PutObject(context.TODO(), input)
+		_, err = repo.client.PutObject(ctx, input)
 	} else {
+		// the uploader aborts the multipart upload if it fails or the context is canceled
 		uploader := manager.NewUploader(repo.client)
-		_, err = uploader.Upload(context.TODO(), input)
+		_, err = uploader.Upload(ctx, input)
 	}
 	return err
 }
 
-func (repo *S3Repository) Download(repoPath, localPath string, m *Meter) error {
+func (repo *S3Repository) Download(ctx context.Context, repoPath, localPath string, m *Meter) error {
 	// Reference the code to show the progress when downloading
 	// https://github.com/aws/aws-sdk-go/tree/main/example/service/s3/getObjectWithProgress
 	key := filepath.Join(repo.BasePath, repoPath)
@@ -98,39 +226,161 @@ func (repo *S3Repository) Download(repoPath, localPath string, m *Meter) error {
 	}
 	defer dest.Close()
 
-	writer := &progressWriter{writer: dest, meter: m}
-	_, err = downloader.Download(context.TODO(), writer, input)
+	writer := &progressWriter{ctx: ctx, writer: dest, meter: m}
+	_, err = downloader.Download(ctx, writer, input)
+	return err
+}
+
+func (repo *S3Repository) UploadStream(ctx context.Context, src io.Reader, repoPath string, m *Meter) error {
+	key := filepath.Join(repo.BasePath, repoPath)
+	input := &s3.PutObjectInput{
+		Bucket: &repo.Bucket,
+		Key:    &key,
+		Body:   NewMeterReader(ctx, src, m),
+	}
+	repo.applyPutOptions(input, repoPath)
+
+	// the uploader buffers the parts of the unseekable body and puts the object directly if it fits in a part
+	uploader := manager.NewUploader(repo.client)
+	_, err := uploader.Upload(ctx, input)
+	return err
+}
+
+func (repo *S3Repository) DownloadStream(ctx context.Context, repoPath string, dest io.Writer, m *Meter) error {
+	key := filepath.Join(repo.BasePath, repoPath)
+	input := &s3.GetObjectInput{
+		Bucket: &repo.Bucket,
+		Key:    &key,
+	}
+
+	output, err := repo.client.GetObject(ctx, input)
+	if err != nil {
+		return err
+	}
+	defer output.Body.Close()
+
+	_, err = CopyWithMeter(ctx, dest, output.Body, m)
+	return err
+}
+
+func (repo *S3Repository) ReadRange(ctx context.Context, repoPath string, offset, length int64) (io.ReadCloser, error) {
+	key := filepath.Join(repo.BasePath, repoPath)
+	byteRange := httpRange(offset, length)
+	input := &s3.GetObjectInput{
+		Bucket: &repo.Bucket,
+		Key:    &key,
+		Range:  &byteRange,
+	}
+
+	output, err := repo.client.GetObject(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Body, nil
+}
+
+// storageClass returns the storage class of the object. The objects under "objects/" are the blobs, and the others
+// (e.g. the commits and the references) are the metadata.
+func (repo *S3Repository) storageClass(repoPath string) types.StorageClass {
+	if strings.HasPrefix(repoPath, "objects/") {
+		return types.StorageClass(repo.config.StorageClass)
+	}
+	return types.StorageClass(repo.config.MetadataStorageClass)
+}
+
+// applyPutOptions sets the storage class, encryption, ACL and tags of the object to put. The multipart uploads take
+// them from the input as well.
+func (repo *S3Repository) applyPutOptions(input *s3.PutObjectInput, repoPath string) {
+	input.StorageClass = repo.storageClass(repoPath)
+	input.ServerSideEncryption = types.ServerSideEncryption(repo.config.SSE)
+	input.ACL = types.ObjectCannedACL(repo.config.ACL)
+	if repo.config.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = &repo.config.SSEKMSKeyID
+	}
+	if repo.config.Tags != "" {
+		input.Tagging = &repo.config.Tags
+	}
+}
+
+// applyCopyOptions sets the options of the destination object as applyPutOptions. The tags of the source are
+// replaced.
+func (repo *S3Repository) applyCopyOptions(input *s3.CopyObjectInput, repoPath string) {
+	input.StorageClass = repo.storageClass(repoPath)
+	input.ServerSideEncryption = types.ServerSideEncryption(repo.config.SSE)
+	input.ACL = types.ObjectCannedACL(repo.config.ACL)
+	if repo.config.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = &repo.config.SSEKMSKeyID
+	}
+	input.TaggingDirective = types.TaggingDirectiveReplace
+	input.Tagging = &repo.config.Tags
+}
+
+// s3MaxCopySize is the max size of an object copied by a single CopyObject request
+const s3MaxCopySize = 5 * 1024 * 1024 * 1024
+
+// CopyFrom copies the object of another S3 repository by CopyObject, so the content stays in S3. Only the source of
+// the same endpoint and credentials is supported, and the objects larger than 5 GiB are not supported.
+func (repo *S3Repository) CopyFrom(ctx context.Context, src Repository, srcPath, repoPath string) error {
+	srcRepo, ok := unwrap(src).(*S3Repository)
+	if !ok || srcRepo.config.Endpoint != repo.config.Endpoint || srcRepo.config.Profile != repo.config.Profile || srcRepo.config.Anonymous != repo.config.Anonymous {
+		return ErrCopyNotSupported
+	}
+
+	srcKey := filepath.Join(srcRepo.BasePath, srcPath)
+	head, err := srcRepo.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &srcRepo.Bucket,
+		Key:    &srcKey,
+	})
+	if err != nil {
+		return err
+	}
+	if head.ContentLength > s3MaxCopySize {
+		return ErrCopyNotSupported
+	}
+
+	key := filepath.Join(repo.BasePath, repoPath)
+	copySource := neturl.PathEscape(srcRepo.Bucket + "/" + srcKey)
+	input := &s3.CopyObjectInput{
+		Bucket:     &repo.Bucket,
+		Key:        &key,
+		CopySource: &copySource,
+	}
+	repo.applyCopyOptions(input, repoPath)
+
+	_, err = repo.client.CopyObject(ctx, input)
 	return err
 }
 
-func (repo *S3Repository) Delete(repoPath string) error {
+func (repo *S3Repository) Delete(ctx context.Context, repoPath string) error {
 	key := filepath.Join(repo.BasePath, repoPath)
 	input := &s3.DeleteObjectInput{
 		Bucket: &repo.Bucket,
 		Key:    &key,
 	}
 
-	_, err := repo.client.DeleteObject(context.TODO(), input)
+	_, err := repo.client.DeleteObject(ctx, input)
 	return err
 }
 
-func (repo *S3Repository) Stat(repoPath string) (FileInfo, error) {
+func (repo *S3Repository) Stat(ctx context.Context, repoPath string) (FileInfo, error) {
 	key := filepath.Join(repo.BasePath, repoPath)
 	input := &s3.HeadObjectInput{
 		Bucket: &repo.Bucket,
 		Key:    &key,
 	}
-	_, err := repo.client.HeadObject(context.TODO(), input)
+	output, err := repo.client.HeadObject(ctx, input)
 	if err != nil {
 		return nil, err
 	}
 
 	return &S3FileInfo{
 		name: filepath.Base(repoPath),
+		size: output.ContentLength,
 	}, nil
 }
 
-func (repo *S3Repository) List(repoPath string) ([]FileInfo, error) {
+func (repo *S3Repository) List(ctx context.Context, repoPath string) ([]FileInfo, error) {
 	fullRepoPath := filepath.Join(repo.BasePath, repoPath)
 	fullRepoPath = fullRepoPath + "/"
 	delimeter := "/"
@@ -139,30 +389,36 @@ func (repo *S3Repository) List(repoPath string) ([]FileInfo, error) {
 		Prefix:    &fullRepoPath,
 		Delimiter: &delimeter,
 	}
-	output, err := repo.client.ListObjectsV2(context.TODO(), input)
-	if err != nil {
-		return nil, err
-	}
 
+	// a page has at most 1000 keys
 	entries := make([]FileInfo, 0)
-	for _, prefix := range output.CommonPrefixes {
-		fullname := *prefix.Prefix
-		name := fullname[len(fullRepoPath) : len(fullname)-1]
-		entry := S3FileInfo{name: name, isDir: true}
-		entries = append(entries, &entry)
-	}
-
-	for _, obj := range output.Contents {
-		fullname := *obj.Key
-		entry := S3FileInfo{name: fullname[len(fullRepoPath):]}
-		entries = append(entries, &entry)
+	paginator := s3.NewListObjectsV2Paginator(repo.client, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, prefix := range output.CommonPrefixes {
+			fullname := *prefix.Prefix
+			name := fullname[len(fullRepoPath) : len(fullname)-1]
+			entry := S3FileInfo{name: name, isDir: true}
+			entries = append(entries, &entry)
+		}
+
+		for _, obj := range output.Contents {
+			fullname := *obj.Key
+			entry := S3FileInfo{name: fullname[len(fullRepoPath):], size: obj.Size}
+			entries = append(entries, &entry)
+		}
 	}
-	return entries, err
+	return entries, nil
 }
 
 type S3FileInfo struct {
 	name  string
 	isDir bool
+	size  int64
 }
 
 func (fi *S3FileInfo) Name() string {
@@ -173,7 +429,12 @@ func (fi *S3FileInfo) IsDir() bool {
 	return fi.isDir
 }
 
+func (fi *S3FileInfo) Size() int64 {
+	return fi.size
+}
+
 type progressReader struct {
+	ctx   context.Context
 	fp    *os.File
 	size  int64
 	meter *Meter
@@ -183,6 +444,9 @@ func (r *progressReader) Read(p []byte) (int, error) {
 	read, err := r.fp.Read(p)
 	if r.meter != nil {
 		r.meter.AddBytes(read)
+		if waitErr := r.meter.Wait(r.ctx, read); waitErr != nil {
+			return read, waitErr
+		}
 	}
 	return read, err
 }
@@ -195,6 +459,7 @@ func (r *progressReader) ReadAt(p []byte, off int64) (int, error) {
 
 	if r.meter != nil {
 		r.meter.AddBytes(n)
+		err = r.meter.Wait(r.ctx, n)
 	}
 
 	return n, err
@@ -205,6 +470,7 @@ func (r *progressReader) Seek(offset int64, whence int) (int64, error) {
 }
 
 type progressWriter struct {
+	ctx    context.Context
 	writer io.WriterAt
 	meter  *Meter
 }
@@ -217,7 +483,26 @@ func (w *progressWriter) WriteAt(p []byte, off int64) (int, error) {
 
 	if w.meter != nil {
 		w.meter.AddBytes(n)
+		err = w.meter.Wait(w.ctx, n)
 	}
 
 	return n, err
 }
+
+// IsRetryableError checks the throttling and server errors of S3. The access denied (403) errors are fatal.
+func (repo *S3Repository) IsRetryableError(err error) bool {
+	var apiErr interface{ ErrorCode() string }
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "SlowDown", "Throttling", "ThrottlingException", "RequestLimitExceeded", "RequestTimeout", "InternalError", "ServiceUnavailable":
+			return true
+		}
+	}
+
+	var respErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &respErr) {
+		return isRetryableStatusCode(respErr.HTTPStatusCode())
+	}
+
+	return isRetryableError(err)
+}
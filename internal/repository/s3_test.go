@@ -0,0 +1,390 @@
+package repository
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// s3TestServer is a minimal S3 compatible server (as MinIO) with the path-style addressing. It keeps the objects in
+// memory and supports the operations used by the S3 repository.
+type s3TestServer struct {
+	mtx     sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	// the headers of the requests creating the objects
+	headers map[string]http.Header
+	// the access key ids of the requests. It is empty for the anonymous requests.
+	accessKeys []string
+}
+
+func newS3TestServer(t *testing.T) (*httptest.Server, *s3TestServer) {
+	server := &s3TestServer{objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}, headers: map[string]http.Header{}}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return ts, server
+}
+
+func (s *s3TestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	accessKey := ""
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		accessKey = strings.SplitN(strings.SplitN(auth, "Credential=", 2)[1], "/", 2)[0]
+	}
+	s.accessKeys = append(s.accessKeys, accessKey)
+
+	// /<bucket>/<key>
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucket, key := parts[0], ""
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+	name := bucket + "/" + key
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		s.list(w, bucket, query)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := strconv.Itoa(len(s.uploads) + 1)
+		s.uploads[id] = map[int][]byte{}
+		s.headers[name] = r.Header
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucket, Key: key, UploadId: id})
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		data, _ := io.ReadAll(r.Body)
+		s.uploads[query.Get("uploadId")][number] = data
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, number))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts := s.uploads[query.Get("uploadId")]
+		numbers := []int{}
+		for number := range parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		data := []byte{}
+		for _, number := range numbers {
+			data = append(data, parts[number]...)
+		}
+		s.objects[name] = data
+		delete(s.uploads, query.Get("uploadId"))
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: `"etag"`})
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(s.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		source, _ := neturl.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		data, ok := s.objects[strings.TrimPrefix(source, "/")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		s.objects[name] = data
+		s.headers[name] = r.Header
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CopyObjectResult"`
+			ETag    string
+		}{ETag: `"etag"`})
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.objects[name] = data
+		s.headers[name] = r.Header
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		data, ok := s.objects[name]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		s.get(w, r, data)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *s3TestServer) get(w http.ResponseWriter, r *http.Request, data []byte) {
+	start, end := 0, len(data)-1
+	status := http.StatusOK
+	if byteRange := r.Header.Get("Range"); byteRange != "" {
+		bounds := strings.SplitN(strings.TrimPrefix(byteRange, "bytes="), "-", 2)
+		start, _ = strconv.Atoi(bounds[0])
+		if len(bounds) == 2 && bounds[1] != "" {
+			end, _ = strconv.Atoi(bounds[1])
+		}
+		if end >= len(data) {
+			end = len(data) - 1
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		status = http.StatusPartialContent
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		w.Write(data[start : end+1])
+	}
+}
+
+// list returns a page of ListObjectsV2. The continuation token is the last key or prefix of the previous page.
+func (s *s3TestServer) list(w http.ResponseWriter, bucket string, query neturl.Values) {
+	prefix, delimiter, token := query.Get("prefix"), query.Get("delimiter"), query.Get("continuation-token")
+	maxKeys := 1000
+	if value := query.Get("max-keys"); value != "" {
+		maxKeys, _ = strconv.Atoi(value)
+	}
+
+	type content struct {
+		Key  string
+		Size int
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Name                  string
+		Prefix                string
+		KeyCount              int
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+		Contents              []content
+		CommonPrefixes        []commonPrefix
+	}{Name: bucket, Prefix: prefix}
+
+	names := []string{}
+	for name := range s.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	prefixes := map[string]bool{}
+	for _, name := range names {
+		key := strings.TrimPrefix(name, bucket+"/")
+		if !strings.HasPrefix(name, bucket+"/") || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		entry := key
+		if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+			entry = key[:len(prefix)+i+len(delimiter)]
+		}
+		if entry <= token || prefixes[entry] {
+			continue
+		}
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+
+		result.KeyCount++
+		result.NextContinuationToken = entry
+		if entry != key {
+			prefixes[entry] = true
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: entry})
+			continue
+		}
+		result.Contents = append(result.Contents, content{Key: key, Size: len(s.objects[name])})
+	}
+	if !result.IsTruncated {
+		result.NextContinuationToken = ""
+	}
+	writeXML(w, result)
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+// setS3TestEnv uses the "envkey" access key of the environment variables and the empty shared config files, instead
+// of the AWS settings of the user
+func setS3TestEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_ACCESS_KEY_ID", "envkey")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "envsecret")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	for _, name := range []string{"AWS_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_SESSION_TOKEN"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+// setupS3TestRepository returns the repository on the stand-in server. The server is set by the URL parameters.
+func setupS3TestRepository(t *testing.T) (string, RepositoryConfig) {
+	setS3TestEnv(t)
+	ts, _ := newS3TestServer(t)
+	return "s3://bucket/path/to/repo?endpoint=" + neturl.QueryEscape(ts.URL) + "&path-style=true", RepositoryConfig{}
+}
+
+func TestS3RepositoryCredentials(t *testing.T) {
+	setS3TestEnv(t)
+	ts, server := newS3TestServer(t)
+	server.objects["bucket/repo/refs/latest"] = []byte("hello")
+
+	err := os.WriteFile(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), []byte("[minio]\naws_access_key_id = profilekey\naws_secret_access_key = profilesecret\n"), 0644)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		desc      string
+		config    S3Config
+		accessKey string
+	}{
+		{desc: "environment", config: S3Config{}, accessKey: "envkey"},
+		{desc: "profile", config: S3Config{Profile: "minio"}, accessKey: "profilekey"},
+		{desc: "anonymous", config: S3Config{Anonymous: true}, accessKey: ""},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			tC.config.Endpoint = ts.URL
+			tC.config.PathStyle = true
+			repo, err := NewS3Repository("bucket", "/repo", tC.config)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			server.accessKeys = nil
+			_, err = repo.Stat(context.Background(), "refs/latest")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{tC.accessKey}, server.accessKeys)
+		})
+	}
+}
+
+func TestS3ListPages(t *testing.T) {
+	setS3TestEnv(t)
+	ts, server := newS3TestServer(t)
+	for i := 0; i < 2500; i++ {
+		server.objects[fmt.Sprintf("bucket/repo/commits/%04d", i)] = []byte("commit")
+	}
+	for i := 0; i < 1200; i++ {
+		server.objects[fmt.Sprintf("bucket/repo/refs/tags/v%04d/latest", i)] = []byte("tag")
+	}
+
+	repo, err := NewS3Repository("bucket", "/repo", S3Config{Endpoint: ts.URL, PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := repo.List(context.Background(), "commits")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2500)
+	assert.Equal(t, "2499", entries[2499].Name())
+
+	// the common prefixes span the pages too
+	entries, err = repo.List(context.Background(), "refs/tags")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1200)
+	for _, entry := range entries {
+		assert.True(t, entry.IsDir(), entry.Name())
+	}
+}
+
+func TestS3ObjectOptions(t *testing.T) {
+	setS3TestEnv(t)
+	ts, server := newS3TestServer(t)
+	ctx := context.Background()
+
+	repo, err := NewS3Repository("bucket", "/repo", S3Config{
+		Endpoint:     ts.URL,
+		PathStyle:    true,
+		StorageClass: "STANDARD_IA",
+		SSEKMSKeyID:  "mykey",
+		ACL:          "bucket-owner-full-control",
+		Tags:         "team=ml&project=avc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := t.TempDir() + "/large"
+	assert.NoError(t, generateRandomFile(path, 10*1024*1024))
+	assert.NoError(t, repo.Upload(ctx, path, "objects/ab/large", nil))
+	assert.NoError(t, repo.UploadStream(ctx, strings.NewReader("blob"), "objects/ab/blob", nil))
+	assert.NoError(t, repo.UploadStream(ctx, strings.NewReader("commit"), "refs/latest", nil))
+	assert.NoError(t, repo.CopyFrom(ctx, repo, "objects/ab/blob", "objects/ab/copied"))
+
+	for _, name := range []string{"objects/ab/large", "objects/ab/blob", "refs/latest", "objects/ab/copied"} {
+		header := server.headers["bucket/repo/"+name]
+		if !assert.NotNil(t, header, name) {
+			continue
+		}
+
+		storageClass := "STANDARD_IA"
+		if name == "refs/latest" {
+			storageClass = ""
+		}
+		assert.Equal(t, storageClass, header.Get("X-Amz-Storage-Class"), name)
+		assert.Equal(t, "aws:kms", header.Get("X-Amz-Server-Side-Encryption"), name)
+		assert.Equal(t, "mykey", header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"), name)
+		assert.Equal(t, "bucket-owner-full-control", header.Get("X-Amz-Acl"), name)
+		assert.Equal(t, "team=ml&project=avc", header.Get("X-Amz-Tagging"), name)
+	}
+	assert.Equal(t, "REPLACE", server.headers["bucket/repo/objects/ab/copied"].Get("X-Amz-Tagging-Directive"))
+}
+
+func TestS3ConfigValidate(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		config S3Config
+		sse    string
+		err    bool
+	}{
+		{desc: "empty", config: S3Config{}},
+		{desc: "aes256", config: S3Config{SSE: "AES256"}, sse: "AES256"},
+		{desc: "kms key", config: S3Config{SSEKMSKeyID: "mykey"}, sse: "aws:kms"},
+		{desc: "kms key with aes256", config: S3Config{SSE: "AES256", SSEKMSKeyID: "mykey"}, err: true},
+		{desc: "invalid sse", config: S3Config{SSE: "des"}, err: true},
+		{desc: "storage classes", config: S3Config{StorageClass: "GLACIER_IR", MetadataStorageClass: "STANDARD"}},
+		{desc: "invalid storage class", config: S3Config{StorageClass: "COLD"}, err: true},
+		{desc: "invalid metadata storage class", config: S3Config{MetadataStorageClass: "COLD"}, err: true},
+		{desc: "invalid acl", config: S3Config{ACL: "everyone"}, err: true},
+		{desc: "invalid tags", config: S3Config{Tags: "team=%zz"}, err: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			err := tC.config.validate()
+			if tC.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tC.sse, tC.config.SSE)
+		})
+	}
+}
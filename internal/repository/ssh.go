@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -24,6 +25,113 @@ import (
 	"golang.org/x/crypto/ssh/knownhosts"
 )
 
+const (
+	DefaultSSHConnectTimeout = 30 * time.Second
+	// the connection is closed if the server does not reply to the keepalive requests in a row, as the
+	// ServerAliveCountMax of OpenSSH
+	sshKeepaliveCountMax = 3
+)
+
+const (
+	sshHostKeyCheckingYes       = "yes"
+	sshHostKeyCheckingNo        = "no"
+	sshHostKeyCheckingAcceptNew = "accept-new"
+)
+
+// SSHConfig is the settings of the SSH repository. The empty settings fall back to the environment variables and then
+// the directives of ~/.ssh/config.
+type SSHConfig struct {
+	// The login user
+	User string
+	// The port of the ssh server
+	Port int
+	// The password of the password authentication
+	Password string
+	// The identity file of the public key authentication
+	IdentityFile string
+	// The passphrase of the identity file
+	KeyPassphrase string
+	// The host key checking, "yes", "no" or "accept-new". "accept-new" adds the keys of the new hosts to the known hosts
+	// file and rejects the changed keys. It is the default.
+	StrictHostKeyChecking string
+	// The known hosts file. The default is ~/.ssh/known_hosts.
+	KnownHostsFile string
+	// The timeout of connecting and the handshake. The default is DefaultSSHConnectTimeout.
+	ConnectTimeout time.Duration
+	// The interval of the keepalive requests. The connection is closed if the server does not reply to
+	// sshKeepaliveCountMax requests in a row. 0 disables the keepalive.
+	KeepaliveInterval time.Duration
+}
+
+// ParseSSHParams reads the user and the port of the repository URL, e.g. ssh://user@host:2222/path.
+func ParseSSHParams(result RepoParseResult, config SSHConfig) (SSHConfig, error) {
+	if result.user != "" {
+		config.User = result.user
+	}
+
+	if result.port != "" {
+		port, err := strconv.Atoi(result.port)
+		if err != nil {
+			return config, fmt.Errorf("invalid port: %s", result.port)
+		}
+		config.Port = port
+	}
+
+	return config, nil
+}
+
+// sshEnvConfig reads the settings from the deprecated SSH_* environment variables. They apply to all the ssh
+// repositories, so they are used only if the repository has no ssh settings in the workspace config.
+func sshEnvConfig() (SSHConfig, error) {
+	var config SSHConfig
+	names := []string{}
+
+	for _, env := range []struct {
+		name  string
+		value *string
+	}{
+		{"SSH_USER", &config.User},
+		{"SSH_PASSWORD", &config.Password},
+		{"SSH_IDENTITY_FILE", &config.IdentityFile},
+		{"SSH_KEY_PASSPHRASE", &config.KeyPassphrase},
+		{"SSH_STRICT_HOST_KEY_CHECKING", &config.StrictHostKeyChecking},
+	} {
+		if value := os.Getenv(env.name); value != "" {
+			*env.value = value
+			names = append(names, env.name)
+		}
+	}
+
+	if value := os.Getenv("SSH_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return config, fmt.Errorf("cannot parse SSH_PORT: %s", err.Error())
+		}
+		config.Port = port
+		names = append(names, "SSH_PORT")
+	}
+
+	if len(names) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %s is deprecated. Please use the ssh settings of the workspace config instead\n", strings.Join(names, ", "))
+	}
+	return config, nil
+}
+
+// parseSSHHostKeyChecking normalizes the value of StrictHostKeyChecking. "ask" is the same as "accept-new" because
+// there is no prompt.
+func parseSSHHostKeyChecking(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "yes", "true":
+		return sshHostKeyCheckingYes, nil
+	case "no", "off", "false":
+		return sshHostKeyCheckingNo, nil
+	case "accept-new", "ask":
+		return sshHostKeyCheckingAcceptNew, nil
+	default:
+		return "", fmt.Errorf("invalid strict host key checking: %s", value)
+	}
+}
+
 type SSHRepository struct {
 	BaseDir    string
 	SSHClient  *ssh.Client
@@ -42,8 +150,8 @@ func normalizeKeyPath(path string) string {
 	}
 }
 
-func NewSSHRepository(hostname, basePath string) (*SSHRepository, error) {
-	sshClient, err := newSSHClient(hostname, false)
+func NewSSHRepository(hostname, basePath string, config SSHConfig) (*SSHRepository, error) {
+	sshClient, err := newSSHClient(hostname, config, false)
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +170,9 @@ func NewSSHRepository(hostname, basePath string) (*SSHRepository, error) {
 	}, nil
 }
 
-func newSSHClient(hostname string, proxy bool) (*ssh.Client, error) {
+// newSSHClient connects to the ssh server. The settings are resolved in the order of the config, the environment
+// variables and ~/.ssh/config. The environment variables of the login are not applied to the proxy server.
+func newSSHClient(hostname string, config SSHConfig, proxy bool) (*ssh.Client, error) {
 	if proxy {
 		log.Debugln("try to connect to proxy server " + hostname)
 	} else {
@@ -76,11 +186,15 @@ func newSSHClient(hostname string, proxy bool) (*ssh.Client, error) {
 
 	user := currentUser.Username
 	port := 22
-	strictHostKeyChecking := true
+	strictHostKeyChecking := sshHostKeyCheckingAcceptNew
+	knownHostsFile := filepath.Join(currentUser.HomeDir, ".ssh", "known_hosts")
+	connectTimeout := DefaultSSHConnectTimeout
+	var keepaliveInterval time.Duration
 	var proxyCommand string
 	var proxyJump string
 
 	explicitSigners := []ssh.Signer{}
+	passphrase := config.KeyPassphrase
 
 	// Load ~/.ssh/config
 	f, err := os.Open(filepath.Join(currentUser.HomeDir, ".ssh", "config"))
@@ -106,15 +220,31 @@ func newSSHClient(hostname string, proxy bool) (*ssh.Client, error) {
 			user = value
 		}
 
-		if value, err := cfg.Get(alias, "StrictHostKeyChecking"); err == nil {
-			if value == "no" {
-				strictHostKeyChecking = false
+		if value, err := cfg.Get(alias, "StrictHostKeyChecking"); err == nil && value != "" {
+			if strictHostKeyChecking, err = parseSSHHostKeyChecking(value); err != nil {
+				return nil, err
 			}
 		}
 
+		if value, err := cfg.Get(alias, "ConnectTimeout"); err == nil && value != "" {
+			seconds, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse ConnectTimeout: %s", err.Error())
+			}
+			connectTimeout = time.Duration(seconds) * time.Second
+		}
+
+		if value, err := cfg.Get(alias, "ServerAliveInterval"); err == nil && value != "" {
+			seconds, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse ServerAliveInterval: %s", err.Error())
+			}
+			keepaliveInterval = time.Duration(seconds) * time.Second
+		}
+
 		if identifierFiles, err := cfg.GetAll(alias, "IdentityFile"); err == nil {
 			for _, identityFile := range identifierFiles {
-				signer, err := sshLoadIdentifyFile(identityFile)
+				signer, err := sshLoadIdentifyFile(identityFile, passphrase)
 				if err != nil {
 					log.Debugf("cannot parse key %s: %s", identityFile, err.Error())
 					continue
@@ -134,31 +264,41 @@ func newSSHClient(hostname string, proxy bool) (*ssh.Client, error) {
 		}
 	}
 
-	// host key callbacks: knownhosts
-	if value := os.Getenv("SSH_STRICT_HOST_KEY_CHECKING"); value != "" {
-		if value == "no" {
-			strictHostKeyChecking = false
-		} else if value == "yes" {
-			strictHostKeyChecking = true
+	// the settings of the repository
+	password := config.Password
+	identityFile := config.IdentityFile
+	if config.StrictHostKeyChecking != "" {
+		if strictHostKeyChecking, err = parseSSHHostKeyChecking(config.StrictHostKeyChecking); err != nil {
+			return nil, err
 		}
 	}
 
-	hostkeyCallback := ssh.InsecureIgnoreHostKey()
-	if strictHostKeyChecking && proxyCommand == "" {
-		hostkeyCallback = sshKnownhostCallback
-	} else {
-		log.Debug("skip the known hosts check")
+	if config.KnownHostsFile != "" {
+		knownHostsFile = normalizeKeyPath(config.KnownHostsFile)
 	}
 
-	if value := os.Getenv("SSH_USER"); !proxy && value != "" {
-		user = value
+	if config.User != "" {
+		user = config.User
 	}
 
-	if value := os.Getenv("SSH_PORT"); !proxy && value != "" {
-		port, err = strconv.Atoi(value)
-		if err != nil {
-			return nil, fmt.Errorf("cannot parse SSH_PORT: %s", err.Error())
-		}
+	if config.Port > 0 {
+		port = config.Port
+	}
+
+	if config.ConnectTimeout > 0 {
+		connectTimeout = config.ConnectTimeout
+	}
+
+	if config.KeepaliveInterval > 0 {
+		keepaliveInterval = config.KeepaliveInterval
+	}
+
+	// host key callbacks: knownhosts
+	hostkeyCallback := ssh.InsecureIgnoreHostKey()
+	if strictHostKeyChecking != sshHostKeyCheckingNo && proxyCommand == "" {
+		hostkeyCallback = sshKnownhostCallback(knownHostsFile, strictHostKeyChecking == sshHostKeyCheckingAcceptNew)
+	} else {
+		log.Debug("skip the known hosts check")
 	}
 
 	// ssh agent
@@ -175,19 +315,19 @@ func newSSHClient(hostname string, proxy bool) (*ssh.Client, error) {
 
 	// auth method: Password
 	authMethods := []ssh.AuthMethod{}
-	if password := os.Getenv("SSH_PASSWORD"); !proxy && password != "" {
-		log.Debugln("add password authentication from env")
+	if password != "" {
+		log.Debugln("add password authentication")
 		authMethods = append(authMethods, ssh.Password(password))
 	}
 
 	// auth method: Public Keys
-	if identityFile := os.Getenv("SSH_IDENTITY_FILE"); !proxy && identityFile != "" {
-		signer, err := sshLoadIdentifyFile(identityFile)
+	if identityFile != "" {
+		signer, err := sshLoadIdentifyFile(identityFile, passphrase)
 		if err != nil {
 			return nil, err
 		}
 
-		log.Debugln("add identify file from env: " + identityFile)
+		log.Debugln("add identify file: " + identityFile)
 		explicitSigners = append(explicitSigners, signer)
 	}
 
@@ -215,7 +355,9 @@ func newSSHClient(hostname string, proxy bool) (*ssh.Client, error) {
 		User:            user,
 		Auth:            authMethods,
 		HostKeyCallback: hostkeyCallback,
+		Timeout:         connectTimeout,
 	}
+	addr := net.JoinHostPort(hostname, strconv.Itoa(port))
 	if proxyCommand != "" {
 		proxyCommand = strings.ReplaceAll(proxyCommand, "%h", hostname)
 		proxyCommand = strings.ReplaceAll(proxyCommand, "%p", strconv.Itoa(port))
@@ -225,50 +367,129 @@ func newSSHClient(hostname string, proxy bool) (*ssh.Client, error) {
 			return nil, err
 		}
 
-		c, chans, reqs, err := ssh.NewClientConn(proxyCommandConn, hostname, &sshConfig)
+		sshClient, err = newSSHClientConn(proxyCommandConn, hostname, &sshConfig)
 		if err != nil {
 			return nil, err
 		}
-
-		sshClient = ssh.NewClient(c, chans, reqs)
 		log.Debugf("connect to %s@%s successfully\n", user, hostname)
 	} else if proxyJump != "" {
-		proxyClient, err := newSSHClient(proxyJump, true)
+		// the proxy server uses the host key checking and the timeouts of the repository only
+		proxyConfig := SSHConfig{
+			StrictHostKeyChecking: config.StrictHostKeyChecking,
+			KnownHostsFile:        config.KnownHostsFile,
+			ConnectTimeout:        config.ConnectTimeout,
+			KeepaliveInterval:     config.KeepaliveInterval,
+		}
+		proxyClient, err := newSSHClient(proxyJump, proxyConfig, true)
 		if err != nil {
 			return nil, err
 		}
 
-		proxyJumpConn, err := proxyClient.Dial("tcp", fmt.Sprintf("%s:%d", hostname, port))
+		proxyJumpConn, err := proxyClient.Dial("tcp", addr)
 		if err != nil {
 			return nil, err
 		}
 
-		ncc, chans, reqs, err := ssh.NewClientConn(proxyJumpConn, fmt.Sprintf("%s:%d", hostname, port), &sshConfig)
+		sshClient, err = newSSHClientConn(proxyJumpConn, addr, &sshConfig)
 		if err != nil {
 			return nil, err
 		}
-
-		sshClient = ssh.NewClient(ncc, chans, reqs)
 		log.Debugf("connect to %s@%s successfully\n", user, hostname)
 	} else {
-		sshClient, err = ssh.Dial("tcp", fmt.Sprintf("%s:%d", hostname, port), &sshConfig)
+		conn, err := net.DialTimeout("tcp", addr, connectTimeout)
+		if err != nil {
+			return nil, err
+		}
+
+		sshClient, err = newSSHClientConn(conn, addr, &sshConfig)
 		if err != nil {
 			return nil, err
 		}
 		log.Debugf("connect to %s@%s at port %d successfully\n", user, hostname, port)
 	}
 
+	if keepaliveInterval > 0 {
+		go sshKeepalive(sshClient, keepaliveInterval)
+	}
+
 	return sshClient, nil
 }
 
-func sshLoadIdentifyFile(identityFile string) (ssh.Signer, error) {
+// newSSHClientConn runs the handshake on the connection. The handshake fails if it is not done in the timeout of the
+// config.
+func newSSHClientConn(conn net.Conn, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	// the deadline is not supported by some connections (e.g. the channel of ProxyJump). It is best effort.
+	if config.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(config.Timeout))
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// sshKeepalive sends the keepalive requests until the connection is closed. If the server does not reply to
+// sshKeepaliveCountMax requests in a row, the connection is closed, so the pending operations fail instead of hanging
+// on a dead connection.
+func sshKeepalive(client *ssh.Client, interval time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	missed := 0
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		reply := make(chan error, 1)
+		go func() {
+			// the server replies with a failure to the unknown request, which is fine as a keepalive
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			reply <- err
+		}()
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case err := <-reply:
+			timer.Stop()
+			if err != nil {
+				return
+			}
+			missed = 0
+		case <-timer.C:
+			missed++
+			if missed >= sshKeepaliveCountMax {
+				log.Debugf("no reply of %d keepalive requests, close the connection\n", missed)
+				client.Close()
+				return
+			}
+		}
+	}
+}
+
+func sshLoadIdentifyFile(identityFile, passphrase string) (ssh.Signer, error) {
 	key, err := ioutil.ReadFile(normalizeKeyPath(identityFile))
 	if err != nil {
 		return nil, err
 	}
 
 	var signer ssh.Signer
-	passphrase := os.Getenv("SSH_KEY_PASSPHRASE")
 	if passphrase == "" {
 		signer, err = ssh.ParsePrivateKey(key)
 		if err != nil {
@@ -283,23 +504,28 @@ func sshLoadIdentifyFile(identityFile string) (ssh.Signer, error) {
 	return signer, nil
 }
 
-func sshKnownhostCallback(hostname string, remote net.Addr, key ssh.PublicKey) error {
-	currentUser, err := osuser.Current()
-	if err != nil {
-		return err
-	}
-
-	knownHostFile := filepath.Join(currentUser.HomeDir, ".ssh", "known_hosts")
-	log.Debugln("check known hosts by file " + knownHostFile)
+// sshKnownhostCallback checks the host key by the known hosts file. If acceptNew is set, the key of a new host is
+// added to the file, which is created if it does not exist. The changed key of a known host is always rejected.
+func sshKnownhostCallback(knownHostFile string, acceptNew bool) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		log.Debugln("check known hosts by file " + knownHostFile)
 
-	knownhostCallback, err := knownhosts.New(knownHostFile)
-	if err != nil {
-		log.Debug("cannot load knownhost file: " + err.Error())
-	} else {
 		var keyErr *knownhosts.KeyError
-		hErr := knownhostCallback(hostname, remote, key)
+		knownhostCallback, err := knownhosts.New(knownHostFile)
+		if err == nil {
+			hErr := knownhostCallback(hostname, remote, key)
+			if hErr == nil {
+				return nil
+			}
+			if !errors.As(hErr, &keyErr) {
+				return hErr
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
 		keyString := knownhosts.Line([]string{}, key)
-		if errors.As(hErr, &keyErr) && len(keyErr.Want) > 0 {
+		if keyErr != nil && len(keyErr.Want) > 0 {
 			// Reference: https://www.godoc.org/golang.org/x/crypto/ssh/knownhosts#KeyError
 			// host key found but key mismatch. return err
 			fmt.Fprintf(os.Stderr, "Error: %s is not a key of %s, either a MiTM attack or %s has reconfigured the host pub key.\n", keyString, hostname, hostname)
@@ -307,26 +533,28 @@ func sshKnownhostCallback(hostname string, remote net.Addr, key ssh.PublicKey) e
 				fmt.Fprintf(os.Stderr, "     Offending %v key in %s:%d\n", w.Key.Type(), w.Filename, w.Line)
 			}
 			return keyErr
-		} else if errors.As(hErr, &keyErr) && len(keyErr.Want) == 0 {
-			fmt.Printf("Warning: %s is not trusted, adding this key: %s to known_hosts file.\n", hostname, keyString)
-			f, err := os.OpenFile(knownHostFile, os.O_APPEND|os.O_WRONLY, 0o600)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-			line := fmt.Sprintf("%s\n", knownhosts.Line([]string{hostname}, key))
-			_, err = f.WriteString(line)
-			if err != nil {
-				return err
-			}
 		}
+
+		if !acceptNew {
+			return fmt.Errorf("host key verification failed: %s is not in the known hosts file %s", hostname, knownHostFile)
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: %s is not trusted, adding this key: %s to known_hosts file.\n", hostname, keyString)
+		if err := os.MkdirAll(filepath.Dir(knownHostFile), 0o700); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(knownHostFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		line := fmt.Sprintf("%s\n", knownhosts.Line([]string{hostname}, key))
+		_, err = f.WriteString(line)
+		return err
 	}
-	return nil
 }
 
-func (repo *SSHRepository) Upload(localPath, repoPath string, m *Meter) error {
-	client := repo.SFTPClient
-
+func (repo *SSHRepository) Upload(ctx context.Context, localPath, repoPath string, m *Meter) error {
 	sourceFileStat, err := os.Stat(localPath)
 	if err != nil {
 		return err
@@ -342,9 +570,48 @@ func (repo *SSHRepository) Upload(localPath, repoPath string, m *Meter) error {
 	}
 	defer source.Close()
 
+	return repo.uploadFrom(&sshFileWrapper{ctx: ctx, file: source, meter: m}, repoPath)
+}
+
+func (repo *SSHRepository) Download(ctx context.Context, repoPath, localPath string, m *Meter) error {
+	client := repo.SFTPClient
+
+	srcPath := path.Join(repo.BaseDir, repoPath)
+	src, err := client.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	written, err := src.WriteTo(&sshFileWrapper{ctx: ctx, file: dest, meter: m})
+	if err != nil {
+		return err
+	}
+
+	if written == 0 {
+		err = os.Truncate(localPath, 0)
+	}
+
+	return err
+}
+
+func (repo *SSHRepository) UploadStream(ctx context.Context, src io.Reader, repoPath string, m *Meter) error {
+	return repo.uploadFrom(NewMeterReader(ctx, src, m), repoPath)
+}
+
+// uploadFrom writes the reader to a temp file and moves it to the destination
+func (repo *SSHRepository) uploadFrom(src io.Reader, repoPath string) error {
+	client := repo.SFTPClient
+
 	// Copy from source to tmp
 	tmpDir := path.Join(repo.BaseDir, "tmp")
-	err = client.MkdirAll(tmpDir)
+	err := client.MkdirAll(tmpDir)
 	if err != nil {
 		return err
 	}
@@ -360,8 +627,9 @@ func (repo *SSHRepository) Upload(localPath, repoPath string, m *Meter) error {
 		}
 	}()
 
-	_, err = tmp.ReadFrom(&sshFileWrapper{file: source, meter: m})
+	_, err = tmp.ReadFrom(src)
 	if err != nil {
+		tmp.Close()
 		return err
 	}
 
@@ -389,51 +657,52 @@ func (repo *SSHRepository) Upload(localPath, repoPath string, m *Meter) error {
 	return nil
 }
 
-func (repo *SSHRepository) Download(repoPath, localPath string, m *Meter) error {
-	client := repo.SFTPClient
-
+func (repo *SSHRepository) DownloadStream(ctx context.Context, repoPath string, dest io.Writer, m *Meter) error {
 	srcPath := path.Join(repo.BaseDir, repoPath)
-	src, err := client.Open(srcPath)
+	src, err := repo.SFTPClient.Open(srcPath)
 	if err != nil {
 		return err
 	}
 	defer src.Close()
 
-	dest, err := os.Create(localPath)
-	if err != nil {
-		return err
-	}
-	defer dest.Close()
+	_, err = CopyWithMeter(ctx, dest, src, m)
+	return err
+}
 
-	written, err := src.WriteTo(&sshFileWrapper{file: dest, meter: m})
+func (repo *SSHRepository) ReadRange(ctx context.Context, repoPath string, offset, length int64) (io.ReadCloser, error) {
+	srcPath := path.Join(repo.BaseDir, repoPath)
+	src, err := repo.SFTPClient.Open(srcPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if written == 0 {
-		err = os.Truncate(localPath, 0)
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		src.Close()
+		return nil, err
 	}
 
-	return err
+	return limitReadCloser(src, length), nil
 }
 
-func (repo *SSHRepository) Delete(repoPath string) error {
+func (repo *SSHRepository) Delete(ctx context.Context, repoPath string) error {
 	filePath := path.Join(repo.BaseDir, repoPath)
 	return repo.SFTPClient.Remove(filePath)
 }
 
-func (repo *SSHRepository) Stat(repoPath string) (FileInfo, error) {
+func (repo *SSHRepository) Stat(ctx context.Context, repoPath string) (FileInfo, error) {
 	filePath := path.Join(repo.BaseDir, repoPath)
 	return repo.SFTPClient.Stat(filePath)
 }
 
-func (repo *SSHRepository) List(repoPath string) ([]FileInfo, error) {
+func (repo *SSHRepository) List(ctx context.Context, repoPath string) ([]FileInfo, error) {
 	client := repo.SFTPClient
 
 	dir := path.Join(repo.BaseDir, repoPath)
 	fs, err := client.ReadDir(dir)
-	if err != nil {
+	if os.IsNotExist(err) {
 		return []FileInfo{}, nil
+	} else if err != nil {
+		return nil, err
 	}
 	fs2 := []FileInfo{}
 
@@ -446,23 +715,35 @@ func (repo *SSHRepository) List(repoPath string) ([]FileInfo, error) {
 	return fs2, nil
 }
 
+// sshFileWrapper wraps the local file to update the meter and stop the transfer once the context is done
 type sshFileWrapper struct {
+	ctx   context.Context
 	file  *os.File
 	meter *Meter
 }
 
 func (r *sshFileWrapper) Read(p []byte) (n int, err error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	n, err = r.file.Read(p)
 	if err == nil && r.meter != nil {
 		r.meter.AddBytes(n)
+		err = r.meter.Wait(r.ctx, n)
 	}
 	return
 }
 
 func (r *sshFileWrapper) Write(p []byte) (n int, err error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	n, err = r.file.Write(p)
 	if err == nil && r.meter != nil {
 		r.meter.AddBytes(n)
+		err = r.meter.Wait(r.ctx, n)
 	}
 	return
 }
@@ -548,3 +829,18 @@ func (f *proxyCommandConn) SetReadDeadline(t time.Time) error {
 func (f *proxyCommandConn) SetWriteDeadline(t time.Time) error {
 	return nil
 }
+
+// IsRetryableError checks the transient sftp failures and network errors. Once the connection is lost,
+// the operations are not retried because the client cannot be recovered.
+func (repo *SSHRepository) IsRetryableError(err error) bool {
+	if errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, sftp.ErrSSHFxNoConnection) {
+		return false
+	}
+
+	var statusErr *sftp.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.FxCode() == sftp.ErrSSHFxFailure
+	}
+
+	return isRetryableError(err)
+}
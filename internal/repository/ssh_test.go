@@ -0,0 +1,368 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshTestServer is an ssh server with the sftp subsystem on the local filesystem. It accepts the password "secret" and
+// the public key of the authorized key.
+type sshTestServer struct {
+	addr    string
+	hostKey ssh.Signer
+
+	mtx           sync.Mutex
+	authorizedKey ssh.PublicKey
+	users         []string
+	keepalives    int
+	// do not reply to the keepalive requests, as a dead connection
+	ignoreKeepalive bool
+}
+
+func newSSHTestServer(t *testing.T) *sshTestServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &sshTestServer{addr: listener.Addr().String(), hostKey: hostKey}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			server.mtx.Lock()
+			defer server.mtx.Unlock()
+			server.users = append(server.users, conn.User())
+			if string(password) != "secret" {
+				return nil, ssh.ErrNoAuth
+			}
+			return nil, nil
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			server.mtx.Lock()
+			defer server.mtx.Unlock()
+			server.users = append(server.users, conn.User())
+			if server.authorizedKey == nil || !bytes.Equal(key.Marshal(), server.authorizedKey.Marshal()) {
+				return nil, ssh.ErrNoAuth
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, config)
+		}
+	}()
+
+	return server
+}
+
+func (s *sshTestServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	serverConn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer serverConn.Close()
+
+	go func() {
+		for req := range reqs {
+			s.mtx.Lock()
+			s.keepalives++
+			ignore := s.ignoreKeepalive
+			s.mtx.Unlock()
+			if req.WantReply && !ignore {
+				req.Reply(false, nil)
+			}
+		}
+	}()
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					server, err := sftp.NewServer(channel)
+					if err != nil {
+						channel.Close()
+						return
+					}
+					server.Serve()
+					channel.Close()
+				}
+			}
+		}()
+	}
+}
+
+func (s *sshTestServer) port() string {
+	_, port, _ := net.SplitHostPort(s.addr)
+	return port
+}
+
+// setSSHTestEnv unsets the ssh agent and the deprecated SSH_* variables, so only the keys and the passwords of the
+// test are offered
+func setSSHTestEnv(t *testing.T) {
+	for _, name := range []string{"SSH_AUTH_SOCK", "SSH_USER", "SSH_PORT", "SSH_PASSWORD", "SSH_IDENTITY_FILE", "SSH_KEY_PASSPHRASE", "SSH_STRICT_HOST_KEY_CHECKING"} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+// setupSSHTestRepository returns the repository on the stand-in server. The user and the port are in the URL.
+func setupSSHTestRepository(t *testing.T) (string, RepositoryConfig) {
+	setSSHTestEnv(t)
+	server := newSSHTestServer(t)
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+	return "ssh://avc@" + server.addr + t.TempDir(), RepositoryConfig{SSH: SSHConfig{Password: "secret", KnownHostsFile: knownHostsFile}}
+}
+
+func TestSSHAuthentication(t *testing.T) {
+	setSSHTestEnv(t)
+	server := newSSHTestServer(t)
+	tmpDir := t.TempDir()
+	knownHostsFile := filepath.Join(tmpDir, "known_hosts")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.NoError(t, err)
+	server.authorizedKey = signer.PublicKey()
+	identityFile := filepath.Join(tmpDir, "id_rsa")
+	assert.NoError(t, os.WriteFile(identityFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+
+	testCases := []struct {
+		desc   string
+		config SSHConfig
+		env    map[string]string
+		err    bool
+	}{
+		{desc: "password", config: SSHConfig{User: "avc", Password: "secret"}},
+		{desc: "identity file", config: SSHConfig{User: "avc", IdentityFile: identityFile}},
+		{desc: "wrong password", config: SSHConfig{User: "avc", Password: "wrong"}, err: true},
+		{desc: "environment variables are not used", config: SSHConfig{User: "avc", Password: "secret"}, env: map[string]string{"SSH_USER": "other", "SSH_PASSWORD": "wrong"}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			for name, value := range tC.env {
+				t.Setenv(name, value)
+			}
+
+			config := tC.config
+			config.KnownHostsFile = knownHostsFile
+			result, err := ParseRepo("ssh://" + server.addr + tmpDir)
+			assert.NoError(t, err)
+			config, err = ParseSSHParams(result, config)
+			assert.NoError(t, err)
+
+			repo, err := NewSSHRepository(result.host, result.path, config)
+			if tC.err {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				_, err = repo.List(context.Background(), "")
+				assert.NoError(t, err)
+				repo.SSHClient.Close()
+
+				server.mtx.Lock()
+				assert.Equal(t, "avc", server.users[len(server.users)-1])
+				server.mtx.Unlock()
+			}
+		})
+	}
+}
+
+func TestSSHHostKeyChecking(t *testing.T) {
+	setSSHTestEnv(t)
+	server := newSSHTestServer(t)
+	tmpDir := t.TempDir()
+
+	knownHostsFile := filepath.Join(tmpDir, "known_hosts")
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	otherSigner, err := ssh.NewSignerFromKey(otherKey)
+	assert.NoError(t, err)
+	changedKnownHostsFile := filepath.Join(tmpDir, "changed_known_hosts")
+	assert.NoError(t, os.WriteFile(changedKnownHostsFile, []byte(knownhosts.Line([]string{server.addr}, otherSigner.PublicKey())+"\n"), 0600))
+
+	testCases := []struct {
+		desc           string
+		checking       string
+		knownHostsFile string
+		err            bool
+	}{
+		{desc: "yes without the known hosts file", checking: "yes", knownHostsFile: knownHostsFile, err: true},
+		{desc: "accept-new adds the new host", checking: "accept-new", knownHostsFile: knownHostsFile},
+		{desc: "yes with the known host", checking: "yes", knownHostsFile: knownHostsFile},
+		{desc: "accept-new with the changed key", checking: "accept-new", knownHostsFile: changedKnownHostsFile, err: true},
+		{desc: "yes with the changed key", checking: "yes", knownHostsFile: changedKnownHostsFile, err: true},
+		{desc: "no with the changed key", checking: "no", knownHostsFile: changedKnownHostsFile},
+		{desc: "invalid", checking: "maybe", knownHostsFile: knownHostsFile, err: true},
+	}
+
+	// the warnings do not go to the stdout, which may be the output of "avc cat"
+	stdout, err := os.Create(filepath.Join(tmpDir, "stdout"))
+	assert.NoError(t, err)
+	defer stdout.Close()
+	os.Stdout, stdout = stdout, os.Stdout
+	defer func() { os.Stdout = stdout }()
+
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			config := SSHConfig{
+				User:                  "avc",
+				Password:              "secret",
+				StrictHostKeyChecking: tC.checking,
+				KnownHostsFile:        tC.knownHostsFile,
+			}
+			result, err := ParseRepo("ssh://" + server.addr + tmpDir)
+			assert.NoError(t, err)
+			config, err = ParseSSHParams(result, config)
+			assert.NoError(t, err)
+
+			repo, err := NewSSHRepository(result.host, result.path, config)
+			if tC.err {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				repo.SSHClient.Close()
+			}
+		})
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "stdout"))
+	assert.NoError(t, err)
+	assert.Empty(t, string(data))
+
+	// the new host is added to the known hosts file, and the changed key is not replaced
+	data, err = os.ReadFile(knownHostsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, knownhosts.Line([]string{server.addr}, server.hostKey.PublicKey())+"\n", string(data))
+	data, err = os.ReadFile(changedKnownHostsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), "\n"))
+}
+
+func TestSSHTimeout(t *testing.T) {
+	setSSHTestEnv(t)
+
+	// the server accepts the connection but never does the handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	result, err := ParseRepo("ssh://avc@127.0.0.1:" + port + "/tmp")
+	assert.NoError(t, err)
+	config, err := ParseSSHParams(result, SSHConfig{Password: "secret", ConnectTimeout: 100 * time.Millisecond})
+	assert.NoError(t, err)
+
+	start := time.Now()
+	_, err = NewSSHRepository(result.host, result.path, config)
+	assert.Error(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestSSHKeepalive(t *testing.T) {
+	setSSHTestEnv(t)
+
+	testCases := []struct {
+		desc   string
+		ignore bool
+	}{
+		{desc: "replied", ignore: false},
+		{desc: "unanswered", ignore: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			server := newSSHTestServer(t)
+			server.ignoreKeepalive = tC.ignore
+
+			config := SSHConfig{
+				User:                  "avc",
+				Password:              "secret",
+				StrictHostKeyChecking: "no",
+				KeepaliveInterval:     10 * time.Millisecond,
+			}
+			result, err := ParseRepo("ssh://127.0.0.1:" + server.port() + "/tmp")
+			assert.NoError(t, err)
+			config, err = ParseSSHParams(result, config)
+			assert.NoError(t, err)
+			client, err := newSSHClient(result.host, config, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			// the connection is closed after the unanswered keepalive requests only
+			closed := make(chan struct{})
+			go func() {
+				client.Wait()
+				close(closed)
+			}()
+			select {
+			case <-closed:
+				assert.True(t, tC.ignore, "the connection is closed")
+			case <-time.After(500 * time.Millisecond):
+				assert.False(t, tC.ignore, "the connection is not closed")
+			}
+
+			server.mtx.Lock()
+			assert.Greater(t, server.keepalives, 0)
+			server.mtx.Unlock()
+		})
+	}
+}
@@ -0,0 +1,578 @@
+package repository
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/infuseai/artivc/internal/log"
+)
+
+// WebDAVConfig is the credentials of the WebDAV repository. The bearer token takes precedence over the username and
+// password. The password is sent by the basic auth, or by the digest auth if the server requires it.
+type WebDAVConfig struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// WebDAVRepository stores the objects on a WebDAV server. The uploads are written to a temp path and moved to the
+// destination, so that a partial object is never visible.
+type WebDAVRepository struct {
+	// The base url in http or https
+	BaseUrl string
+	client  *http.Client
+	auth    *webdavAuth
+
+	// the collections known to exist
+	mtx         sync.Mutex
+	collections map[string]bool
+}
+
+// NewWebDAVRepository creates the repository of the webdav:// or webdavs:// url. The credentials are resolved in the
+// order of the config, the environment variables and the user info of the url.
+func NewWebDAVRepository(repo string, config WebDAVConfig) (*WebDAVRepository, error) {
+	url, err := neturl.Parse(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	switch url.Scheme {
+	case "webdav":
+		url.Scheme = "http"
+	case "webdavs":
+		url.Scheme = "https"
+	default:
+		return nil, fmt.Errorf("unsupported webdav url: %s", repo)
+	}
+
+	if config.Username == "" {
+		config.Username = os.Getenv("AVC_WEBDAV_USERNAME")
+	}
+	if config.Password == "" {
+		config.Password = os.Getenv("AVC_WEBDAV_PASSWORD")
+	}
+	if config.Token == "" {
+		config.Token = os.Getenv("AVC_WEBDAV_TOKEN")
+	}
+	if url.User != nil {
+		if config.Username == "" {
+			config.Username = url.User.Username()
+		}
+		if password, ok := url.User.Password(); ok && config.Password == "" {
+			config.Password = password
+		}
+		url.User = nil
+	}
+
+	url.Path = strings.TrimSuffix(url.Path, "/")
+	url.RawQuery = ""
+	url.Fragment = ""
+
+	return &WebDAVRepository{
+		BaseUrl:     url.String(),
+		client:      http.DefaultClient,
+		auth:        &webdavAuth{config: config},
+		collections: map[string]bool{},
+	}, nil
+}
+
+func (repo *WebDAVRepository) Upload(ctx context.Context, localPath, repoPath string, m *Meter) error {
+	sourceFileStat, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+
+	if !sourceFileStat.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", localPath)
+	}
+
+	source, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	return repo.UploadStream(ctx, source, repoPath, m)
+}
+
+func (repo *WebDAVRepository) Download(ctx context.Context, repoPath, localPath string, m *Meter) error {
+	dest, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	return repo.DownloadStream(ctx, repoPath, dest, m)
+}
+
+func (repo *WebDAVRepository) UploadStream(ctx context.Context, src io.Reader, repoPath string, m *Meter) error {
+	tmpPath := path.Join("tmp", randomName())
+	if err := repo.mkcolAll(ctx, "tmp"); err != nil {
+		return err
+	}
+
+	// Copy from source to tmp
+	res, err := repo.do(ctx, http.MethodPut, tmpPath, NewMeterReader(ctx, src, m), nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusNoContent {
+		return HttpStatusError{StatusCode: res.StatusCode}
+	}
+
+	// Move from tmp to dest
+	err = repo.move(ctx, tmpPath, repoPath)
+	if err != nil {
+		if err := repo.Delete(context.Background(), tmpPath); err != nil {
+			log.Debugf("cannot remove the temp path %s: %s\n", tmpPath, err.Error())
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (repo *WebDAVRepository) move(ctx context.Context, srcPath, destPath string) error {
+	if err := repo.mkcolAll(ctx, path.Dir(destPath)); err != nil {
+		return err
+	}
+
+	res, err := repo.do(ctx, "MOVE", srcPath, nil, map[string]string{
+		"Destination": repo.url(destPath),
+		"Overwrite":   "T",
+	})
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		return HttpStatusError{StatusCode: res.StatusCode}
+	}
+
+	return nil
+}
+
+// mkcolAll creates the collection and its parents up to the base collection if they do not exist
+func (repo *WebDAVRepository) mkcolAll(ctx context.Context, dir string) error {
+	if dir == "." || dir == "/" {
+		dir = ""
+	}
+
+	repo.mtx.Lock()
+	exists := repo.collections[dir]
+	repo.mtx.Unlock()
+	if exists {
+		return nil
+	}
+
+	if dir != "" {
+		if err := repo.mkcolAll(ctx, path.Dir(dir)); err != nil {
+			return err
+		}
+	}
+
+	res, err := repo.do(ctx, "MKCOL", dir, nil, nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	// 405 means the collection already exists
+	if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusMethodNotAllowed && res.StatusCode != http.StatusOK {
+		return HttpStatusError{StatusCode: res.StatusCode}
+	}
+
+	repo.mtx.Lock()
+	repo.collections[dir] = true
+	repo.mtx.Unlock()
+	return nil
+}
+
+func (repo *WebDAVRepository) DownloadStream(ctx context.Context, repoPath string, dest io.Writer, m *Meter) error {
+	res, err := repo.do(ctx, http.MethodGet, repoPath, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return HttpStatusError{StatusCode: res.StatusCode}
+	}
+
+	_, err = CopyWithMeter(ctx, dest, res.Body, m)
+	return err
+}
+
+func (repo *WebDAVRepository) ReadRange(ctx context.Context, repoPath string, offset, length int64) (io.ReadCloser, error) {
+	res, err := repo.do(ctx, http.MethodGet, repoPath, nil, map[string]string{"Range": httpRange(offset, length)})
+	if err != nil {
+		return nil, err
+	}
+
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+		return res.Body, nil
+	case http.StatusOK:
+		if _, err := io.CopyN(io.Discard, res.Body, offset); err != nil {
+			res.Body.Close()
+			return nil, err
+		}
+		return limitReadCloser(res.Body, length), nil
+	default:
+		res.Body.Close()
+		return nil, HttpStatusError{StatusCode: res.StatusCode}
+	}
+}
+
+func (repo *WebDAVRepository) Delete(ctx context.Context, repoPath string) error {
+	res, err := repo.do(ctx, http.MethodDelete, repoPath, nil, nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		return HttpStatusError{StatusCode: res.StatusCode}
+	}
+
+	return nil
+}
+
+func (repo *WebDAVRepository) Stat(ctx context.Context, repoPath string) (FileInfo, error) {
+	responses, err := repo.propfind(ctx, repoPath, "0")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(responses) == 0 {
+		return nil, HttpStatusError{StatusCode: http.StatusNotFound}
+	}
+
+	return &SimpleFileInfo{
+		name:  path.Base(repoPath),
+		isDir: responses[0].isCollection(),
+	}, nil
+}
+
+func (repo *WebDAVRepository) List(ctx context.Context, repoPath string) ([]FileInfo, error) {
+	responses, err := repo.propfind(ctx, repoPath, "1")
+	if errors.Is(err, os.ErrNotExist) {
+		return []FileInfo{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	base, err := neturl.Parse(repo.url(repoPath))
+	if err != nil {
+		return nil, err
+	}
+	dir := strings.TrimSuffix(base.Path, "/")
+
+	entries := make([]FileInfo, 0)
+	for _, response := range responses {
+		href, err := neturl.Parse(response.Href)
+		if err != nil {
+			return nil, err
+		}
+
+		// skip the collection itself
+		p := strings.TrimSuffix(href.Path, "/")
+		if p == dir {
+			continue
+		}
+
+		entries = append(entries, &SimpleFileInfo{
+			name:  path.Base(p),
+			isDir: response.isCollection(),
+		})
+	}
+	return entries, nil
+}
+
+type webdavMultistatus struct {
+	Responses []webdavResponse `xml:"DAV: response"`
+}
+
+type webdavResponse struct {
+	Href      string `xml:"DAV: href"`
+	Propstats []struct {
+		Status string `xml:"DAV: status"`
+		Prop   struct {
+			ResourceType struct {
+				Collection *struct{} `xml:"DAV: collection"`
+			} `xml:"DAV: resourcetype"`
+		} `xml:"DAV: prop"`
+	} `xml:"DAV: propstat"`
+}
+
+func (r webdavResponse) isCollection() bool {
+	for _, propstat := range r.Propstats {
+		if propstat.Prop.ResourceType.Collection != nil {
+			return true
+		}
+	}
+	return false
+}
+
+const webdavPropfindBody = `<?xml version="1.0" encoding="utf-8"?><propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`
+
+func (repo *WebDAVRepository) propfind(ctx context.Context, repoPath, depth string) ([]webdavResponse, error) {
+	res, err := repo.do(ctx, "PROPFIND", repoPath, strings.NewReader(webdavPropfindBody), map[string]string{
+		"Depth":        depth,
+		"Content-Type": "application/xml; charset=utf-8",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusMultiStatus {
+		return nil, HttpStatusError{StatusCode: res.StatusCode}
+	}
+
+	var multistatus webdavMultistatus
+	if err := xml.NewDecoder(res.Body).Decode(&multistatus); err != nil {
+		return nil, fmt.Errorf("invalid PROPFIND response: %w", err)
+	}
+
+	return multistatus.Responses, nil
+}
+
+func (repo *WebDAVRepository) url(repoPath string) string {
+	u := &neturl.URL{Path: strings.TrimPrefix(repoPath, "/")}
+	return repo.BaseUrl + "/" + u.EscapedPath()
+}
+
+// do sends the request with the credentials. The bodyless requests are resent once if the server asks for
+// another digest challenge.
+func (repo *WebDAVRepository) do(ctx context.Context, method, repoPath string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	if body != nil && repo.auth.needsChallenge() {
+		// the body cannot be resent after the challenge. get the challenge by a bodyless request first.
+		if err := repo.challenge(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, repo.url(repoPath), body)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		if err := repo.auth.authorize(req); err != nil {
+			return nil, err
+		}
+
+		res, err := repo.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode == http.StatusUnauthorized && repo.auth.update(res) && attempt == 0 && (body == nil || req.GetBody != nil) {
+			res.Body.Close()
+			if body != nil {
+				if body, err = req.GetBody(); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		return res, nil
+	}
+}
+
+// challenge requests the properties of the base collection to get the digest challenge
+func (repo *WebDAVRepository) challenge(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", repo.BaseUrl+"/", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Depth", "0")
+
+	if err := repo.auth.authorize(req); err != nil {
+		return err
+	}
+
+	res, err := repo.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	repo.auth.update(res)
+	return nil
+}
+
+// IsRetryableError checks the http status and network errors
+func (repo *WebDAVRepository) IsRetryableError(err error) bool {
+	var statusErr HttpStatusError
+	if errors.As(err, &statusErr) {
+		return isRetryableStatusCode(statusErr.StatusCode)
+	}
+
+	return isRetryableError(err)
+}
+
+// webdavAuth sets the bearer, basic or digest authorization of the requests
+type webdavAuth struct {
+	config WebDAVConfig
+
+	mtx        sync.Mutex
+	challenged bool
+	digest     map[string]string
+	nc         int
+}
+
+// needsChallenge checks if the digest challenge is unknown yet
+func (a *webdavAuth) needsChallenge() bool {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.config.Token == "" && a.config.Username != "" && !a.challenged
+}
+
+// update reads the digest challenge of the response. It returns true if there is a new challenge.
+func (a *webdavAuth) update(res *http.Response) bool {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.challenged = true
+	for _, value := range res.Header.Values("WWW-Authenticate") {
+		if !strings.HasPrefix(strings.ToLower(value), "digest ") {
+			continue
+		}
+
+		params := parseAuthParams(value[len("digest "):])
+		if a.digest != nil && a.digest["nonce"] == params["nonce"] {
+			return false
+		}
+		a.digest = params
+		a.nc = 0
+		return a.config.Token == "" && a.config.Username != ""
+	}
+	return false
+}
+
+func (a *webdavAuth) authorize(req *http.Request) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	switch {
+	case a.config.Token != "":
+		req.Header.Set("Authorization", "Bearer "+a.config.Token)
+	case a.config.Username == "":
+	case a.digest != nil:
+		a.nc++
+		value, err := digestAuthorization(a.digest, a.config.Username, a.config.Password, req.Method, req.URL.RequestURI(), a.nc)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", value)
+	default:
+		req.SetBasicAuth(a.config.Username, a.config.Password)
+	}
+	return nil
+}
+
+// digestAuthorization computes the Authorization header of the digest auth (RFC 7616) with MD5
+func digestAuthorization(challenge map[string]string, username, password, method, uri string, nc int) (string, error) {
+	if algorithm := challenge["algorithm"]; algorithm != "" && !strings.EqualFold(algorithm, "MD5") {
+		return "", fmt.Errorf("unsupported digest algorithm: %s", algorithm)
+	}
+
+	realm := challenge["realm"]
+	nonce := challenge["nonce"]
+	ha1 := md5hex(username + ":" + realm + ":" + password)
+	ha2 := md5hex(method + ":" + uri)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `Digest username="%s", realm="%s", nonce="%s", uri="%s"`, username, realm, nonce, uri)
+
+	qop := ""
+	for _, q := range strings.Split(challenge["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	if qop != "" {
+		cnonce := randomName()
+		ncValue := fmt.Sprintf("%08x", nc)
+		response := md5hex(strings.Join([]string{ha1, nonce, ncValue, cnonce, qop, ha2}, ":"))
+		fmt.Fprintf(&sb, `, qop=%s, nc=%s, cnonce="%s", response="%s"`, qop, ncValue, cnonce, response)
+	} else {
+		fmt.Fprintf(&sb, `, response="%s"`, md5hex(ha1+":"+nonce+":"+ha2))
+	}
+
+	if opaque, ok := challenge["opaque"]; ok {
+		fmt.Fprintf(&sb, `, opaque="%s"`, opaque)
+	}
+	if algorithm, ok := challenge["algorithm"]; ok {
+		fmt.Fprintf(&sb, `, algorithm=%s`, algorithm)
+	}
+
+	return sb.String(), nil
+}
+
+// parseAuthParams parses the comma separated key=value or key="value" parameters
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		i := strings.Index(s, "=")
+		if i < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:i]))
+		s = s[i+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else {
+			end := strings.Index(s, ",")
+			if end < 0 {
+				value, s = s, ""
+			} else {
+				value, s = s[:end], s[end:]
+			}
+		}
+		params[key] = strings.TrimSpace(value)
+	}
+	return params
+}
+
+func md5hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomName() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
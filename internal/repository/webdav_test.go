@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+const (
+	testWebDAVUsername = "user"
+	testWebDAVPassword = "secret"
+	testWebDAVRealm    = "avc"
+	testWebDAVNonce    = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+)
+
+// webdavAuthHandler checks the basic, digest or bearer authorization before the webdav handler
+func webdavAuthHandler(auth string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get("Authorization")
+
+		switch auth {
+		case "basic":
+			username, password, ok := r.BasicAuth()
+			if !ok || username != testWebDAVUsername || password != testWebDAVPassword {
+				w.Header().Set("WWW-Authenticate", `Basic realm="`+testWebDAVRealm+`"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		case "digest":
+			if !strings.HasPrefix(value, "Digest ") || !checkDigest(r.Method, parseAuthParams(value[len("Digest "):])) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", qop="auth", nonce="%s", algorithm=MD5`, testWebDAVRealm, testWebDAVNonce))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		case "bearer":
+			if subtle.ConstantTimeCompare([]byte(value), []byte("Bearer "+testWebDAVPassword)) != 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func checkDigest(method string, params map[string]string) bool {
+	if params["username"] != testWebDAVUsername || params["nonce"] != testWebDAVNonce || params["qop"] != "auth" {
+		return false
+	}
+
+	ha1 := md5hex(testWebDAVUsername + ":" + testWebDAVRealm + ":" + testWebDAVPassword)
+	ha2 := md5hex(method + ":" + params["uri"])
+	expected := md5hex(strings.Join([]string{ha1, params["nonce"], params["nc"], params["cnonce"], "auth", ha2}, ":"))
+	return params["response"] == expected
+}
+
+func newWebDAVTestServer(t *testing.T, auth string) (*httptest.Server, string) {
+	dir := t.TempDir()
+	handler := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.Dir(dir),
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	ts := httptest.NewServer(webdavAuthHandler(auth, handler))
+	t.Cleanup(ts.Close)
+	return ts, dir
+}
+
+// setupWebDAVTestRepository returns the repository on the stand-in server with the basic authentication
+func setupWebDAVTestRepository(t *testing.T) (string, RepositoryConfig) {
+	ts, _ := newWebDAVTestServer(t, "basic")
+	repo := strings.Replace(ts.URL, "http://", "webdav://", 1) + "/dav/repo"
+	return repo, RepositoryConfig{WebDAV: WebDAVConfig{Username: testWebDAVUsername, Password: testWebDAVPassword}}
+}
+
+func TestWebDAVAuthentication(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		desc   string
+		auth   string
+		config WebDAVConfig
+		err    bool
+	}{
+		{desc: "no auth", auth: ""},
+		{desc: "basic", auth: "basic", config: WebDAVConfig{Username: testWebDAVUsername, Password: testWebDAVPassword}},
+		{desc: "digest", auth: "digest", config: WebDAVConfig{Username: testWebDAVUsername, Password: testWebDAVPassword}},
+		{desc: "bearer", auth: "bearer", config: WebDAVConfig{Token: testWebDAVPassword}},
+		{desc: "missing credentials", auth: "basic", err: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ts, _ := newWebDAVTestServer(t, tC.auth)
+			repo, err := NewWebDAVRepository(strings.Replace(ts.URL, "http://", "webdav://", 1)+"/dav/repo", tC.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = repo.UploadStream(ctx, strings.NewReader("0123456789"), "refs/latest", nil)
+			if tC.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			_, err = repo.Stat(ctx, "refs/latest")
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestWebDAVUpload(t *testing.T) {
+	ctx := context.Background()
+	ts, dir := newWebDAVTestServer(t, "digest")
+
+	result, err := ParseRepo(strings.Replace(ts.URL, "http://", "webdav://", 1) + "/dav/repo")
+	assert.NoError(t, err)
+	assert.Equal(t, "webdav", result.Backend())
+
+	t.Setenv("AVC_WEBDAV_USERNAME", testWebDAVUsername)
+	t.Setenv("AVC_WEBDAV_PASSWORD", testWebDAVPassword)
+	repo, err := NewRepository(result, RepositoryConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, repo.UploadStream(ctx, strings.NewReader("0123456789"), "objects/ab/cdef", nil))
+
+	// the temp files are moved to the destination
+	entries, err := os.ReadDir(filepath.Join(dir, "repo", "tmp"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 0)
+
+	data, err := os.ReadFile(filepath.Join(dir, "repo", "objects", "ab", "cdef"))
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	reader, err := ReadRange(ctx, repo, "objects/ab/cdef", 2, 3)
+	assert.NoError(t, err)
+	data, _ = io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "234", string(data))
+
+	_, err = repo.Stat(ctx, "objects/ab/notfound")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	wrong, _ := NewWebDAVRepository(result.Repo, WebDAVConfig{Username: testWebDAVUsername, Password: "wrong"})
+	err = wrong.UploadStream(ctx, strings.NewReader("abc"), "objects/ab/cdef", nil)
+	assert.Error(t, err)
+	assert.NoError(t, repo.Delete(ctx, "objects/ab/cdef"))
+}
+
+func TestParseAuthParams(t *testing.T) {
+	params := parseAuthParams(`realm="my realm", qop="auth,auth-int", nonce="abc", algorithm=MD5, stale=true`)
+	assert.Equal(t, map[string]string{
+		"realm":     "my realm",
+		"qop":       "auth,auth-int",
+		"nonce":     "abc",
+		"algorithm": "MD5",
+		"stale":     "true",
+	}, params)
+}
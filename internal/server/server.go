@@ -0,0 +1,247 @@
+// Package server exposes a repository over HTTP. The objects are read by GET and HEAD and written by PUT and
+// DELETE. A directory is listed in JSON by GET with the "list" query parameter.
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/infuseai/artivc/internal/log"
+	"github.com/infuseai/artivc/internal/repository"
+)
+
+type Options struct {
+	// The bearer token required by the write requests. The writes are rejected if it is empty.
+	Token string
+	// Require the token for the read requests as well
+	AuthRead bool
+}
+
+// Server is the http handler of a repository
+type Server struct {
+	repo    repository.Repository
+	options Options
+}
+
+func New(repo repository.Repository, options Options) *Server {
+	return &Server{repo: repo, options: options}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	repoPath, ok := cleanPath(r.URL.Path)
+	if !ok {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+
+	log.Debugf("%s %s\n", r.Method, r.URL.Path)
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if s.options.AuthRead && !s.authorized(w, r) {
+			return
+		}
+
+		if _, list := r.URL.Query()["list"]; list && r.Method == http.MethodGet {
+			s.list(w, r, repoPath)
+		} else if r.Method == http.MethodHead {
+			s.stat(w, r, repoPath)
+		} else {
+			s.get(w, r, repoPath)
+		}
+	case http.MethodPut:
+		if !s.authorized(w, r) {
+			return
+		}
+		s.put(w, r, repoPath)
+	case http.MethodDelete:
+		if !s.authorized(w, r) {
+			return
+		}
+		s.delete(w, r, repoPath)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized checks the bearer token. It writes the error response if the request is not authorized.
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if s.options.Token == "" {
+		http.Error(w, "the server is read-only", http.StatusForbidden)
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.options.Token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="avc"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, repoPath string) {
+	if repoPath == "" {
+		http.Error(w, "not a file", http.StatusNotFound)
+		return
+	}
+
+	if byteRange := r.Header.Get("Range"); byteRange != "" {
+		if offset, length, ok := parseRange(byteRange); ok {
+			reader, err := repository.ReadRange(r.Context(), s.repo, repoPath, offset, length)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			defer reader.Close()
+
+			// the total size is unknown without an extra request
+			end := ""
+			if length > 0 {
+				end = strconv.FormatInt(offset+length-1, 10)
+			}
+			w.Header().Set("Content-Range", "bytes "+strconv.FormatInt(offset, 10)+"-"+end+"/*")
+			w.WriteHeader(http.StatusPartialContent)
+			io.Copy(w, reader)
+			return
+		}
+	}
+
+	// the status is sent on the first write, so that a missing object is still reported as an error
+	dest := &responseWriter{w: w}
+	err := s.repo.DownloadStream(r.Context(), repoPath, dest, nil)
+	if err != nil && !dest.written {
+		writeError(w, err)
+		return
+	}
+
+	if err != nil {
+		log.Debugf("download %s failed: %s\n", repoPath, err.Error())
+	}
+}
+
+func (s *Server) stat(w http.ResponseWriter, r *http.Request, repoPath string) {
+	info, err := s.repo.Stat(r.Context(), repoPath)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if info.IsDir() {
+		http.Error(w, "not a file", http.StatusNotFound)
+		return
+	}
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request, repoPath string) {
+	entries, err := s.repo.List(r.Context(), repoPath)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	result := []repository.IndexEntry{}
+	for _, entry := range entries {
+		result = append(result, repository.IndexEntry{Name: entry.Name(), IsDir: entry.IsDir()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, repoPath string) {
+	if repoPath == "" {
+		http.Error(w, "not a file", http.StatusBadRequest)
+		return
+	}
+
+	err := s.repo.UploadStream(r.Context(), r.Body, repoPath, nil)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, repoPath string) {
+	err := s.repo.Delete(r.Context(), repoPath)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// cleanPath converts the url path to the repository path. The path must not escape the repository.
+func cleanPath(urlPath string) (string, bool) {
+	for _, comp := range strings.Split(urlPath, "/") {
+		if comp == ".." {
+			return "", false
+		}
+	}
+
+	return strings.TrimPrefix(path.Clean("/"+urlPath), "/"), true
+}
+
+// parseRange parses the single range of the Range header. A negative length means to the end.
+func parseRange(value string) (offset, length int64, ok bool) {
+	if !strings.HasPrefix(value, "bytes=") || strings.Contains(value, ",") {
+		return 0, 0, false
+	}
+
+	comps := strings.SplitN(strings.TrimPrefix(value, "bytes="), "-", 2)
+	if len(comps) != 2 || comps[0] == "" {
+		return 0, 0, false
+	}
+
+	offset, err := strconv.ParseInt(comps[0], 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, false
+	}
+
+	if comps[1] == "" {
+		return offset, -1, true
+	}
+
+	end, err := strconv.ParseInt(comps[1], 10, 64)
+	if err != nil || end < offset {
+		return 0, 0, false
+	}
+
+	return offset, end - offset + 1, true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var statusErr repository.HttpStatusError
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.As(err, &statusErr):
+		http.Error(w, err.Error(), statusErr.StatusCode)
+	default:
+		log.Debugln(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// responseWriter tracks if the response body is started
+type responseWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.w.Write(p)
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+const testToken = "secret"
+
+func newTestServer(t *testing.T, options Options) (*httptest.Server, string) {
+	repoDir := t.TempDir()
+	repo, err := repository.NewLocalFileSystemRepository(repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(New(repo, options))
+	t.Cleanup(ts.Close)
+	return ts, repoDir
+}
+
+func doRequest(t *testing.T, method, url, token, body string) (int, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	data, _ := io.ReadAll(res.Body)
+	return res.StatusCode, string(data)
+}
+
+func TestServer(t *testing.T) {
+	ts, repoDir := newTestServer(t, Options{Token: testToken})
+
+	testCases := []struct {
+		desc   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		result string
+	}{
+		{desc: "put without token", method: http.MethodPut, path: "/refs/latest", body: "abc", status: http.StatusUnauthorized},
+		{desc: "put with wrong token", method: http.MethodPut, path: "/refs/latest", token: "wrong", body: "abc", status: http.StatusUnauthorized},
+		{desc: "put", method: http.MethodPut, path: "/refs/latest", token: testToken, body: "abc", status: http.StatusCreated},
+		{desc: "get", method: http.MethodGet, path: "/refs/latest", status: http.StatusOK, result: "abc"},
+		{desc: "head", method: http.MethodHead, path: "/refs/latest", status: http.StatusOK},
+		{desc: "get not found", method: http.MethodGet, path: "/refs/tags/v1", status: http.StatusNotFound},
+		{desc: "head not found", method: http.MethodHead, path: "/refs/tags/v1", status: http.StatusNotFound},
+		{desc: "list", method: http.MethodGet, path: "/refs?list", status: http.StatusOK, result: "[{\"name\":\"latest\"}]\n"},
+		{desc: "escape the repository", method: http.MethodGet, path: "/refs/../../etc/passwd", status: http.StatusBadRequest},
+		{desc: "delete without token", method: http.MethodDelete, path: "/refs/latest", status: http.StatusUnauthorized},
+		{desc: "delete", method: http.MethodDelete, path: "/refs/latest", token: testToken, status: http.StatusNoContent},
+		{desc: "get deleted", method: http.MethodGet, path: "/refs/latest", status: http.StatusNotFound},
+		{desc: "method not allowed", method: http.MethodPost, path: "/refs/latest", status: http.StatusMethodNotAllowed},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			// the client cleans the path. send it as is.
+			url := ts.URL + tC.path
+			status, body := doRequest(t, tC.method, url, tC.token, tC.body)
+			assert.Equal(t, tC.status, status)
+			if tC.result != "" {
+				assert.Equal(t, tC.result, body)
+			}
+		})
+	}
+
+	_, err := os.Stat(filepath.Join(repoDir, "refs/latest"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestServerReadOnly(t *testing.T) {
+	ts, _ := newTestServer(t, Options{})
+
+	status, _ := doRequest(t, http.MethodPut, ts.URL+"/refs/latest", testToken, "abc")
+	assert.Equal(t, http.StatusForbidden, status)
+}
+
+func TestServerAuthRead(t *testing.T) {
+	ts, _ := newTestServer(t, Options{Token: testToken, AuthRead: true})
+
+	status, _ := doRequest(t, http.MethodPut, ts.URL+"/refs/latest", testToken, "abc")
+	assert.Equal(t, http.StatusCreated, status)
+
+	status, _ = doRequest(t, http.MethodGet, ts.URL+"/refs/latest", "", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, body := doRequest(t, http.MethodGet, ts.URL+"/refs/latest", testToken, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "abc", body)
+}
+
+func TestHttpRepository(t *testing.T) {
+	ts, _ := newTestServer(t, Options{Token: testToken})
+	ctx := context.Background()
+
+	repo, err := repository.NewHttpRepository(ts.URL, repository.HttpConfig{Token: testToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, repo.UploadStream(ctx, strings.NewReader("0123456789"), "objects/ab/cdef", nil))
+
+	var buf bytes.Buffer
+	assert.NoError(t, repo.DownloadStream(ctx, "objects/ab/cdef", &buf, nil))
+	assert.Equal(t, "0123456789", buf.String())
+
+	reader, err := repository.ReadRange(ctx, repo, "objects/ab/cdef", 2, 3)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "234", string(data))
+
+	entries, err := repo.List(ctx, "objects")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "ab", entries[0].Name())
+	assert.True(t, entries[0].IsDir())
+
+	_, err = repo.Stat(ctx, "objects/ab/notfound")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	readOnly, _ := repository.NewHttpRepository(ts.URL, repository.HttpConfig{})
+	assert.Error(t, readOnly.Delete(ctx, "objects/ab/cdef"))
+	assert.NoError(t, repo.Delete(ctx, "objects/ab/cdef"))
+}
+
+func TestPushPullThroughServer(t *testing.T) {
+	ts, _ := newTestServer(t, Options{Token: testToken})
+	ctx := context.Background()
+	t.Setenv("AVC_HTTP_TOKEN", testToken)
+
+	wp1 := t.TempDir()
+	wp2 := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(wp1, "a"), []byte("a"), 0644))
+
+	assert.NoError(t, core.InitWorkspace(wp1, ts.URL))
+	config, _ := core.LoadConfig(wp1)
+	mngr, err := core.NewArtifactManager(config)
+	assert.NoError(t, err)
+
+	tag := "v1"
+	pushResult, err := mngr.Push(ctx, core.PushOptions{Tag: &tag})
+	assert.NoError(t, err)
+
+	tags, err := mngr.ListTags(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []core.TagEntry{{Name: tag, Commit: pushResult.Commit}}, tags)
+
+	assert.NoError(t, core.InitWorkspace(wp2, ts.URL))
+	config, _ = core.LoadConfig(wp2)
+	mngr, err = core.NewArtifactManager(config)
+	assert.NoError(t, err)
+
+	ref := tag
+	_, err = mngr.Pull(ctx, core.PullOptions{RefOrCommit: &ref})
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(wp2, "a"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+}
@@ -0,0 +1,703 @@
+// Package avc is the Go client of ArtiVC. It provides the same operations as the avc command and returns the
+// structured results instead of printing them.
+package avc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/infuseai/artivc/internal/core"
+	"github.com/infuseai/artivc/internal/dvc"
+	"github.com/infuseai/artivc/internal/git"
+	"github.com/infuseai/artivc/internal/repository"
+)
+
+type Options struct {
+	// The receiver of the progress events. nil to discard.
+	Progress ProgressReporter
+	// The writer of the human-readable output, which is the same as the avc command. nil to discard.
+	Output io.Writer
+	// The number of concurrent transfers, or "auto" to adjust by the throughput. It overrides the config.
+	Jobs string
+	// The bandwidth limit in the rclone "--bwlimit" syntax. It overrides the config.
+	BandwidthLimit string
+	// The named remote of the workspace to operate. The default remote is used if it is empty.
+	Remote string
+}
+
+type PushOptions struct {
+	DryRun  bool
+	Message string
+	// Tag the pushed commit
+	Tag string
+	// Write the static listing index for the plain HTTP mirrors. It is always written if "repo.index" is set.
+	Index bool
+}
+
+type ImportOptions struct {
+	// The commit message. It is "import <archive>" if it is empty.
+	Message string
+	// Tag the created commit
+	Tag string
+	// The time of the commit. The modification time of the archive is used if it is zero.
+	CreatedAt time.Time
+	// Strip the leading components of the paths in the archive
+	StripComponents int
+}
+
+type ImportDVCOptions struct {
+	// The DVC cache or local remote dirs to find the data. The cache of the project is used if it is empty.
+	CacheDirs []string
+	// Create a commit for each git commit changing the DVC files instead of the working tree
+	History bool
+	// The commit message of the working tree. It is "import <dir>" if it is empty.
+	Message string
+	// Tag the last created commit
+	Tag string
+}
+
+type ImportGitOptions struct {
+	// The subdir of the git repository to import. The paths of the commits are relative to it.
+	Path string
+	// The git revision to import the history of. It is "HEAD" if it is empty.
+	Ref string
+}
+
+type PullOptions struct {
+	DryRun bool
+	// Delete the files which are not in the commit
+	Delete bool
+	// The commit or reference to pull. The latest commit is pulled if it is empty.
+	Ref string
+	// Pull the matched paths only. The patterns are in the gitignore syntax.
+	Paths []string
+}
+
+type GetOptions struct {
+	Options
+	PullOptions
+}
+
+type PutOptions struct {
+	Options
+	PushOptions
+}
+
+// Client operates a workspace
+type Client struct {
+	config  core.ArtConfig
+	mngr    *core.ArtifactManager
+	options Options
+}
+
+// Open opens the workspace containing the directory. The current directory is used if dir is empty.
+func Open(dir string, options Options) (*Client, error) {
+	config, err := core.LoadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Remote != "" {
+		if err := config.UseRemote(options.Remote); err != nil {
+			return nil, err
+		}
+	}
+
+	return newClient(config, options)
+}
+
+// Init creates a workspace of the repository in the directory
+func Init(dir, repo string, options Options) (*Client, error) {
+	repo, err := checkRepository(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(writerOrDiscard(options.Output), "Initialize the artivc workspace of the repository '%s'\n", repo)
+	if err := core.InitWorkspace(dir, repo); err != nil {
+		return nil, err
+	}
+
+	return Open(dir, options)
+}
+
+// Clone creates a workspace of the repository in the directory and pulls the latest commit. The directory
+// is created if it does not exist. It must be empty otherwise.
+func Clone(ctx context.Context, repo, dir string, options Options) (*Client, *PullResult, error) {
+	repo, err := checkRepository(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := dir
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = os.Mkdir(dir, fs.ModePerm)
+	if err == nil || (os.IsExist(err) && isDirEmpty(dir)) {
+		// pass
+	} else if os.IsExist(err) {
+		return nil, nil, fmt.Errorf("destination path '%s' already exists and is not an empty directory", name)
+	} else {
+		return nil, nil, fmt.Errorf("cannot create destination path '%s'", name)
+	}
+	fmt.Fprintf(writerOrDiscard(options.Output), "Cloning into '%s'...\n", name)
+
+	client, result, err := clone(ctx, repo, dir, options)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	return client, result, nil
+}
+
+func clone(ctx context.Context, repo, dir string, options Options) (*Client, *PullResult, error) {
+	if err := core.InitWorkspace(dir, repo); err != nil {
+		return nil, nil, err
+	}
+
+	client, err := Open(dir, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result, err := client.Pull(ctx, PullOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, result, nil
+}
+
+// Get downloads a commit of the repository to the directory without a workspace
+func Get(ctx context.Context, repo, dir string, options GetOptions) (*PullResult, error) {
+	metadataDir, err := os.MkdirTemp(os.TempDir(), "*-avc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(metadataDir)
+
+	client, err := newClient(core.NewConfig(dir, metadataDir, repo), options.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Pull(ctx, options.PullOptions)
+}
+
+// Put uploads the directory to the repository as a new commit without a workspace
+func Put(ctx context.Context, dir, repo string, options PutOptions) (*PushResult, error) {
+	metadataDir, err := os.MkdirTemp(os.TempDir(), "*-avc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(metadataDir)
+
+	client, err := newClient(core.NewConfig(dir, metadataDir, repo), options.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Push(ctx, options.PushOptions)
+}
+
+// Import creates a commit from the tar or zip archive without a workspace. The parent is the latest commit of the
+// repository.
+func Import(ctx context.Context, repo, archive string, importOptions ImportOptions, options Options) (*ImportResult, error) {
+	metadataDir, err := os.MkdirTemp(os.TempDir(), "*-avc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(metadataDir)
+
+	// no file is written to the base dir
+	client, err := newClient(core.NewConfig(metadataDir, metadataDir, repo), options)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Import(ctx, archive, importOptions)
+}
+
+// ImportDVC creates the commits from the data tracked by the DVC project without a workspace. The parent of the
+// first commit is the latest commit of the repository.
+func ImportDVC(ctx context.Context, repo, dir string, importOptions ImportDVCOptions, options Options) ([]*ImportResult, error) {
+	metadataDir, err := os.MkdirTemp(os.TempDir(), "*-avc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(metadataDir)
+
+	// no file is written to the base dir
+	client, err := newClient(core.NewConfig(metadataDir, metadataDir, repo), options)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.ImportDVC(ctx, dir, importOptions)
+}
+
+// ImportGit creates a commit for each git commit changing the files without a workspace. The parent of the first
+// commit is the latest commit of the repository.
+func ImportGit(ctx context.Context, repo, gitDir string, importOptions ImportGitOptions, options Options) ([]*ImportResult, error) {
+	metadataDir, err := os.MkdirTemp(os.TempDir(), "*-avc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(metadataDir)
+
+	// no file is written to the base dir
+	client, err := newClient(core.NewConfig(metadataDir, metadataDir, repo), options)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.ImportGit(ctx, gitDir, importOptions)
+}
+
+// Sync copies the references, commits and missing objects from the source repository to the destination
+// repository. The transfer options apply to the destination.
+func Sync(ctx context.Context, src, dst string, syncOptions SyncOptions, options Options) (*SyncResult, error) {
+	srcMetadataDir, err := os.MkdirTemp(os.TempDir(), "*-avc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(srcMetadataDir)
+
+	dstMetadataDir, err := os.MkdirTemp(os.TempDir(), "*-avc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dstMetadataDir)
+
+	// no file is written to the base dirs
+	srcClient, err := newClient(core.NewConfig(srcMetadataDir, srcMetadataDir, src), Options{Jobs: options.Jobs})
+	if err != nil {
+		return nil, err
+	}
+
+	dstClient, err := newClient(core.NewConfig(dstMetadataDir, dstMetadataDir, dst), options)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := srcClient.mngr.Sync(ctx, dstClient.mngr, syncOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	writeSyncResult(dstClient.output(), result)
+	return result, nil
+}
+
+// Cat writes the file of the commit or reference in the repository to the writer. The latest commit is used if
+// the ref is empty.
+func Cat(ctx context.Context, repo, ref, path string, dest io.Writer, options Options) (*BlobMetaData, error) {
+	return cat(ctx, core.NewConfig("", "", repo), ref, path, dest, options)
+}
+
+func cat(ctx context.Context, config core.ArtConfig, ref, path string, dest io.Writer, options Options) (*BlobMetaData, error) {
+	metadataDir, err := os.MkdirTemp(os.TempDir(), "*-avc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(metadataDir)
+
+	config.MetadataDir = metadataDir
+	if config.BaseDir == "" {
+		// no file is written to the base dir
+		config.BaseDir = metadataDir
+	}
+	client, err := newClient(config, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref == "" {
+		ref = RefLatest
+	}
+	return client.mngr.Cat(ctx, ref, path, dest)
+}
+
+// Export streams the files of the commit or reference in the repository into the archive. The latest commit is used if
+// the ref is empty.
+func Export(ctx context.Context, repo, ref string, exportOptions ExportOptions, create ShardWriterFunc, options Options) (*ExportResult, error) {
+	return export(ctx, core.NewConfig("", "", repo), ref, exportOptions, create, options)
+}
+
+func export(ctx context.Context, config core.ArtConfig, ref string, exportOptions ExportOptions, create ShardWriterFunc, options Options) (*ExportResult, error) {
+	metadataDir, err := os.MkdirTemp(os.TempDir(), "*-avc")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(metadataDir)
+
+	config.MetadataDir = metadataDir
+	if config.BaseDir == "" {
+		// no file is written to the base dir
+		config.BaseDir = metadataDir
+	}
+	client, err := newClient(config, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref == "" {
+		ref = RefLatest
+	}
+	return client.mngr.Export(ctx, ref, exportOptions, create)
+}
+
+// RepoName returns the default directory name to clone or get the repository
+func RepoName(repo string) (string, error) {
+	result, err := repository.ParseRepo(repo)
+	if err != nil {
+		return "", err
+	}
+
+	return repository.ParseRepoName(result)
+}
+
+func newClient(config core.ArtConfig, options Options) (*Client, error) {
+	if err := applyTransferOptions(&config, options); err != nil {
+		return nil, err
+	}
+
+	mngr, err := core.NewArtifactManager(config)
+	if err != nil {
+		return nil, err
+	}
+	mngr.SetProgressReporter(options.Progress)
+
+	return &Client{config: config, mngr: mngr, options: options}, nil
+}
+
+// applyTransferOptions overrides the transfer config by the options
+func applyTransferOptions(config *core.ArtConfig, options Options) error {
+	if options.BandwidthLimit != "" {
+		config.Set("transfer.bwlimit", options.BandwidthLimit)
+	}
+
+	if options.Jobs != "" {
+		if options.Jobs != "auto" {
+			if n, err := strconv.Atoi(options.Jobs); err != nil || n <= 0 {
+				return fmt.Errorf("invalid number of jobs: %s", options.Jobs)
+			}
+		}
+
+		// the option takes precedence over the backend specific config
+		config.Set("transfer.concurrency", options.Jobs)
+		config.Unset("transfer." + config.RepoBackend() + ".concurrency")
+	}
+
+	return nil
+}
+
+// checkRepository checks if the repository is supported and accessible. It returns the normalized repository url.
+func checkRepository(repo string) (string, error) {
+	result, err := repository.ParseRepo(repo)
+	if err != nil {
+		return "", err
+	}
+
+	// probe with the same settings as the workspace to be written, e.g. the retry and the ssh timeouts
+	config := core.NewConfig("", "", result.Repo)
+	repoConfig, err := config.RepositoryConfig()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = repository.NewRepository(result, repoConfig)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Repo, nil
+}
+
+// RepoUrl returns the repository of the workspace
+func (c *Client) RepoUrl() string {
+	return c.config.RepoUrl()
+}
+
+// Remote returns the name of the remote of the client. It is empty if "repo.url" is used.
+func (c *Client) Remote() string {
+	return c.config.Remote()
+}
+
+// BaseDir returns the root directory of the workspace
+func (c *Client) BaseDir() string {
+	return c.config.BaseDir
+}
+
+func (c *Client) output() io.Writer {
+	return writerOrDiscard(c.options.Output)
+}
+
+func (c *Client) Push(ctx context.Context, options PushOptions) (*PushResult, error) {
+	coreOptions := core.PushOptions{DryRun: options.DryRun, Index: options.Index}
+	if options.Message != "" {
+		coreOptions.Message = &options.Message
+	}
+	if options.Tag != "" {
+		coreOptions.Tag = &options.Tag
+	}
+
+	result, err := c.mngr.Push(ctx, coreOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	writePushResult(c.output(), result)
+	return result, nil
+}
+
+// Import creates a commit from the tar or zip archive. The parent is the latest commit of the repository.
+func (c *Client) Import(ctx context.Context, archive string, options ImportOptions) (*ImportResult, error) {
+	coreOptions := core.ImportOptions{CreatedAt: options.CreatedAt, StripComponents: options.StripComponents}
+	if options.Message != "" {
+		coreOptions.Message = &options.Message
+	}
+	if options.Tag != "" {
+		coreOptions.Tag = &options.Tag
+	}
+
+	result, err := c.mngr.Import(ctx, archive, coreOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	writeImportResult(c.output(), result)
+	return result, nil
+}
+
+// ImportDVC creates the commits from the data tracked by the DVC project. The MD5-addressed objects in the DVC cache
+// are uploaded as the blobs, so the data is not downloaded again.
+func (c *Client) ImportDVC(ctx context.Context, dir string, options ImportDVCOptions) ([]*ImportResult, error) {
+	project, err := dvc.NewProject(dir, options.CacheDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []*dvc.Snapshot{project.WorkingTree()}
+	if options.History {
+		snapshots, err = project.History(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(snapshots) == 0 {
+			return nil, errors.New("no git commit changes the DVC files")
+		}
+	}
+
+	results := []*ImportResult{}
+	for i, snapshot := range snapshots {
+		files, err := project.Files(snapshot)
+		if err != nil {
+			return nil, err
+		}
+
+		source := dir
+		message := snapshot.Message
+		if snapshot.Revision != "" {
+			source = fmt.Sprintf("%s@%.7s", dir, snapshot.Revision)
+		} else if options.Message != "" {
+			message = options.Message
+		} else {
+			message = "import " + dir
+		}
+
+		coreOptions := core.ImportOptions{Message: &message, CreatedAt: snapshot.CreatedAt}
+		if options.Tag != "" && i == len(snapshots)-1 {
+			coreOptions.Tag = &options.Tag
+		}
+
+		result, err := c.mngr.ImportFiles(ctx, source, files, coreOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		writeImportResult(c.output(), result)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// ImportGit creates a commit for each git commit changing the files, with the message, author and time of the git
+// commit. Only the first parents of the merges are followed.
+func (c *Client) ImportGit(ctx context.Context, gitDir string, options ImportGitOptions) ([]*ImportResult, error) {
+	repo, err := git.Open(ctx, gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ref := options.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	commits, err := repo.Log(ctx, ref, options.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, errors.New("no git commit changes the files")
+	}
+
+	results := []*ImportResult{}
+	for i := range commits {
+		commit := commits[i]
+		files, err := repo.Files(ctx, commit.Hash, options.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		coreOptions := core.ImportOptions{Message: &commit.Message, Author: &commit.Author, CreatedAt: commit.CreatedAt}
+		result, err := c.mngr.ImportFiles(ctx, fmt.Sprintf("%s@%.7s", gitDir, commit.Hash), files, coreOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		writeImportResult(c.output(), result)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (c *Client) Pull(ctx context.Context, options PullOptions) (*PullResult, error) {
+	coreOptions := core.PullOptions{DryRun: options.DryRun, Delete: options.Delete}
+	if options.Ref != "" {
+		coreOptions.RefOrCommit = &options.Ref
+	}
+	if len(options.Paths) > 0 {
+		if options.Delete {
+			return nil, errors.New("cannot pull partial files and specify delete flag at the same time")
+		}
+
+		include := core.NewAvcInclude(options.Paths)
+		coreOptions.FileFilter = func(path string) bool {
+			return include.MatchesPath(path)
+		}
+	}
+
+	result, err := c.mngr.Pull(ctx, coreOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	writePullResult(c.output(), result)
+	return result, nil
+}
+
+// Fetch downloads the references from the repository. If all is set, all the commits are downloaded as well.
+func (c *Client) Fetch(ctx context.Context, all bool) error {
+	return c.mngr.Fetch(ctx, core.FetchOptions{All: all})
+}
+
+// Status compares the workspace with the latest commit
+func (c *Client) Status(ctx context.Context) (DiffResult, error) {
+	result, err := c.mngr.Status(ctx)
+	if err != nil {
+		return DiffResult{}, err
+	}
+
+	writeDiffResult(c.output(), result, true)
+	return result, nil
+}
+
+// Diff compares two commits or references
+func (c *Client) Diff(ctx context.Context, left, right string) (DiffResult, error) {
+	result, err := c.mngr.Diff(ctx, core.DiffOptions{
+		LeftRef:  left,
+		RightRef: right,
+	})
+	if err != nil {
+		return DiffResult{}, err
+	}
+
+	writeDiffResult(c.output(), result, true)
+	return result, nil
+}
+
+// Log returns the commits from the commit or reference to the ancestors
+func (c *Client) Log(ctx context.Context, ref string) ([]LogEntry, error) {
+	entries, err := c.mngr.Log(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	writeLogEntries(c.output(), entries)
+	return entries, nil
+}
+
+// List returns the files of the commit or reference
+func (c *Client) List(ctx context.Context, ref string) ([]BlobMetaData, error) {
+	blobs, err := c.mngr.List(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, blob := range blobs {
+		fmt.Fprintln(c.output(), blob.Path)
+	}
+	return blobs, nil
+}
+
+func (c *Client) Tags(ctx context.Context) ([]TagEntry, error) {
+	tags, err := c.mngr.ListTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		fmt.Fprintln(c.output(), tag.Name)
+	}
+	return tags, nil
+}
+
+// AddTag tags the commit or reference
+func (c *Client) AddTag(ctx context.Context, ref, tag string) error {
+	return c.mngr.AddTag(ctx, ref, tag)
+}
+
+func (c *Client) DeleteTag(ctx context.Context, tag string) error {
+	return c.mngr.DeleteTag(ctx, tag)
+}
+
+// Cat writes the file of the commit or reference to the writer. The metadata is not cached in the workspace.
+func (c *Client) Cat(ctx context.Context, ref, path string, dest io.Writer) (*BlobMetaData, error) {
+	return cat(ctx, c.config, ref, path, dest, c.options)
+}
+
+// Export streams the files of the commit or reference into the archive. The metadata is not cached in the workspace.
+func (c *Client) Export(ctx context.Context, ref string, exportOptions ExportOptions, create ShardWriterFunc) (*ExportResult, error) {
+	return export(ctx, c.config, ref, exportOptions, create, c.options)
+}
+
+func writerOrDiscard(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
+
+func isDirEmpty(dir string) bool {
+	f, err := os.Open(dir)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	_, err = f.Readdirnames(1)
+	return err == io.EOF
+}
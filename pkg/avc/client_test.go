@@ -0,0 +1,125 @@
+package avc
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	wp1 := t.TempDir()
+	wp2 := filepath.Join(t.TempDir(), "clone")
+	repo := t.TempDir()
+
+	writeTestFile(t, filepath.Join(wp1, "a"), "a")
+
+	var output bytes.Buffer
+	client1, err := Init(wp1, repo, Options{Output: &output})
+	assert.NoError(t, err)
+	assert.Equal(t, repo, client1.RepoUrl())
+
+	var mtx sync.Mutex
+	events := []ProgressEvent{}
+	client1, err = Open(wp1, Options{
+		Output: &output,
+		Progress: ProgressReporterFunc(func(event ProgressEvent) {
+			mtx.Lock()
+			events = append(events, event)
+			mtx.Unlock()
+		}),
+	})
+	assert.NoError(t, err)
+
+	output.Reset()
+	pushResult, err := client1.Push(ctx, PushOptions{Message: "first", Tag: "v1"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, pushResult.Commit)
+	assert.Equal(t, "v1", pushResult.Tag)
+	assert.Equal(t, []DiffRecord{{Type: DiffTypeAdd, Hash: pushResult.Diff.Records[0].Hash, Path: "a", Size: 1, Mode: 0o644}}, pushResult.Diff.Records)
+	assert.Contains(t, output.String(), "create commit: "+pushResult.Commit)
+	assert.NotEmpty(t, events)
+
+	// clone
+	client2, pullResult, err := Clone(ctx, repo, wp2, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, pushResult.Commit, pullResult.Commit)
+	data, err := os.ReadFile(filepath.Join(wp2, "a"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+
+	// status
+	writeTestFile(t, filepath.Join(wp2, "b"), "b")
+	status, err := client2.Status(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, DiffStats{Added: 1}, status.Stats())
+
+	_, err = client2.Push(ctx, PushOptions{})
+	assert.NoError(t, err)
+
+	// log, list, tags
+	entries, err := client1.Log(ctx, RefLatest)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, []string{"v1"}, entries[1].Refs)
+
+	blobs, err := client1.List(ctx, "v1")
+	assert.NoError(t, err)
+	assert.Len(t, blobs, 1)
+
+	diff, err := client1.Diff(ctx, "v1", RefLatest)
+	assert.NoError(t, err)
+	assert.Equal(t, DiffStats{Added: 1}, diff.Stats())
+
+	assert.NoError(t, client1.DeleteTag(ctx, "v1"))
+	tags, err := client1.Tags(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+
+	// clone to a non-empty directory
+	_, _, err = Clone(ctx, repo, wp2, Options{})
+	assert.Error(t, err)
+}
+
+func TestGetPut(t *testing.T) {
+	ctx := context.Background()
+	src := t.TempDir()
+	dest := t.TempDir()
+	repo := t.TempDir()
+
+	writeTestFile(t, filepath.Join(src, "a"), "a")
+	writeTestFile(t, filepath.Join(src, "sub/b"), "b")
+
+	pushResult, err := Put(ctx, src, repo, PutOptions{PushOptions: PushOptions{Tag: "v1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, pushResult.Transfer.Objects)
+
+	pullResult, err := Get(ctx, repo, dest, GetOptions{PullOptions: PullOptions{Ref: "v1", Paths: []string{"sub/"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pullResult.Transfer.Objects)
+
+	_, err = os.Stat(filepath.Join(dest, "a"))
+	assert.True(t, os.IsNotExist(err))
+	data, err := os.ReadFile(filepath.Join(dest, "sub/b"))
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(data))
+
+	_, err = Get(ctx, repo, dest, GetOptions{Options: Options{Jobs: "0"}})
+	assert.Error(t, err)
+
+	var buf bytes.Buffer
+	blob, err := Cat(ctx, repo, "v1", "sub/b", &buf, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "sub/b", blob.Path)
+	assert.Equal(t, "b", buf.String())
+}
@@ -0,0 +1,112 @@
+package avc
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fatih/color"
+	"github.com/infuseai/artivc/internal/core"
+)
+
+func writePushResult(w io.Writer, result *PushResult) {
+	if result.Commit == "" {
+		writeDiffResult(w, result.Diff, true)
+		return
+	}
+
+	writeDiffResult(w, result.Diff, false)
+	fmt.Fprintln(w, "create commit: "+result.Commit)
+	fmt.Fprintln(w, "update ref: latest -> "+result.Commit)
+	if result.Tag != "" {
+		fmt.Fprintln(w, "add tag: "+result.Tag+" -> "+result.Commit)
+	}
+}
+
+func writeImportResult(w io.Writer, result *ImportResult) {
+	fmt.Fprintf(w, "import %s: %d files\n", result.Source, result.Files)
+	fmt.Fprintln(w, "create commit: "+result.Commit)
+	fmt.Fprintln(w, "update ref: latest -> "+result.Commit)
+	if result.Tag != "" {
+		fmt.Fprintln(w, "add tag: "+result.Tag+" -> "+result.Commit)
+	}
+}
+
+func writeSyncResult(w io.Writer, result *SyncResult) {
+	if len(result.Refs) == 0 && result.Commits == 0 && result.Objects == 0 {
+		fmt.Fprintln(w, "already up to date")
+		return
+	}
+
+	if result.DryRun {
+		fmt.Fprintf(w, "commits to copy: %d\n", result.Commits)
+		fmt.Fprintf(w, "objects to copy: %d\n", result.Objects)
+	} else {
+		fmt.Fprintf(w, "copy commits: %d\n", result.Commits)
+	}
+
+	for _, change := range result.Refs {
+		switch {
+		case change.Commit == "":
+			fmt.Fprintln(w, "delete ref: "+change.Ref)
+		case change.Previous == "":
+			fmt.Fprintln(w, "add ref: "+change.Ref+" -> "+change.Commit)
+		default:
+			fmt.Fprintln(w, "update ref: "+change.Ref+" -> "+change.Commit)
+		}
+	}
+}
+
+func writePullResult(w io.Writer, result *PullResult) {
+	// list the changes if nothing is transferred, e.g. dry run
+	writeDiffResult(w, result.Diff, result.Transfer == nil)
+}
+
+func writeDiffResult(w io.Writer, result DiffResult, verbose bool) {
+	if verbose {
+		for _, record := range result.Records {
+			switch record.Type {
+			case DiffTypeAdd:
+				color.New(color.FgHiGreen).Fprintf(w, "+ %s\n", record.Path)
+			case DiffTypeDelete:
+				color.New(color.FgHiRed).Fprintf(w, "- %s\n", record.Path)
+			case DiffTypeChange:
+				color.New(color.FgHiYellow).Fprintf(w, "M %s\n", record.Path)
+			case DiffTypeRename:
+				color.New(color.FgHiYellow).Fprintf(w, "R %s -> %s\n", record.OldPath, record.Path)
+			}
+		}
+	}
+
+	if !result.IsChanged() {
+		fmt.Fprintln(w, "no changed")
+	} else {
+		stats := result.Stats()
+		fmt.Fprintf(w, "%d modified(M), %d added(+), %d deleted(-), %d renamed(R)\n", stats.Modified, stats.Added, stats.Deleted, stats.Renamed)
+	}
+}
+
+func writeLogEntries(w io.Writer, entries []LogEntry) {
+	yellow := color.New(color.FgYellow)
+	for _, entry := range entries {
+		yellow.Fprintf(w, "%s ", entry.Hash[:8])
+		color.New(color.FgHiBlack).Fprintf(w, "%s ", entry.CreatedAt.Format("2006-01-02 15:04 -0700"))
+
+		if len(entry.Refs) > 0 {
+			yellow.Fprint(w, "(")
+			for i, ref := range entry.Refs {
+				if i > 0 {
+					yellow.Fprint(w, ", ")
+				}
+
+				if ref == core.RefLatest {
+					color.New(color.FgHiGreen).Fprint(w, ref)
+				} else {
+					color.New(color.FgHiRed).Fprint(w, ref)
+				}
+			}
+			yellow.Fprint(w, ") ")
+		}
+
+		color.New(color.FgHiWhite).Fprintln(w, entry.Message)
+	}
+}
@@ -0,0 +1,70 @@
+package avc
+
+import (
+	"github.com/infuseai/artivc/internal/core"
+)
+
+// The data structures returned by the client. They are shared with the CLI json output.
+type (
+	Commit          = core.Commit
+	BlobMetaData    = core.BlobMetaData
+	DiffType        = core.DiffType
+	DiffRecord      = core.DiffRecord
+	DiffResult      = core.DiffResult
+	DiffStats       = core.DiffStats
+	LogEntry        = core.LogEntry
+	TagEntry        = core.TagEntry
+	PushResult      = core.PushResult
+	PullResult      = core.PullResult
+	TransferSummary = core.TransferSummary
+	ExportOptions   = core.ExportOptions
+	ExportResult    = core.ExportResult
+	ShardWriterFunc = core.ShardWriterFunc
+	ImportResult    = core.ImportResult
+	SyncOptions     = core.SyncOptions
+	SyncResult      = core.SyncResult
+	SyncRefChange   = core.SyncRefChange
+
+	ProgressEventType    = core.ProgressEventType
+	ProgressEvent        = core.ProgressEvent
+	ProgressReporter     = core.ProgressReporter
+	ProgressReporterFunc = core.ProgressReporterFunc
+
+	ReferenceNotFoundError = core.ReferenceNotFoundError
+)
+
+const (
+	DiffTypeAdd    = core.DiffTypeAdd
+	DiffTypeDelete = core.DiffTypeDelete
+	DiffTypeChange = core.DiffTypeChange
+	DiffTypeRename = core.DiffTypeRename
+
+	ProgressScanStart      = core.ProgressScanStart
+	ProgressHash           = core.ProgressHash
+	ProgressTransfer       = core.ProgressTransfer
+	ProgressTransferStart  = core.ProgressTransferStart
+	ProgressTransferFinish = core.ProgressTransferFinish
+	ProgressSummary        = core.ProgressSummary
+
+	ProgressFormatAuto  = core.ProgressFormatAuto
+	ProgressFormatTTY   = core.ProgressFormatTTY
+	ProgressFormatPlain = core.ProgressFormatPlain
+	ProgressFormatJSON  = core.ProgressFormatJSON
+	ProgressFormatNone  = core.ProgressFormatNone
+
+	RefLatest = core.RefLatest
+
+	ExportFormatTar        = core.ExportFormatTar
+	ExportFormatTarZstd    = core.ExportFormatTarZstd
+	ExportFormatZip        = core.ExportFormatZip
+	ExportFormatBagIt      = core.ExportFormatBagIt
+	ExportFormatWebDataset = core.ExportFormatWebDataset
+)
+
+var (
+	ErrWorkspaceNotFound = core.ErrWorkspaceNotFound
+	ErrEmptyRepository   = core.ErrEmptyRepository
+)
+
+// NewProgressReporter creates the built-in progress renderer. The format is "auto", "tty", "plain", "json" or "none".
+var NewProgressReporter = core.NewProgressReporter